@@ -2,7 +2,10 @@ package action
 
 import (
 	utils "backend/Utils"
+	estructuras "backend/struct"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -12,6 +15,16 @@ import (
  */
 func NewDisk(path string, sizeInBytes int64) error {
 
+	// Los discos en memoria no crean archivos
+	if estructuras.IsMemoryDisk(path) {
+		if err := estructuras.CreateMemoryDisk(path, sizeInBytes); err != nil {
+			utils.LogError("NewDisk", err.Error())
+			return err
+		}
+		utils.LogInfo("NewDisk", fmt.Sprintf("Disco en memoria creado en %s de tamaño %d bytes", path, sizeInBytes))
+		return nil
+	}
+
 	// Verificar si el archivo ya existe
 	if _, err := os.Stat(path); err == nil {
 		utils.LogError("NewDisk", fmt.Sprintf("El archivo ya existe en la ruta: %s", path))
@@ -53,3 +66,192 @@ func NewDisk(path string, sizeInBytes int64) error {
 	utils.LogInfo("NewDisk", fmt.Sprintf("Disco creado con éxito en %s de tamaño %d bytes", path, sizeInBytes))
 	return nil
 }
+
+/*
+ * NewQmiaDisk crea un disco en formato qmia: un contenedor con clusters comprimidos que
+ * solo ocupan espacio al escribirse, en lugar de un archivo del tamaño completo del disco.
+ */
+func NewQmiaDisk(path string, sizeInBytes int64) error {
+
+	if estructuras.IsMemoryDisk(path) {
+		utils.LogError("NewDisk", "Los discos en memoria no admiten el formato qmia")
+		return fmt.Errorf("los discos en memoria no admiten el formato qmia")
+	}
+
+	// Verificar si el archivo ya existe
+	if _, err := os.Stat(path); err == nil {
+		utils.LogError("NewDisk", fmt.Sprintf("El archivo ya existe en la ruta: %s", path))
+		return fmt.Errorf("el archivo ya existe en la ruta: %s", path)
+	}
+
+	// Crear directorios padre si no existen
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		utils.LogError("NewDisk", fmt.Sprintf("Error al crear directorios: %v", err))
+		return fmt.Errorf("error al crear directorios: %v", err)
+	}
+
+	if err := estructuras.CreateQmiaDisk(path, sizeInBytes); err != nil {
+		utils.LogError("NewDisk", err.Error())
+		return err
+	}
+
+	utils.LogInfo("NewDisk", fmt.Sprintf("Disco qmia creado con éxito en %s de tamaño %d bytes", path, sizeInBytes))
+	return nil
+}
+
+/*
+ * ResizeDiskFile cambia el tamaño del archivo de un disco existente.
+ * Al crecer, el espacio agregado queda en ceros; al reducir, se descarta el final del archivo.
+ */
+func ResizeDiskFile(path string, sizeInBytes int64) error {
+
+	if sizeInBytes <= 0 {
+		utils.LogError("ResizeDisk", "El nuevo tamaño debe ser mayor que cero")
+		return fmt.Errorf("el nuevo tamaño debe ser mayor que cero")
+	}
+
+	if estructuras.IsMemoryDisk(path) {
+		if err := estructuras.ResizeMemoryDisk(path, sizeInBytes); err != nil {
+			utils.LogError("ResizeDisk", err.Error())
+			return err
+		}
+		utils.LogInfo("ResizeDisk", fmt.Sprintf("Disco en memoria %s redimensionado a %d bytes", path, sizeInBytes))
+		return nil
+	}
+
+	// Verificar que el archivo existe
+	if _, err := os.Stat(path); err != nil {
+		utils.LogError("ResizeDisk", fmt.Sprintf("No se puede acceder al archivo: %v", err))
+		return fmt.Errorf("no se puede acceder al archivo: %v", err)
+	}
+
+	// Un disco qmia cambia el tamaño registrado en su cabecera, no el de su archivo
+	format, err := estructuras.DiskFormat(path)
+	if err != nil {
+		utils.LogError("ResizeDisk", err.Error())
+		return err
+	}
+	if format == estructuras.DISK_FORMAT_QMIA {
+		if err := estructuras.ResizeQmiaDisk(path, sizeInBytes); err != nil {
+			utils.LogError("ResizeDisk", err.Error())
+			return err
+		}
+		utils.LogInfo("ResizeDisk", fmt.Sprintf("Disco qmia %s redimensionado a %d bytes", path, sizeInBytes))
+		return resizeChecksums(path)
+	}
+
+	// Truncate extiende el archivo con ceros (sin escribirlos físicamente) o lo recorta
+	if err := os.Truncate(path, sizeInBytes); err != nil {
+		utils.LogError("ResizeDisk", fmt.Sprintf("Error al cambiar el tamaño del archivo: %v", err))
+		return fmt.Errorf("error al cambiar el tamaño del archivo: %v", err)
+	}
+
+	utils.LogInfo("ResizeDisk", fmt.Sprintf("Archivo %s redimensionado a %d bytes", path, sizeInBytes))
+	return resizeChecksums(path)
+}
+
+// resizeChecksums ajusta las sumas de verificación del disco redimensionado
+func resizeChecksums(path string) error {
+	if err := estructuras.ResizeChecksums(path); err != nil {
+		utils.LogError("ResizeDisk", err.Error())
+		return err
+	}
+	return nil
+}
+
+/*
+ * CopyDiskFile copia el archivo de un disco a una ruta nueva conservando las regiones dispersas:
+ * los bloques que son solo ceros no se escriben, el destino los obtiene como huecos del archivo.
+ * Retorna la cantidad de bytes con datos que se escribieron.
+ */
+func CopyDiskFile(srcPath, destPath string) (int64, error) {
+
+	// Verificar que el destino no existe
+	if _, err := os.Stat(destPath); err == nil {
+		utils.LogError("CopyDisk", fmt.Sprintf("El archivo ya existe en la ruta: %s", destPath))
+		return 0, fmt.Errorf("el archivo ya existe en la ruta: %s", destPath)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		utils.LogError("CopyDisk", fmt.Sprintf("Error al abrir el disco origen: %v", err))
+		return 0, fmt.Errorf("error al abrir el disco origen: %v", err)
+	}
+	defer src.Close()
+
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("error al obtener información del disco origen: %v", err)
+	}
+
+	// Crear directorios padre si no existen
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		utils.LogError("CopyDisk", fmt.Sprintf("Error al crear directorios: %v", err))
+		return 0, fmt.Errorf("error al crear directorios: %v", err)
+	}
+
+	dest, err := os.OpenFile(destPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		utils.LogError("CopyDisk", fmt.Sprintf("Error al crear el disco destino: %v", err))
+		return 0, fmt.Errorf("error al crear el disco destino: %v", err)
+	}
+	defer dest.Close()
+
+	// Si la copia falla no se deja un disco a medias
+	completed := false
+	defer func() {
+		if !completed {
+			dest.Close()
+			os.Remove(destPath)
+		}
+	}()
+
+	// Copiar en bloques de 64KB, saltando los bloques que son solo ceros
+	buffer := make([]byte, 64*1024)
+	zeroBlock := make([]byte, len(buffer))
+	var offset, written int64
+
+	for {
+		n, readErr := io.ReadFull(src, buffer)
+		if n > 0 {
+			if !bytes.Equal(buffer[:n], zeroBlock[:n]) {
+				if _, err := dest.WriteAt(buffer[:n], offset); err != nil {
+					return written, fmt.Errorf("error al escribir en el disco destino: %v", err)
+				}
+				written += int64(n)
+			}
+			offset += int64(n)
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return written, fmt.Errorf("error al leer el disco origen: %v", readErr)
+		}
+	}
+
+	// Fijar el tamaño final (los huecos del final no se escribieron)
+	if err := dest.Truncate(srcInfo.Size()); err != nil {
+		return written, fmt.Errorf("error al establecer el tamaño del disco destino: %v", err)
+	}
+
+	completed = true
+	utils.LogInfo("CopyDisk", fmt.Sprintf("Disco copiado de %s a %s (%d bytes, %d con datos)",
+		srcPath, destPath, srcInfo.Size(), written))
+	return written, nil
+}
+
+/*
+ * DeleteDisk elimina el archivo de un disco, o libera un disco en memoria.
+ */
+func DeleteDisk(path string) error {
+	// Un comando en curso puede tener el disco abierto
+	estructuras.CloseDevice(path)
+
+	if estructuras.IsMemoryDisk(path) {
+		return estructuras.RemoveMemoryDisk(path)
+	}
+
+	return os.Remove(path)
+}
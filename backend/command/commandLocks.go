@@ -0,0 +1,88 @@
+package command
+
+import (
+	diskCommands "backend/command/disk"
+)
+
+// Comandos que solo leen los discos
+var readOnlyCommands = map[string]bool{
+	"checkdisk": true,
+	"diskinfo":  true,
+	"lsdisk":    true,
+	"diffdisk":  true,
+}
+
+// Parámetros con los que un comando que modifica discos solo los lee
+var readOnlyFlags = map[string]string{
+	"snapshot": "list",
+	"undo":     "list",
+}
+
+// commandDisks retorna los discos que un comando lee y los que modifica, para bloquearlos
+func commandDisks(command string, params map[string]string) (reads, writes []string) {
+	var disks []string
+	for _, param := range []string{"path", "path1", "path2", "dest"} {
+		if path := params[param]; path != "" {
+			disks = append(disks, path)
+		}
+	}
+
+	// Los comandos que reciben un ID usan el disco de la partición montada
+	if id, hasID := params["id"]; hasID {
+		if partition, err := diskCommands.GetMountedPartitionByID(id); err == nil {
+			disks = append(disks, partition.Path)
+		}
+	}
+
+	// mount -all y unmount -all usan los discos del archivo de montaje automático
+	if _, hasAll := params["all"]; hasAll {
+		disks = append(disks, diskCommands.FstabDisks()...)
+	}
+
+	// El origen de cpdisk solo se lee
+	if src := params["src"]; src != "" {
+		reads = append(reads, src)
+	}
+
+	if isReadOnlyCommand(command, params) {
+		return append(reads, disks...), nil
+	}
+	return reads, disks
+}
+
+// isReadOnlyCommand indica si el comando, con sus parámetros, solo lee los discos
+func isReadOnlyCommand(command string, params map[string]string) bool {
+	if readOnlyCommands[command] {
+		return true
+	}
+
+	if flag, exists := readOnlyFlags[command]; exists {
+		_, hasFlag := params[flag]
+		return hasFlag
+	}
+
+	// checkebr solo escribe con -repair
+	if command == "checkebr" {
+		_, hasRepair := params["repair"]
+		return !hasRepair
+	}
+
+	// scrub solo escribe (sus sumas) con -enable o -disable
+	if command == "scrub" {
+		_, hasEnable := params["enable"]
+		_, hasDisable := params["disable"]
+		return !hasEnable && !hasDisable
+	}
+
+	// diskmeta solo escribe (el archivo de metadatos) si cambia algún metadato
+	if command == "diskmeta" {
+		for _, flag := range []string{"label", "description", "owner", "clear"} {
+			if _, hasFlag := params[flag]; hasFlag {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
@@ -3,7 +3,9 @@ package command
 import (
 	utils "backend/Utils"
 	diskCommands "backend/command/disk"
+	estructuras "backend/struct"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -39,7 +41,7 @@ func (cp *CommandParser) ParseAndExecute(commandLine string) *CommandResult {
 		}
 	}
 
-	utils.LogInfo("Parser", fmt.Sprintf("Procesando comando: %s", commandLine))
+	utils.LogInfo("Parser", fmt.Sprintf("Procesando comando: %s", RedactCommandLine(commandLine)))
 
 	// Parsear el comando y sus parámetros
 	parts, err := cp.parseCommandLine(commandLine)
@@ -71,20 +73,94 @@ func (cp *CommandParser) ParseAndExecute(commandLine string) *CommandResult {
 		}
 	}
 
+	// El comando bloquea los discos que usa mientras se ejecuta
+	reads, writes := commandDisks(command, params)
+	lock, err := estructuras.LockDisks(reads, writes)
+	if err != nil {
+		utils.LogError("Parser", err.Error())
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+	defer lock.Unlock()
+
+	// Cada disco se abre una sola vez para todo el comando
+	devices := estructuras.OpenDevices(append(reads, writes...))
+	defer devices.Close()
+
+	// Los cambios estructurales se registran en el diario para poder deshacerlos
+	if undoableCommands[command] {
+		transaction := estructuras.BeginUndo(RedactCommandLine(commandLine), writes)
+		result := cp.executeCommand(command, params)
+		// Un resultado fallido con datos es parcial (mount -all monta algunas entradas aunque otras fallen)
+		estructuras.EndUndo(transaction, result.Success || result.Data != nil)
+		return result
+	}
+
 	// Ejecutar el comando correspondiente
 	return cp.executeCommand(command, params)
 }
 
-// parseCommandLine divide la línea de comando en partes, respetando comillas y parámetros
-func (cp *CommandParser) parseCommandLine(commandLine string) ([]string, error) {
-	var parts []string
+// Comandos cuyos cambios se pueden deshacer con undo
+var undoableCommands = map[string]bool{
+	"fdisk": true,
+	"mount": true,
+	"mkfs":  true,
+}
+
+// RedactCommandLine oculta las frases de contraseña de una línea de comando antes de registrarla
+// Se oculta el mismo valor que toma parseParameters: las palabras siguientes que no
+// empiezan con - forman parte del valor de -passphrase
+func RedactCommandLine(commandLine string) string {
+	tokens, _ := splitCommandLine(commandLine)
+
+	var redacted strings.Builder
+	copied := 0 // Bytes de la línea ya copiados
+	inPassphrase := false
+	for _, token := range tokens {
+		switch {
+		case strings.HasPrefix(token.text, "-"):
+			inPassphrase = false
+			name, _, hasValue := strings.Cut(token.text[1:], "=")
+			if hasValue && strings.EqualFold(name, "passphrase") {
+				redacted.WriteString(commandLine[copied:token.start])
+				redacted.WriteString("-" + name + "=***")
+				copied = token.end
+				inPassphrase = true
+			}
+		case inPassphrase:
+			// Continuación del valor: se omite junto con el espacio que la separa
+			copied = token.end
+		}
+	}
+	redacted.WriteString(commandLine[copied:])
+
+	return redacted.String()
+}
+
+// commandToken es una parte de la línea de comando
+type commandToken struct {
+	text       string // Contenido sin comillas
+	start, end int    // Posición de la parte en la línea, con comillas
+}
+
+// splitCommandLine divide la línea de comando en partes separadas por espacios fuera de comillas
+// Retorna false si las comillas no están balanceadas
+func splitCommandLine(commandLine string) ([]commandToken, bool) {
+	var tokens []commandToken
 	var current strings.Builder
+	start := -1
 	inQuotes := false
 	quoteChar := byte(0)
 
 	for i := 0; i < len(commandLine); i++ {
 		char := commandLine[i]
 
+		if start == -1 && (inQuotes || (char != ' ' && char != '\t')) {
+			start = i
+		}
+
 		switch char {
 		case '"', '\'':
 			if !inQuotes {
@@ -108,9 +184,10 @@ func (cp *CommandParser) parseCommandLine(commandLine string) ([]string, error)
 			} else {
 				// Espacio fuera de comillas - separador
 				if current.Len() > 0 {
-					parts = append(parts, current.String())
+					tokens = append(tokens, commandToken{text: current.String(), start: start, end: i})
 					current.Reset()
 				}
+				start = -1
 			}
 		default:
 			current.WriteByte(char)
@@ -119,14 +196,26 @@ func (cp *CommandParser) parseCommandLine(commandLine string) ([]string, error)
 
 	// Agregar la última parte
 	if current.Len() > 0 {
-		parts = append(parts, current.String())
+		tokens = append(tokens, commandToken{text: current.String(), start: start, end: len(commandLine)})
 	}
 
+	return tokens, !inQuotes
+}
+
+// parseCommandLine divide la línea de comando en partes, respetando comillas y parámetros
+func (cp *CommandParser) parseCommandLine(commandLine string) ([]string, error) {
+	tokens, balanced := splitCommandLine(commandLine)
+
 	// Verificar comillas balanceadas
-	if inQuotes {
+	if !balanced {
 		return nil, fmt.Errorf("comillas no balanceadas")
 	}
 
+	parts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		parts = append(parts, token.text)
+	}
+
 	return parts, nil
 }
 
@@ -214,6 +303,38 @@ func (cp *CommandParser) executeCommand(command string, params map[string]string
 		return cp.executeMounted(params)
 	case "mkfs":
 		return cp.executeMkfs(params)
+	case "defrag":
+		return cp.executeDefrag(params)
+	case "resizedisk":
+		return cp.executeResizeDisk(params)
+	case "cpdisk":
+		return cp.executeCpDisk(params)
+	case "repairmbr":
+		return cp.executeRepairMBR(params)
+	case "checkebr":
+		return cp.executeCheckEBR(params)
+	case "checkdisk":
+		return cp.executeCheckDisk(params)
+	case "diskinfo":
+		return cp.executeDiskInfo(params)
+	case "lsdisk":
+		return cp.executeLsDisk(params)
+	case "diffdisk":
+		return cp.executeDiffDisk(params)
+	case "wipe":
+		return cp.executeWipe(params)
+	case "cleandisk":
+		return cp.executeCleanDisk(params)
+	case "snapshot":
+		return cp.executeSnapshot(params)
+	case "undo":
+		return cp.executeUndo(params)
+	case "convertdisk":
+		return cp.executeConvertDisk(params)
+	case "scrub":
+		return cp.executeScrub(params)
+	case "diskmeta":
+		return cp.executeDiskMeta(params)
 	default:
 		utils.LogError("Parser", fmt.Sprintf("Comando no reconocido: %s", command))
 		return &CommandResult{
@@ -255,9 +376,11 @@ func (cp *CommandParser) executeMkDisk(params map[string]string) *CommandResult
 	// Parámetros opcionales
 	fit := params["fit"]
 	unit := params["unit"]
+	table := params["table"]
+	format := params["format"]
 
 	// Ejecutar el comando
-	err = diskCommands.MkDisk(size, fit, unit, path)
+	err = diskCommands.MkDisk(size, fit, unit, path, table, format)
 	if err != nil {
 		return &CommandResult{
 			Success: false,
@@ -269,10 +392,12 @@ func (cp *CommandParser) executeMkDisk(params map[string]string) *CommandResult
 		Success: true,
 		Message: fmt.Sprintf("Disco creado exitosamente en %s", path),
 		Data: map[string]interface{}{
-			"path": path,
-			"size": size,
-			"fit":  fit,
-			"unit": unit,
+			"path":   path,
+			"size":   size,
+			"fit":    fit,
+			"unit":   unit,
+			"table":  table,
+			"format": format,
 		},
 	}
 }
@@ -309,6 +434,21 @@ func (cp *CommandParser) executeRmDisk(params map[string]string) *CommandResult
 
 // executeFdisk ejecuta el comando fdisk
 func (cp *CommandParser) executeFdisk(params map[string]string) *CommandResult {
+	// Eliminar partición
+	if _, hasDelete := params["delete"]; hasDelete {
+		return cp.executeFdiskDelete(params)
+	}
+
+	// Agregar o quitar espacio
+	if _, hasAdd := params["add"]; hasAdd {
+		return cp.executeFdiskAdd(params)
+	}
+
+	// Mover partición
+	if _, hasMove := params["move"]; hasMove {
+		return cp.executeFdiskMove(params)
+	}
+
 	// Validar parámetros obligatorios
 	sizeStr, hasSizeParam := params["size"]
 	path, hasPath := params["path"]
@@ -335,12 +475,12 @@ func (cp *CommandParser) executeFdisk(params map[string]string) *CommandResult {
 		}
 	}
 
-	// Convertir size a número
-	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	// Convertir size a número (o porcentaje, o rest)
+	size, sizeMode, err := diskCommands.ParseFdiskSize(sizeStr)
 	if err != nil {
 		return &CommandResult{
 			Success: false,
-			Error:   fmt.Sprintf("El valor de -size debe ser un número válido: %v", err),
+			Error:   err.Error(),
 		}
 	}
 
@@ -349,8 +489,53 @@ func (cp *CommandParser) executeFdisk(params map[string]string) *CommandResult {
 	partType := params["type"]
 	fit := params["fit"]
 
+	placement := diskCommands.DefaultPlacement()
+	placement.SizeMode = sizeMode
+
+	if alignStr, hasAlign := params["align"]; hasAlign {
+		placement.Align, err = diskCommands.ParseAlignment(alignStr)
+		if err != nil {
+			return &CommandResult{
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+	}
+
+	if startStr, hasStart := params["start"]; hasStart {
+		placement.Start, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil || placement.Start < 0 {
+			return &CommandResult{
+				Success: false,
+				Error:   "El valor de -start debe ser un número entero no negativo",
+			}
+		}
+	}
+
+	// Una partición cifrada necesita su frase de contraseña
+	_, encrypt := params["encrypt"]
+	passphrase, hasPassphrase := params["passphrase"]
+	if encrypt && (!hasPassphrase || passphrase == "" || passphrase == "true") {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -passphrase es obligatorio con -encrypt",
+		}
+	}
+	if !encrypt && hasPassphrase {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -passphrase solo se usa con -encrypt",
+		}
+	}
+	if encrypt && strings.EqualFold(partType, "E") {
+		return &CommandResult{
+			Success: false,
+			Error:   "No se puede cifrar una partición extendida, cifre sus particiones lógicas",
+		}
+	}
+
 	// Ejecutar el comando
-	err = diskCommands.Fdisk(size, unit, path, partType, fit, name)
+	err = diskCommands.Fdisk(size, unit, path, partType, fit, name, placement)
 	if err != nil {
 		return &CommandResult{
 			Success: false,
@@ -358,25 +543,146 @@ func (cp *CommandParser) executeFdisk(params map[string]string) *CommandResult {
 		}
 	}
 
+	// Si no se puede cifrar, la partición recién creada se elimina
+	if encrypt {
+		if err := diskCommands.FdiskEncrypt(path, name, passphrase); err != nil {
+			diskCommands.FdiskDelete(path, name, "fast")
+			return &CommandResult{
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+	}
+
+	data := map[string]interface{}{
+		"name": name,
+		"path": path,
+		"size": size,
+		"unit": unit,
+		"type": partType,
+		"fit":  fit,
+	}
+	if sizeMode != diskCommands.SizeAbsolute {
+		data["size"] = sizeStr
+	}
+	if placement.Align > 1 {
+		data["align"] = placement.Align
+	}
+	if placement.Start >= 0 {
+		data["start"] = placement.Start
+	}
+	if encrypt {
+		data["encrypted"] = true
+	}
+
 	return &CommandResult{
 		Success: true,
 		Message: fmt.Sprintf("Partición '%s' creada exitosamente en %s", name, path),
+		Data:    data,
+	}
+}
+
+// executeFdiskDelete ejecuta el comando fdisk con el parámetro -delete
+func (cp *CommandParser) executeFdiskDelete(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+	name, hasName := params["name"]
+	mode := params["delete"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	if !hasName {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -name es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	err := diskCommands.FdiskDelete(path, name, mode)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Partición '%s' eliminada exitosamente de %s", name, path),
+		Data: map[string]interface{}{
+			"name":   name,
+			"path":   path,
+			"delete": strings.ToLower(mode),
+		},
+	}
+}
+
+// executeFdiskAdd ejecuta el comando fdisk con el parámetro -add
+func (cp *CommandParser) executeFdiskAdd(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	addStr := params["add"]
+	path, hasPath := params["path"]
+	name, hasName := params["name"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	if !hasName {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -name es obligatorio",
+		}
+	}
+
+	// Convertir add a número (puede ser negativo)
+	add, err := strconv.ParseInt(addStr, 10, 64)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("El valor de -add debe ser un número válido: %v", err),
+		}
+	}
+
+	// Parámetros opcionales
+	unit := params["unit"]
+
+	// Ejecutar el comando
+	err = diskCommands.FdiskAdd(add, unit, path, name)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Partición '%s' redimensionada exitosamente en %s", name, path),
 		Data: map[string]interface{}{
 			"name": name,
 			"path": path,
-			"size": size,
+			"add":  add,
 			"unit": unit,
-			"type": partType,
-			"fit":  fit,
 		},
 	}
 }
 
-// executeMount ejecuta el comando mount
-func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
+// executeFdiskMove ejecuta el comando fdisk con el parámetro -move
+func (cp *CommandParser) executeFdiskMove(params map[string]string) *CommandResult {
 	// Validar parámetros obligatorios
 	path, hasPath := params["path"]
 	name, hasName := params["name"]
+	startStr, hasStart := params["start"]
 
 	if !hasPath {
 		return &CommandResult{
@@ -392,8 +698,24 @@ func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
 		}
 	}
 
+	if !hasStart {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -start es obligatorio al usar -move",
+		}
+	}
+
+	// Convertir start a número
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("El valor de -start debe ser un número válido: %v", err),
+		}
+	}
+
 	// Ejecutar el comando
-	err := diskCommands.Mount(path, name)
+	err = diskCommands.FdiskMove(path, name, start)
 	if err != nil {
 		return &CommandResult{
 			Success: false,
@@ -401,18 +723,55 @@ func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
 		}
 	}
 
-	// Obtener información de la partición montada
-	mountedPartitions := diskCommands.GetMountedPartitions()
-	var mountedPartition *diskCommands.MountedPartition
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Partición '%s' movida exitosamente al byte %d en %s", name, start, path),
+		Data: map[string]interface{}{
+			"name":  name,
+			"path":  path,
+			"start": start,
+		},
+	}
+}
 
-	// Buscar la partición recién montada
-	for _, partition := range mountedPartitions {
-		if partition.Path == path && partition.Name == name {
-			mountedPartition = partition
-			break
+// executeMount ejecuta el comando mount
+func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
+	// Montar las particiones del archivo de montaje automático
+	if _, hasAll := params["all"]; hasAll {
+		report, err := diskCommands.MountAll()
+		return fstabCommandResult(report, err, "montaje")
+	}
+
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+	name, hasName := params["name"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	if !hasName {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -name es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando (una partición cifrada se desbloquea con -passphrase)
+	id, err := diskCommands.MountWithID(path, name, "", params["passphrase"])
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
 		}
 	}
 
+	// Obtener información de la partición recién montada
+	mountedPartition, _ := diskCommands.GetMountedPartitionByID(id)
+
 	var partitionData map[string]interface{}
 	if mountedPartition != nil {
 		partitionData = map[string]interface{}{
@@ -422,6 +781,7 @@ func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
 			"type":        mountedPartition.Type,
 			"size":        mountedPartition.Size,
 			"correlative": mountedPartition.Correlative,
+			"encrypted":   mountedPartition.Encrypted,
 		}
 	}
 
@@ -438,6 +798,12 @@ func (cp *CommandParser) executeMount(params map[string]string) *CommandResult {
 
 // executeUnmount ejecuta el comando unmount
 func (cp *CommandParser) executeUnmount(params map[string]string) *CommandResult {
+	// Desmontar las particiones del archivo de montaje automático
+	if _, hasAll := params["all"]; hasAll {
+		report, err := diskCommands.UnmountAll()
+		return fstabCommandResult(report, err, "desmontaje")
+	}
+
 	// Validar parámetros obligatorios
 	id, hasID := params["id"]
 
@@ -477,6 +843,36 @@ func (cp *CommandParser) executeUnmount(params map[string]string) *CommandResult
 	}
 }
 
+// fstabCommandResult arma el resultado de mount -all y unmount -all
+func fstabCommandResult(report *diskCommands.FstabReport, err error, action string) *CommandResult {
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	data := map[string]interface{}{
+		"file":    report.File,
+		"results": report.Results,
+		"failed":  report.Failed,
+	}
+
+	if report.Failed > 0 {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("%d de %d entradas de %s fallaron en el %s", report.Failed, len(report.Results), report.File, action),
+			Data:    data,
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Se aplicó el %s de %d entradas de %s", action, len(report.Results), report.File),
+		Data:    data,
+	}
+}
+
 // executeMounted ejecuta el comando mounted
 func (cp *CommandParser) executeMounted(params map[string]string) *CommandResult {
 	// Este comando no requiere parámetros
@@ -499,6 +895,764 @@ func (cp *CommandParser) executeMounted(params map[string]string) *CommandResult
 	}
 }
 
+// executeDefrag ejecuta el comando defrag
+func (cp *CommandParser) executeDefrag(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	moves, err := diskCommands.Defrag(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Disco %s compactado exitosamente (%d particiones movidas)", path, len(moves)),
+		Data: map[string]interface{}{
+			"path":  path,
+			"moves": moves,
+		},
+	}
+}
+
+// executeResizeDisk ejecuta el comando resizedisk
+func (cp *CommandParser) executeResizeDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	sizeStr, hasSizeParam := params["size"]
+	path, hasPath := params["path"]
+
+	if !hasSizeParam {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -size es obligatorio",
+		}
+	}
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Convertir size a número
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("El valor de -size debe ser un número válido: %v", err),
+		}
+	}
+
+	// Parámetros opcionales
+	unit := params["unit"]
+
+	// Ejecutar el comando
+	result, err := diskCommands.ResizeDisk(size, unit, path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Disco %s redimensionado de %d a %d bytes", path, result.OldSize, result.NewSize),
+		Data: map[string]interface{}{
+			"path":     result.Path,
+			"old_size": result.OldSize,
+			"new_size": result.NewSize,
+			"unit":     unit,
+		},
+	}
+}
+
+// executeCpDisk ejecuta el comando cpdisk
+func (cp *CommandParser) executeCpDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	src, hasSrc := params["src"]
+	dest, hasDest := params["dest"]
+
+	if !hasSrc {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -src es obligatorio",
+		}
+	}
+
+	if !hasDest {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -dest es obligatorio",
+		}
+	}
+
+	// Parámetros opcionales
+	name := params["name"]
+
+	// Ejecutar el comando
+	result, err := diskCommands.CpDisk(src, dest, name)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	message := fmt.Sprintf("Disco %s copiado exitosamente en %s", src, dest)
+	if result.Partition != "" {
+		message = fmt.Sprintf("Partición '%s' clonada exitosamente de %s a %s", result.Partition, src, dest)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"source":     result.Source,
+			"dest":       result.Dest,
+			"size":       result.Size,
+			"data_bytes": result.DataBytes,
+			"signature":  result.Signature,
+			"partition":  result.Partition,
+			"start":      result.Start,
+		},
+	}
+}
+
+// executeRepairMBR ejecuta el comando repairmbr
+func (cp *CommandParser) executeRepairMBR(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.RepairMBR(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	var repairs []string
+	switch result.Action {
+	case diskCommands.RepairMBRPrimaryRestored:
+		repairs = append(repairs, "MBR restaurado desde la copia de respaldo")
+	case diskCommands.RepairMBRBackupRewritten:
+		repairs = append(repairs, "copia de respaldo del MBR reescrita")
+	}
+	switch result.GPTAction {
+	case diskCommands.RepairGPTPrimaryRestored:
+		repairs = append(repairs, "tabla GPT principal restaurada desde la de respaldo")
+	case diskCommands.RepairGPTBackupRewritten:
+		repairs = append(repairs, "tabla GPT de respaldo reescrita")
+	}
+
+	message := fmt.Sprintf("El MBR de %s y su copia de respaldo son válidos", path)
+	if result.GPT {
+		message = fmt.Sprintf("El MBR de %s, su tabla GPT y sus copias de respaldo son válidos", path)
+	}
+	if len(repairs) > 0 {
+		message = fmt.Sprintf("Disco %s reparado: %s", path, strings.Join(repairs, ", "))
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path":              result.Path,
+			"action":            result.Action,
+			"primary_valid":     result.PrimaryValid,
+			"primary_error":     result.PrimaryError,
+			"backup_valid":      result.BackupValid,
+			"backup_error":      result.BackupError,
+			"backup_offset":     result.BackupOffset,
+			"gpt":               result.GPT,
+			"gpt_action":        result.GPTAction,
+			"gpt_primary_valid": result.GPTPrimaryValid,
+			"gpt_primary_error": result.GPTPrimaryError,
+			"gpt_backup_valid":  result.GPTBackupValid,
+			"gpt_backup_error":  result.GPTBackupError,
+		},
+	}
+}
+
+// executeCheckEBR ejecuta el comando checkebr
+func (cp *CommandParser) executeCheckEBR(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Parámetros opcionales
+	_, repair := params["repair"]
+
+	// Ejecutar el comando
+	result, err := diskCommands.CheckEBR(path, repair)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	message := fmt.Sprintf("La cadena de EBRs de %s es consistente", path)
+	if result.Repaired {
+		message = fmt.Sprintf("Cadena de EBRs de %s reconstruida (%d problemas corregidos, %d restantes)",
+			path, len(result.Issues), len(result.Remaining))
+	} else if len(result.Issues) > 0 {
+		message = fmt.Sprintf("Se encontraron %d problemas en la cadena de EBRs de %s", len(result.Issues), path)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path":           result.Path,
+			"extended_start": result.ExtendedStart,
+			"extended_end":   result.ExtendedEnd,
+			"logicals":       result.Logicals,
+			"issues":         result.Issues,
+			"repaired":       result.Repaired,
+			"dropped":        result.Dropped,
+			"remaining":      result.Remaining,
+		},
+	}
+}
+
+// executeCheckDisk ejecuta el comando checkdisk
+func (cp *CommandParser) executeCheckDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	report, err := diskCommands.CheckDisk(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	message := fmt.Sprintf("El disco %s no tiene errores (%d advertencias)", path, report.Warnings)
+	if !report.IsHealthy() {
+		message = fmt.Sprintf("El disco %s tiene %d errores y %d advertencias", path, report.Errors, report.Warnings)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path":       report.Path,
+			"size":       report.Size,
+			"table_type": report.TableType,
+			"healthy":    report.IsHealthy(),
+			"errors":     report.Errors,
+			"warnings":   report.Warnings,
+			"findings":   report.Findings,
+		},
+	}
+}
+
+// executeDiskInfo ejecuta el comando diskinfo
+func (cp *CommandParser) executeDiskInfo(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	stats, err := diskCommands.DiskInfo(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Información del disco %s", path),
+		Data:    stats,
+	}
+}
+
+// executeLsDisk ejecuta el comando lsdisk
+func (cp *CommandParser) executeLsDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.LsDisk(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("%d particiones en %s", len(result.Partitions), path),
+		Data: map[string]interface{}{
+			"path":       result.Path,
+			"size":       result.Size,
+			"table_type": result.TableType,
+			"usage":      result.Usage,
+			"partitions": result.Partitions,
+		},
+	}
+}
+
+// executeDiffDisk ejecuta el comando diffdisk
+func (cp *CommandParser) executeDiffDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path1, hasPath1 := params["path1"]
+	path2, hasPath2 := params["path2"]
+
+	if !hasPath1 {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path1 es obligatorio",
+		}
+	}
+
+	if !hasPath2 {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path2 es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.DiffDisk(path1, path2)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	message := fmt.Sprintf("Las tablas de particiones de %s y %s son iguales", path1, path2)
+	if !result.Equal {
+		message = fmt.Sprintf("Se encontraron %d diferencias entre %s y %s", len(result.Differences), path1, path2)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path1":       result.Path1,
+			"path2":       result.Path2,
+			"equal":       result.Equal,
+			"differences": result.Differences,
+		},
+	}
+}
+
+// executeWipe ejecuta el comando wipe
+func (cp *CommandParser) executeWipe(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.Wipe(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Contenido de %s llenado con ceros (%d bytes)", path, result.ZeroedBytes),
+		Data: map[string]interface{}{
+			"path":          result.Path,
+			"table_type":    result.TableType,
+			"partitions":    result.Partitions,
+			"zeroed_start":  result.ZeroedStart,
+			"zeroed_end":    result.ZeroedEnd,
+			"zeroed_bytes":  result.ZeroedBytes,
+			"backup_offset": result.BackupOffset,
+		},
+	}
+}
+
+// executeCleanDisk ejecuta el comando cleandisk
+func (cp *CommandParser) executeCleanDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.CleanDisk(path)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Se eliminaron %d particiones de %s", len(result.Removed), path),
+		Data: map[string]interface{}{
+			"path":       result.Path,
+			"table_type": result.TableType,
+			"removed":    result.Removed,
+			"free_space": result.FreeSpace,
+		},
+	}
+}
+
+// executeSnapshot ejecuta el comando snapshot
+func (cp *CommandParser) executeSnapshot(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Exactamente una acción
+	var actions []string
+	for _, action := range []string{diskCommands.SnapshotCreate, diskCommands.SnapshotList, diskCommands.SnapshotRestore, diskCommands.SnapshotDelete} {
+		if _, ok := params[action]; ok {
+			actions = append(actions, action)
+		}
+	}
+
+	if len(actions) != 1 {
+		return &CommandResult{
+			Success: false,
+			Error:   "Indique una acción: -create, -list, -restore o -delete",
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.Snapshot(path, actions[0], params["name"])
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	var message string
+	switch result.Action {
+	case diskCommands.SnapshotCreate:
+		message = fmt.Sprintf("Snapshot '%s' de %s creado", result.Name, path)
+	case diskCommands.SnapshotRestore:
+		message = fmt.Sprintf("Disco %s restaurado al snapshot '%s'", path, result.Name)
+	case diskCommands.SnapshotDelete:
+		message = fmt.Sprintf("Snapshot '%s' de %s eliminado", result.Name, path)
+	default:
+		message = fmt.Sprintf("%d snapshots en %s", len(result.Snapshots), path)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path":         result.Path,
+			"action":       result.Action,
+			"name":         result.Name,
+			"snapshots":    result.Snapshots,
+			"discarded":    result.Discarded,
+			"merged_bytes": result.MergedBytes,
+		},
+	}
+}
+
+// executeUndo ejecuta el comando undo
+func (cp *CommandParser) executeUndo(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Parámetros opcionales
+	_, list := params["list"]
+	count := 1
+	if countStr, hasCount := params["count"]; hasCount {
+		var err error
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count <= 0 {
+			return &CommandResult{
+				Success: false,
+				Error:   "El parámetro -count debe ser un número entero positivo",
+			}
+		}
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.Undo(path, count, list)
+	if result == nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	data := map[string]interface{}{
+		"path":      result.Path,
+		"undone":    result.Undone,
+		"unmounted": result.Unmounted,
+		"entries":   result.Entries,
+	}
+
+	// Se deshicieron algunos cambios antes de encontrar uno que no se puede deshacer
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("Se deshicieron %d cambios: %v", len(result.Undone), err),
+			Data:    data,
+		}
+	}
+
+	message := fmt.Sprintf("Se deshicieron %d cambios en %s", len(result.Undone), path)
+	if list {
+		message = fmt.Sprintf("%d cambios para deshacer en %s", len(result.Entries), path)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data:    data,
+	}
+}
+
+// executeConvertDisk ejecuta el comando convertdisk
+func (cp *CommandParser) executeConvertDisk(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+	format, hasFormat := params["format"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	if !hasFormat {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -format es obligatorio",
+		}
+	}
+
+	// La conversión es en el mismo archivo: un -dest ignorado convertiría el origen
+	if unknown := unknownParameter(params, "path", "format"); unknown != "" {
+		return &CommandResult{
+			Success: false,
+			Error:   fmt.Sprintf("Parámetro no reconocido para convertdisk: -%s", unknown),
+		}
+	}
+
+	// Ejecutar el comando
+	conversion, err := diskCommands.ConvertDisk(path, format)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: fmt.Sprintf("Disco %s convertido de %s a %s (%d → %d bytes)", path, conversion.From, conversion.To, conversion.OldFileSize, conversion.NewFileSize),
+		Data:    conversion,
+	}
+}
+
+// unknownParameter retorna el primer parámetro (en orden alfabético) que no está entre los permitidos, o ""
+func unknownParameter(params map[string]string, allowed ...string) string {
+	known := make(map[string]bool)
+	for _, name := range allowed {
+		known[name] = true
+	}
+
+	var unknown []string
+	for name := range params {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+
+	sort.Strings(unknown)
+	return unknown[0]
+}
+
+// executeScrub ejecuta el comando scrub
+func (cp *CommandParser) executeScrub(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Sin opciones se verifica el disco
+	_, enable := params[diskCommands.ScrubEnable]
+	_, disable := params[diskCommands.ScrubDisable]
+	if enable && disable {
+		return &CommandResult{
+			Success: false,
+			Error:   "Los parámetros -enable y -disable no se pueden usar juntos",
+		}
+	}
+
+	action := diskCommands.ScrubVerify
+	if enable {
+		action = diskCommands.ScrubEnable
+	} else if disable {
+		action = diskCommands.ScrubDisable
+	}
+
+	// Ejecutar el comando
+	result, err := diskCommands.Scrub(path, action)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	var message string
+	switch {
+	case action == diskCommands.ScrubEnable:
+		message = fmt.Sprintf("Sumas de verificación activadas en %s (%d regiones)", path, result.Checksum.Regions)
+	case action == diskCommands.ScrubDisable:
+		message = fmt.Sprintf("Sumas de verificación desactivadas en %s", path)
+	case result.Report.BadRegions == 0:
+		message = fmt.Sprintf("Las %d regiones de %s coinciden con sus sumas", result.Report.Regions, path)
+	default:
+		message = fmt.Sprintf("%d regiones de %s no coinciden con sus sumas (%d rangos)", result.Report.BadRegions, path, len(result.Report.BadRanges))
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data:    result,
+	}
+}
+
+// executeDiskMeta ejecuta el comando diskmeta
+func (cp *CommandParser) executeDiskMeta(params map[string]string) *CommandResult {
+	// Validar parámetros obligatorios
+	path, hasPath := params["path"]
+
+	if !hasPath {
+		return &CommandResult{
+			Success: false,
+			Error:   "El parámetro -path es obligatorio",
+		}
+	}
+
+	// Solo se cambian los metadatos indicados
+	changes := make(map[string]string)
+	for _, field := range []string{diskCommands.DiskMetaLabel, diskCommands.DiskMetaDescription, diskCommands.DiskMetaOwner} {
+		if value, ok := params[field]; ok {
+			changes[field] = value
+		}
+	}
+	_, clear := params["clear"]
+
+	// Ejecutar el comando
+	metadata, err := diskCommands.DiskMeta(path, changes, clear)
+	if err != nil {
+		return &CommandResult{
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	message := fmt.Sprintf("Metadatos del disco %s", path)
+	if len(changes) > 0 || clear {
+		message = fmt.Sprintf("Metadatos del disco %s actualizados", path)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Message: message,
+		Data: map[string]interface{}{
+			"path":     path,
+			"metadata": metadata,
+		},
+	}
+}
+
 // executeMkfs ejecuta el comando mkfs (placeholder por ahora)
 func (cp *CommandParser) executeMkfs(params map[string]string) *CommandResult {
 	utils.LogWarning("Parser", "Comando MKFS aún no implementado")
@@ -519,7 +1673,7 @@ func (cp *CommandParser) ExecuteScript(script string) []*CommandResult {
 		line = strings.TrimSpace(line)
 
 		// Agregar información de línea para debugging
-		utils.LogInfo("Parser", fmt.Sprintf("Línea %d: %s", i+1, line))
+		utils.LogInfo("Parser", fmt.Sprintf("Línea %d: %s", i+1, RedactCommandLine(line)))
 
 		result := cp.ParseAndExecute(line)
 
@@ -565,7 +1719,7 @@ func (cp *CommandParser) ValidateCommand(commandLine string) error {
 
 	// Validar que el comando existe
 	command := strings.ToLower(parts[0])
-	validCommands := []string{"mkdisk", "rmdisk", "fdisk", "mount", "unmount", "mkfs", "login", "logout"}
+	validCommands := []string{"mkdisk", "rmdisk", "fdisk", "mount", "unmount", "mkfs", "login", "logout", "defrag", "resizedisk", "cpdisk", "repairmbr", "checkebr", "checkdisk", "diskinfo", "lsdisk", "diffdisk", "wipe", "cleandisk", "snapshot", "undo", "convertdisk", "scrub", "diskmeta"}
 
 	found := false
 	for _, validCmd := range validCommands {
@@ -591,23 +1745,39 @@ func (cp *CommandParser) ValidateCommand(commandLine string) error {
 // GetSupportedCommands retorna la lista de comandos soportados
 func (cp *CommandParser) GetSupportedCommands() []string {
 	return []string{
-		"mkdisk",  // Crear disco
-		"rmdisk",  // Eliminar disco
-		"fdisk",   // Administrar particiones
-		"mount",   // Montar partición
-		"unmount", // Desmontar partición
-		"mounted", // Listar particiones montadas
-		"mkfs",    // Formatear partición
-		"login",   // Iniciar sesión
-		"logout",  // Cerrar sesión
-		"mkgrp",   // Crear grupo
-		"rmgrp",   // Eliminar grupo
-		"mkusr",   // Crear usuario
-		"rmusr",   // Eliminar usuario
-		"chgrp",   // Cambiar grupo
-		"mkfile",  // Crear archivo
-		"mkdir",   // Crear directorio
-		"cat",     // Mostrar contenido
-		"rep",     // Generar reportes
+		"mkdisk",      // Crear disco
+		"rmdisk",      // Eliminar disco
+		"fdisk",       // Administrar particiones
+		"mount",       // Montar partición
+		"unmount",     // Desmontar partición
+		"mounted",     // Listar particiones montadas
+		"mkfs",        // Formatear partición
+		"defrag",      // Compactar particiones
+		"resizedisk",  // Cambiar tamaño del disco
+		"cpdisk",      // Copiar disco o partición
+		"repairmbr",   // Reparar el MBR desde su copia de respaldo
+		"checkebr",    // Verificar y reparar la cadena de EBRs
+		"checkdisk",   // Verificar la integridad completa del disco
+		"diskinfo",    // Mostrar estadísticas del disco
+		"lsdisk",      // Listar particiones del disco
+		"diffdisk",    // Comparar las tablas de particiones de dos discos
+		"wipe",        // Llenar con ceros el contenido del disco
+		"cleandisk",   // Eliminar todas las particiones del disco
+		"snapshot",    // Administrar snapshots del disco
+		"undo",        // Deshacer cambios de fdisk, mount o mkfs
+		"convertdisk", // Cambiar el formato del archivo del disco (raw o qmia)
+		"scrub",       // Verificar las sumas de verificación del disco
+		"diskmeta",    // Etiqueta, descripción y dueño del disco
+		"login",       // Iniciar sesión
+		"logout",      // Cerrar sesión
+		"mkgrp",       // Crear grupo
+		"rmgrp",       // Eliminar grupo
+		"mkusr",       // Crear usuario
+		"rmusr",       // Eliminar usuario
+		"chgrp",       // Cambiar grupo
+		"mkfile",      // Crear archivo
+		"mkdir",       // Crear directorio
+		"cat",         // Mostrar contenido
+		"rep",         // Generar reportes
 	}
 }
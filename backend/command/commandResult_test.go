@@ -0,0 +1,588 @@
+package command
+
+import (
+	diskCommands "backend/command/disk"
+	estructuras "backend/struct"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain ejecuta las pruebas en un directorio temporal: los comandos escriben el log
+// de errores en ./logs
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "backend-command")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// execute ejecuta un comando y falla la prueba si no tuvo éxito
+func execute(t *testing.T, parser *CommandParser, commandLine string) *CommandResult {
+	t.Helper()
+	result := parser.ParseAndExecute(commandLine)
+	if !result.Success {
+		t.Fatalf("%s: %s", commandLine, result.Error)
+	}
+	return result
+}
+
+// mountedID retorna el ID que reportó un mount
+func mountedID(t *testing.T, result *CommandResult) string {
+	t.Helper()
+	data, _ := result.Data.(map[string]interface{})
+	partition, _ := data["partition"].(map[string]interface{})
+	id, _ := partition["id"].(string)
+	if id == "" {
+		t.Fatalf("mount no reportó el ID de la partición: %+v", result.Data)
+	}
+	return id
+}
+
+// TestDiskLifecycle crea, particiona, monta, desmonta y elimina un disco en memoria y uno en archivo
+func TestDiskLifecycle(t *testing.T) {
+	paths := map[string]string{
+		"memoria": "mem://ciclo",
+		"archivo": filepath.Join(t.TempDir(), "ciclo.mia"),
+	}
+
+	for name, path := range paths {
+		t.Run(name, func(t *testing.T) {
+			parser := NewCommandParser()
+
+			execute(t, parser, "mkdisk -size=5 -unit=M -path="+path)
+			execute(t, parser, "fdisk -size=1 -unit=M -name=p1 -path="+path)
+			execute(t, parser, "fdisk -size=2 -unit=M -type=E -name=ext -path="+path)
+			execute(t, parser, "fdisk -size=512 -unit=K -type=L -name=l1 -path="+path)
+
+			id := mountedID(t, execute(t, parser, "mount -name=p1 -path="+path))
+			if result := parser.ParseAndExecute("mount -name=p1 -path=" + path); result.Success {
+				t.Fatalf("se montó dos veces la partición p1")
+			}
+
+			if !diskCommands.IsPartitionMounted(path, "p1") {
+				t.Fatalf("la partición p1 no quedó montada")
+			}
+			if _, err := estructuras.ReadMBR(path); err != nil {
+				t.Fatalf("ReadMBR: %v", err)
+			}
+
+			execute(t, parser, "unmount -id="+id)
+			if diskCommands.IsPartitionMounted(path, "p1") {
+				t.Fatalf("la partición p1 sigue montada después de unmount")
+			}
+			execute(t, parser, "rmdisk -path="+path)
+
+			if _, err := estructuras.DiskSize(path); err == nil {
+				t.Fatalf("el disco %s sigue existiendo después de rmdisk", path)
+			}
+		})
+	}
+}
+
+// TestRmDiskMemoryMounted verifica que no se elimine un disco en memoria con particiones montadas
+func TestRmDiskMemoryMounted(t *testing.T) {
+	parser := NewCommandParser()
+	path := "mem://montado"
+
+	execute(t, parser, "mkdisk -size=2 -unit=M -path="+path)
+	execute(t, parser, "fdisk -size=1 -unit=M -name=p1 -path="+path)
+	id := mountedID(t, execute(t, parser, "mount -name=p1 -path="+path))
+
+	if result := parser.ParseAndExecute("rmdisk -path=" + path); result.Success {
+		t.Fatalf("rmdisk eliminó un disco en memoria con la partición %s montada", id)
+	}
+
+	execute(t, parser, "unmount -id="+id)
+	execute(t, parser, "rmdisk -path="+path)
+}
+
+// TestUndoAfterUnjournaledRewrite verifica que undo no reescriba el MBR sobre cambios de
+// comandos que no se registran en el diario
+func TestUndoAfterUnjournaledRewrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string // Comandos después de mkdisk -size=10 y fdisk de p1
+		undoable int      // Cambios que undo todavía puede deshacer
+	}{
+		{"resizedisk", []string{"resizedisk -size=20 -unit=M"}, 0},
+		{"resizedisk y fdisk", []string{"resizedisk -size=20 -unit=M", "fdisk -size=1 -unit=M -name=p2"}, 1},
+		{"resizedisk, fdisk y defrag", []string{"resizedisk -size=20 -unit=M", "fdisk -size=1 -unit=M -name=p2", "defrag"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewCommandParser()
+			path := filepath.Join(t.TempDir(), "deshacer.mia")
+
+			execute(t, parser, "mkdisk -size=10 -unit=M -path="+path)
+			execute(t, parser, "fdisk -size=1 -unit=M -name=p1 -path="+path)
+			for _, command := range test.commands {
+				execute(t, parser, command+" -path="+path)
+			}
+
+			undone := 0
+			for i := 0; i < 2; i++ {
+				if parser.ParseAndExecute("undo -path=" + path).Success {
+					undone++
+				}
+			}
+			if undone != test.undoable {
+				t.Fatalf("undo deshizo %d cambios, se esperaban %d", undone, test.undoable)
+			}
+
+			report, err := estructuras.CheckDisk(path)
+			if err != nil {
+				t.Fatalf("CheckDisk: %v", err)
+			}
+			for _, finding := range report.Findings {
+				if finding.Severity != estructuras.SeverityInfo {
+					t.Errorf("hallazgo después de undo: [%s] %s", finding.Code, finding.Message)
+				}
+			}
+
+			mbr, err := estructuras.ReadMBR(path)
+			if err != nil {
+				t.Fatalf("ReadMBR: %v", err)
+			}
+			if mbr.MbrTamanio != 20*1024*1024 {
+				t.Fatalf("el MBR indica %d bytes, el disco tiene %d", mbr.MbrTamanio, 20*1024*1024)
+			}
+		})
+	}
+}
+
+// TestUndoDetectsChangedMBR verifica que undo se detenga si el MBR cambió después del comando
+func TestUndoDetectsChangedMBR(t *testing.T) {
+	parser := NewCommandParser()
+	path := filepath.Join(t.TempDir(), "cambiado.mia")
+
+	execute(t, parser, "mkdisk -size=5 -unit=M -path="+path)
+	execute(t, parser, "fdisk -size=1 -unit=M -name=p1 -path="+path)
+
+	// Un MBR válido distinto, escrito fuera del diario
+	mbr, err := estructuras.ReadMBR(path)
+	if err != nil {
+		t.Fatalf("ReadMBR: %v", err)
+	}
+	mbr.ResetSignature()
+	if err := estructuras.WritePartitionTable(path, mbr); err != nil {
+		t.Fatalf("WritePartitionTable: %v", err)
+	}
+
+	if result := parser.ParseAndExecute("undo -path=" + path); result.Success {
+		t.Fatalf("undo reescribió un MBR modificado después del comando")
+	}
+
+	after, err := estructuras.ReadMBR(path)
+	if err != nil {
+		t.Fatalf("ReadMBR: %v", err)
+	}
+	if after.MbrDiskSignature != mbr.MbrDiskSignature || after.GetParticionByName("p1") == nil {
+		t.Fatalf("undo modificó el MBR")
+	}
+}
+
+// TestRedactCommandLine verifica que se oculte todo el valor que parseParameters toma para -passphrase
+func TestRedactCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"mount -name=p1 -path=/d.mia", "mount -name=p1 -path=/d.mia"},
+		{"mount -name=p1 -passphrase=secreto -path=/d.mia", "mount -name=p1 -passphrase=*** -path=/d.mia"},
+		{"mount -name=p1 -passphrase=mi frase secreta -path=/d.mia", "mount -name=p1 -passphrase=*** -path=/d.mia"},
+		{"mount -path=/d.mia -name=p1 -passphrase=mi frase secreta", "mount -path=/d.mia -name=p1 -passphrase=***"},
+		{`mount -passphrase="mi frase" secreta -name=p1`, "mount -passphrase=*** -name=p1"},
+		{`mount -passphrase=mi "frase -secreta" -name=p1`, "mount -passphrase=*** -name=p1"},
+		{"mount -PASSPHRASE=mi frase -encrypt -name=p1", "mount -PASSPHRASE=*** -encrypt -name=p1"},
+		{"mount -passphrase=mi frase -passphrase=otra frase", "mount -passphrase=*** -passphrase=***"},
+	}
+
+	for _, test := range tests {
+		if got := RedactCommandLine(test.line); got != test.want {
+			t.Errorf("RedactCommandLine(%q) = %q, se esperaba %q", test.line, got, test.want)
+		}
+	}
+}
+
+// TestUndoJournalRedactsPassphrase verifica que el diario de deshacer no guarde la frase de contraseña
+func TestUndoJournalRedactsPassphrase(t *testing.T) {
+	parser := NewCommandParser()
+	path := filepath.Join(t.TempDir(), "cifrado.mia")
+
+	execute(t, parser, "mkdisk -size=5 -unit=M -path="+path)
+	execute(t, parser, "fdisk -size=1 -unit=M -name=c1 -encrypt -passphrase=mi frase muy secreta -path="+path)
+
+	entries, err := estructuras.ListUndo(path)
+	if err != nil {
+		t.Fatalf("ListUndo: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatalf("fdisk no registró cambios en el diario")
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Command, "frase") || strings.Contains(entry.Command, "secreta") {
+			t.Fatalf("el diario guarda la frase de contraseña: %s", entry.Command)
+		}
+	}
+}
+
+// TestConvertDiskUnknownParameter verifica que convertdisk no ignore parámetros desconocidos
+func TestConvertDiskUnknownParameter(t *testing.T) {
+	parser := NewCommandParser()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "origen.mia")
+
+	execute(t, parser, "mkdisk -size=2 -unit=M -path="+path)
+	if result := parser.ParseAndExecute("convertdisk -path=" + path + " -dest=" + filepath.Join(dir, "destino.mia") + " -format=qmia"); result.Success {
+		t.Fatalf("convertdisk aceptó el parámetro -dest")
+	}
+
+	if format, err := estructuras.DiskFormat(path); err != nil || format != estructuras.DISK_FORMAT_RAW {
+		t.Fatalf("convertdisk modificó el disco origen: formato %s (%v)", format, err)
+	}
+}
+
+// TestScrubMissingDisk verifica que scrub reporte que el disco no existe antes que la falta de sumas
+func TestScrubMissingDisk(t *testing.T) {
+	parser := NewCommandParser()
+	path := filepath.Join(t.TempDir(), "inexistente.mia")
+
+	for _, flag := range []string{"", " -enable", " -disable"} {
+		result := parser.ParseAndExecute("scrub -path=" + path + flag)
+		if result.Success || !strings.Contains(result.Error, "no existe") {
+			t.Errorf("scrub%s sobre un disco inexistente: %q", flag, result.Error)
+		}
+	}
+}
+
+// TestRestoreMountSystemSharedSignature verifica que una firma repetida solo sea un conflicto
+// si los dos discos tienen montajes guardados
+func TestRestoreMountSystemSharedSignature(t *testing.T) {
+	tests := []struct {
+		name         string
+		copyMounted  bool // La copia se hace después de montar, con el montaje guardado
+		wantConflict bool
+	}{
+		{"copia sin montajes", false, false},
+		{"copia con montajes", true, true},
+	}
+
+	diskCommands.SetPersistentMounts(true)
+	defer diskCommands.SetPersistentMounts(false)
+	defer diskCommands.ClearMountSystem()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewCommandParser()
+			dir := t.TempDir()
+			original := filepath.Join(dir, "a.mia")
+			copyPath := filepath.Join(dir, "b.mia")
+
+			copyDisk := func() {
+				data, err := os.ReadFile(original)
+				if err != nil {
+					t.Fatalf("ReadFile: %v", err)
+				}
+				if err := os.WriteFile(copyPath, data, 0644); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+			}
+
+			execute(t, parser, "mkdisk -size=2 -unit=M -path="+original)
+			execute(t, parser, "fdisk -size=1 -unit=M -name=p1 -path="+original)
+			if !test.copyMounted {
+				copyDisk()
+			}
+			id := mountedID(t, execute(t, parser, "mount -name=p1 -path="+original))
+			if test.copyMounted {
+				copyDisk()
+			}
+
+			// Reiniciar el sistema de montaje como al arrancar el backend
+			diskCommands.ClearMountSystem()
+			report, err := diskCommands.RestoreMountSystem(dir)
+			if err != nil {
+				t.Fatalf("RestoreMountSystem: %v", err)
+			}
+
+			if hasConflict := len(report.Conflicts) > 0; hasConflict != test.wantConflict {
+				t.Fatalf("conflictos: %v, se esperaba conflicto: %t", report.Conflicts, test.wantConflict)
+			}
+			if len(report.Restored) != 1 || report.Restored[0] != id {
+				t.Fatalf("montajes restaurados: %v, se esperaba %s", report.Restored, id)
+			}
+		})
+	}
+}
+
+// partitionLayout retorna las particiones del disco por nombre
+func partitionLayout(t *testing.T, path string) map[string]diskCommands.LsDiskEntry {
+	t.Helper()
+	listing, err := diskCommands.LsDisk(path)
+	if err != nil {
+		t.Fatalf("LsDisk: %v", err)
+	}
+
+	layout := make(map[string]diskCommands.LsDiskEntry)
+	for _, entry := range listing.Partitions {
+		entry.Mounted, entry.ID = false, ""
+		layout[entry.Name] = entry
+	}
+	return layout
+}
+
+// checkHealthy falla la prueba si checkdisk encuentra errores o advertencias en el disco
+func checkHealthy(t *testing.T, path string) {
+	t.Helper()
+	report, err := estructuras.CheckDisk(path)
+	if err != nil {
+		t.Fatalf("CheckDisk: %v", err)
+	}
+	for _, finding := range report.Findings {
+		if finding.Severity != estructuras.SeverityInfo {
+			t.Errorf("checkdisk: [%s] %s", finding.Code, finding.Message)
+		}
+	}
+}
+
+// corruptDisk sobrescribe bytes del disco; un offset negativo se cuenta desde el final
+func corruptDisk(t *testing.T, path string, offset int64) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer file.Close()
+
+	if offset < 0 {
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		offset += info.Size()
+	}
+	if _, err := file.WriteAt([]byte("XXXX"), offset); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+}
+
+// TestFdiskEditAndUndo modifica las particiones de un disco con fdisk -delete, -add y -move
+// (y con comandos que no usan el diario) y verifica el resultado de undo
+func TestFdiskEditAndUndo(t *testing.T) {
+	const (
+		K = int64(1024)
+		M = 1024 * K
+	)
+
+	// Disco de 10 MiB: p1 [1M, 2M), ext [3M, 7M) con l1 y l2 de 512K, p2 [8M, 9M)
+	setup := []string{
+		"mkdisk -size=10 -unit=M",
+		"fdisk -size=1 -unit=M -name=p1 -start=1048576",
+		"fdisk -size=4 -unit=M -type=E -name=ext -start=3145728",
+		"fdisk -size=512 -unit=K -type=L -name=l1",
+		"fdisk -size=512 -unit=K -type=L -name=l2",
+		"fdisk -size=1 -unit=M -name=p2 -start=8388608",
+	}
+
+	type layout = map[string]diskCommands.LsDiskEntry
+	tests := []struct {
+		name     string
+		command  string
+		wantErr  bool
+		undoable bool // undo restaura la tabla anterior al comando
+		check    func(t *testing.T, before, after layout)
+	}{
+		{
+			name: "delete primaria", command: "fdisk -delete=fast -name=p1", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if _, exists := after["p1"]; exists || len(after) != len(before)-1 {
+					t.Errorf("p1 no se eliminó: %v", after)
+				}
+			},
+		},
+		{
+			name: "delete lógica", command: "fdisk -delete=full -name=l1", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if _, exists := after["l1"]; exists || after["l2"] != before["l2"] {
+					t.Errorf("se esperaba solo l2 en la extendida: %v", after)
+				}
+			},
+		},
+		{
+			name: "delete extendida", command: "fdisk -delete=fast -name=ext", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				for _, name := range []string{"ext", "l1", "l2"} {
+					if _, exists := after[name]; exists {
+						t.Errorf("'%s' sigue en el disco después de eliminar la extendida", name)
+					}
+				}
+			},
+		},
+		{
+			name: "add positivo", command: "fdisk -add=512 -unit=K -name=p1", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if after["p1"].Size != before["p1"].Size+512*K || after["p1"].Start != before["p1"].Start {
+					t.Errorf("p1 tiene %d bytes desde el byte %d", after["p1"].Size, after["p1"].Start)
+				}
+			},
+		},
+		{
+			name: "add negativo", command: "fdisk -add=-512 -unit=K -name=p2", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if after["p2"].Size != 512*K {
+					t.Errorf("p2 tiene %d bytes, se esperaban %d", after["p2"].Size, 512*K)
+				}
+			},
+		},
+		{name: "add sin espacio libre", command: "fdisk -add=2 -unit=M -name=p1", wantErr: true},
+		{
+			name: "move primaria", command: "fdisk -move -name=p1 -start=2097152", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if after["p1"].Start != 2*M || after["p1"].Size != before["p1"].Size {
+					t.Errorf("p1 inicia en el byte %d, se esperaba %d", after["p1"].Start, 2*M)
+				}
+			},
+		},
+		{
+			name: "move lógica", command: "fdisk -move -name=l1 -start=6815744", undoable: true,
+			check: func(t *testing.T, before, after layout) {
+				if after["l1"].Start != 7*M-512*K || after["l2"] != before["l2"] {
+					t.Errorf("l1 inicia en el byte %d, se esperaba %d", after["l1"].Start, 7*M-512*K)
+				}
+			},
+		},
+		{name: "move lógica fuera de la extendida", command: "fdisk -move -name=l1 -start=1048576", wantErr: true},
+		{name: "move primaria sobre otra", command: "fdisk -move -name=p1 -start=8388608", wantErr: true},
+		{
+			name: "resizedisk", command: "resizedisk -size=12 -unit=M",
+			check: func(t *testing.T, before, after layout) {
+				for name, entry := range before {
+					if after[name] != entry {
+						t.Errorf("resizedisk movió '%s'", name)
+					}
+				}
+			},
+		},
+		{
+			name: "defrag", command: "defrag",
+			check: func(t *testing.T, before, after layout) {
+				if after["p1"].Start >= before["p1"].Start || after["p2"].Start >= before["p2"].Start {
+					t.Errorf("defrag no compactó las particiones: %v", after)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewCommandParser()
+			path := filepath.Join(t.TempDir(), "editar.mia")
+			for _, command := range setup {
+				execute(t, parser, command+" -path="+path)
+			}
+			before := partitionLayout(t, path)
+
+			result := parser.ParseAndExecute(test.command + " -path=" + path)
+			if test.wantErr {
+				if result.Success {
+					t.Fatalf("%s no falló", test.command)
+				}
+				if after := partitionLayout(t, path); len(after) != len(before) {
+					t.Fatalf("%s falló pero cambió las particiones: %v", test.command, after)
+				}
+				checkHealthy(t, path)
+				return
+			}
+			if !result.Success {
+				t.Fatalf("%s: %s", test.command, result.Error)
+			}
+
+			after := partitionLayout(t, path)
+			test.check(t, before, after)
+			checkHealthy(t, path)
+
+			// Undo deshace el comando, o se rechaza si el comando no usa el diario
+			undo := parser.ParseAndExecute("undo -path=" + path)
+			if undo.Success != test.undoable {
+				t.Fatalf("undo después de %s: éxito %t, se esperaba %t (%s)", test.command, undo.Success, test.undoable, undo.Error)
+			}
+			want := after
+			if test.undoable {
+				want = before
+			}
+			restored := partitionLayout(t, path)
+			if len(restored) != len(want) {
+				t.Fatalf("después de undo: %v, se esperaba %v", restored, want)
+			}
+			for name, entry := range want {
+				if restored[name] != entry {
+					t.Errorf("después de undo '%s' es %+v, se esperaba %+v", name, restored[name], entry)
+				}
+			}
+			checkHealthy(t, path)
+		})
+	}
+}
+
+// TestRepairMBRGPT daña las copias de la tabla de un disco GPT y verifica la reparación
+func TestRepairMBRGPT(t *testing.T) {
+	tests := []struct {
+		name          string
+		corrupt       []int64 // Offsets a sobrescribir; negativos desde el final del disco
+		wantErr       bool
+		wantAction    string
+		wantGPTAction string
+	}{
+		{"intacto", nil, false, diskCommands.RepairMBRNone, diskCommands.RepairMBRNone},
+		{"cabecera GPT principal", []int64{540}, false, diskCommands.RepairMBRNone, diskCommands.RepairGPTPrimaryRestored},
+		{"entradas GPT principales", []int64{1034}, false, diskCommands.RepairMBRNone, diskCommands.RepairGPTPrimaryRestored},
+		{"cabecera GPT de respaldo", []int64{-480}, false, diskCommands.RepairMBRNone, diskCommands.RepairGPTBackupRewritten},
+		{"MBR protector", []int64{10}, false, diskCommands.RepairMBRPrimaryRestored, diskCommands.RepairMBRNone},
+		{"MBR protector y cabecera GPT principal", []int64{10, 540}, false, diskCommands.RepairMBRPrimaryRestored, diskCommands.RepairGPTPrimaryRestored},
+		{"ambas cabeceras GPT", []int64{540, -480}, true, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewCommandParser()
+			path := filepath.Join(t.TempDir(), "gpt.mia")
+
+			execute(t, parser, "mkdisk -size=5 -unit=M -table=GPT -path="+path)
+			execute(t, parser, "fdisk -size=1 -unit=M -name=g1 -path="+path)
+			for _, offset := range test.corrupt {
+				corruptDisk(t, path, offset)
+			}
+
+			result := parser.ParseAndExecute("repairmbr -path=" + path)
+			if test.wantErr {
+				if result.Success {
+					t.Fatalf("repairmbr reparó un disco sin copias válidas de la tabla GPT")
+				}
+				return
+			}
+			if !result.Success {
+				t.Fatalf("repairmbr: %s", result.Error)
+			}
+
+			data, _ := result.Data.(map[string]interface{})
+			if data["action"] != test.wantAction || data["gpt_action"] != test.wantGPTAction {
+				t.Fatalf("repairmbr: action=%v gpt_action=%v, se esperaba %s y %s", data["action"], data["gpt_action"], test.wantAction, test.wantGPTAction)
+			}
+
+			checkHealthy(t, path)
+			if _, exists := partitionLayout(t, path)["g1"]; !exists {
+				t.Fatalf("la partición g1 no está en el disco reparado")
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package disk
+
+/*
+ * CHECKDISK - Este comando verifica la integridad completa de un disco.
+ * Revisa el MBR y su copia de respaldo (o la tabla GPT), las particiones de la
+ * tabla, la cadena de EBRs de la extendida y el superbloque EXT2 de las
+ * particiones formateadas. No se detiene en el primer problema: reporta todos
+ * los hallazgos con su severidad, código y posición. No modifica el disco.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                      |
+|-----------|--------------|--------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco que se va a verificar.            |
+*/
+
+// CheckDisk verifica el disco y retorna todos los hallazgos
+func CheckDisk(path string) (*estructuras.DiskCheckReport, error) {
+	utils.LogInfo("CHECKDISK", fmt.Sprintf("Verificando disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("CHECKDISK", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		utils.LogError("CHECKDISK", err.Error())
+		return nil, err
+	}
+	defer device.Close()
+
+	report, err := estructuras.CheckDiskOnDevice(device)
+	if err != nil {
+		utils.LogError("CHECKDISK", err.Error())
+		return nil, err
+	}
+
+	for _, finding := range report.Findings {
+		message := fmt.Sprintf("[%s] byte %d: %s", finding.Code, finding.Offset, finding.Message)
+		switch finding.Severity {
+		case estructuras.SeverityError:
+			utils.LogError("CHECKDISK", message)
+		case estructuras.SeverityWarning:
+			utils.LogWarning("CHECKDISK", message)
+		default:
+			utils.LogInfo("CHECKDISK", message)
+		}
+	}
+
+	if report.IsHealthy() {
+		utils.LogSuccess("CHECKDISK", fmt.Sprintf("El disco no tiene errores (%d advertencias)", report.Warnings))
+	} else {
+		utils.LogWarning("CHECKDISK", fmt.Sprintf("El disco tiene %d errores y %d advertencias", report.Errors, report.Warnings))
+	}
+
+	return report, nil
+}
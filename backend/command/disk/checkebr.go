@@ -0,0 +1,173 @@
+package disk
+
+/*
+ * CHECKEBR - Este comando verifica la cadena de EBRs de la partición extendida.
+ * Detecta ciclos, EBRs que apuntan fuera de la extendida, lógicas traslapadas,
+ * enlaces desordenados y EBRs huérfanos. Con -repair reconstruye una cadena
+ * ordenada y consistente a partir de las lógicas válidas de la cadena y de las
+ * encontradas al recorrer el espacio de la extendida.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"sort"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                  |
+|-----------|--------------|----------------------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco cuya cadena de EBRs se va a verificar.                                        |
+| -repair   | Opcional     | Reconstruye la cadena. Ninguna partición del disco puede estar montada.                      |
+*/
+
+// CheckEBRResult es el resultado del comando checkebr
+type CheckEBRResult struct {
+	Path          string                 `json:"path"`           // Ruta del disco
+	ExtendedStart int64                  `json:"extended_start"` // Inicio de la partición extendida
+	ExtendedEnd   int64                  `json:"extended_end"`   // Fin (exclusivo) de la partición extendida
+	Logicals      int                    `json:"logicals"`       // Lógicas válidas encontradas (cadena y huérfanas)
+	Issues        []estructuras.EBRIssue `json:"issues"`         // Problemas encontrados
+	Repaired      bool                   `json:"repaired"`       // La cadena se reconstruyó
+	Dropped       []string               `json:"dropped"`        // Lógicas descartadas al reconstruir (traslapadas o repetidas)
+	Remaining     []estructuras.EBRIssue `json:"remaining"`      // Problemas que quedan después de reparar
+}
+
+// CheckEBR verifica (y opcionalmente repara) la cadena de EBRs de un disco
+func CheckEBR(path string, repair bool) (*CheckEBRResult, error) {
+	utils.LogInfo("CHECKEBR", fmt.Sprintf("Verificando cadena de EBRs: path=%s, repair=%t", path, repair))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("CHECKEBR", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	// El disco se abre una sola vez para leer la tabla y la cadena de EBRs
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+	defer device.Close()
+
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	extendedPartition := mbr.GetParticionExtendida()
+	if extendedPartition == nil {
+		utils.LogError("CHECKEBR", "El disco no tiene partición extendida")
+		return nil, fmt.Errorf("el disco no tiene partición extendida")
+	}
+
+	report, err := estructuras.CheckEBRChainOnDevice(device, extendedPartition)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CheckEBRResult{
+		Path:          path,
+		ExtendedStart: report.ExtendedStart,
+		ExtendedEnd:   report.ExtendedEnd,
+		Logicals:      len(report.Logicals) + len(report.Orphans),
+		Issues:        report.Issues,
+		Dropped:       []string{},
+		Remaining:     []estructuras.EBRIssue{},
+	}
+
+	for _, issue := range report.Issues {
+		utils.LogWarning("CHECKEBR", fmt.Sprintf("[%s] %s", issue.Code, issue.Message))
+	}
+
+	if report.IsConsistent() {
+		utils.LogSuccess("CHECKEBR", fmt.Sprintf("La cadena de EBRs es consistente (%d lógicas)", result.Logicals))
+		return result, nil
+	}
+
+	if !repair {
+		utils.LogWarning("CHECKEBR", fmt.Sprintf("Se encontraron %d problemas, use -repair para reconstruir la cadena", len(report.Issues)))
+		return result, nil
+	}
+
+	// Los IDs de las lógicas montadas dependen de la posición de su EBR
+	if HasMountedPartitions(path) {
+		utils.LogError("CHECKEBR", "El disco tiene particiones montadas, desmóntelas antes de reparar")
+		return nil, fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de reparar la cadena de EBRs")
+	}
+
+	// La reconstrucción no se registra en el diario de deshacer
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return nil, err
+	}
+
+	dropped, err := rebuildEBRChain(path, report)
+	if err != nil {
+		return nil, err
+	}
+	result.Repaired = true
+	result.Dropped = dropped
+
+	// Verificar la cadena reconstruida
+	after, err := estructuras.CheckEBRChainOnDevice(device, extendedPartition)
+	if err != nil {
+		return nil, err
+	}
+	result.Logicals = len(after.Logicals) + len(after.Orphans)
+	result.Remaining = after.Issues
+
+	utils.LogSuccess("CHECKEBR", "Cadena de EBRs reconstruida:")
+	utils.LogSuccess("CHECKEBR", fmt.Sprintf("  → Lógicas enlazadas: %d", len(after.Logicals)))
+	utils.LogSuccess("CHECKEBR", fmt.Sprintf("  → Lógicas descartadas: %d", len(dropped)))
+	utils.LogSuccess("CHECKEBR", fmt.Sprintf("  → Problemas restantes: %d", len(after.Issues)))
+
+	return result, nil
+}
+
+// rebuildEBRChain enlaza en orden las lógicas válidas (de la cadena y huérfanas), descartando las traslapadas
+func rebuildEBRChain(path string, report *estructuras.EBRCheckReport) ([]string, error) {
+	candidates := append(append([]estructuras.EBRNode(nil), report.Logicals...), report.Orphans...)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Position < candidates[j].Position
+	})
+
+	// El primer EBR siempre está al inicio de la extendida; si no es una lógica válida queda vacío
+	head := estructuras.EBRNode{Position: report.ExtendedStart, EBR: estructuras.NewEmptyEBR()}
+	usedEnd := report.ExtendedStart + int64(estructuras.EBR_SIZE)
+
+	var logicals []estructuras.EBRNode
+	dropped := []string{}
+	names := make(map[string]bool)
+
+	for _, node := range candidates {
+		name := node.EBR.GetName()
+		if node.Position == report.ExtendedStart {
+			head = node
+			usedEnd = node.EBR.GetEndPosition()
+			names[name] = true
+			continue
+		}
+
+		if node.Position < usedEnd {
+			utils.LogWarning("CHECKEBR", fmt.Sprintf("Se descarta la lógica '%s' en %d: se superpone con otra lógica", name, node.Position))
+			dropped = append(dropped, name)
+			continue
+		}
+		if names[name] {
+			utils.LogWarning("CHECKEBR", fmt.Sprintf("Se descarta la lógica '%s' en %d: el nombre está repetido", name, node.Position))
+			dropped = append(dropped, name)
+			continue
+		}
+
+		logicals = append(logicals, node)
+		usedEnd = node.EBR.GetEndPosition()
+		names[name] = true
+	}
+
+	if err := writeSortedEBRChain(path, head, logicals); err != nil {
+		return nil, err
+	}
+
+	return dropped, nil
+}
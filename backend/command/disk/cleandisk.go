@@ -0,0 +1,79 @@
+package disk
+
+/*
+ * CLEANDISK - Este comando elimina todas las particiones de un disco. Se
+ * escribe una tabla vacía del mismo tipo (MBR o GPT) conservando el tamaño,
+ * la firma y el ajuste del disco. El contenido de las particiones no se borra
+ * (para eso existe wipe).
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                 |
+|-----------|--------------|-----------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco a limpiar. Ninguna partición del disco puede estar montada.  |
+*/
+
+// CleanDiskResult es el resultado del comando cleandisk
+type CleanDiskResult struct {
+	Path      string   `json:"path"`       // Ruta del disco
+	TableType string   `json:"table_type"` // Tipo de tabla de particiones
+	Removed   []string `json:"removed"`    // Particiones eliminadas (primarias, extendidas y lógicas)
+	FreeSpace int64    `json:"free_space"` // Espacio libre después de limpiar
+}
+
+// CleanDisk elimina todas las particiones del disco
+func CleanDisk(path string) (*CleanDiskResult, error) {
+	utils.LogInfo("CLEANDISK", fmt.Sprintf("Eliminando particiones del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("CLEANDISK", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	// Los IDs montados quedarían apuntando a particiones que ya no existen
+	if HasMountedPartitions(path) {
+		utils.LogError("CLEANDISK", "El disco tiene particiones montadas, desmóntelas antes de limpiarlo")
+		return nil, fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de limpiarlo")
+	}
+
+	// Listar lo que se va a eliminar
+	listing, err := LsDisk(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// La limpieza no se registra en el diario de deshacer
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return nil, err
+	}
+
+	if err := estructuras.CleanDisk(path); err != nil {
+		utils.LogError("CLEANDISK", err.Error())
+		return nil, err
+	}
+
+	info, err := estructuras.GetDiskInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CleanDiskResult{
+		Path:      path,
+		TableType: info.TableType,
+		Removed:   []string{},
+		FreeSpace: info.FreeSpace,
+	}
+	for _, entry := range listing.Partitions {
+		result.Removed = append(result.Removed, entry.Name)
+	}
+
+	utils.LogSuccess("CLEANDISK", fmt.Sprintf("Se eliminaron %d particiones de %s", len(result.Removed), path))
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package disk
+
+/*
+ * CONVERTDISK - Este comando cambia el formato del archivo de un disco entre
+ * raw (el archivo tiene todos los bytes del disco) y qmia (contenedor con
+ * clusters comprimidos, ver mkdisk -format). El contenido del disco no cambia:
+ * las particiones, los montajes y el diario de deshacer siguen siendo válidos.
+ * Convertir un disco qmia a qmia lo compacta. La conversión se hace sobre el
+ * mismo archivo, así que se rechaza cualquier parámetro que no esté en la tabla.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"strings"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                                  |
+|-----------|--------------|--------------------------------------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco a convertir. No puede tener snapshots ni ser un disco en memoria.                            |
+| -format   | Obligatorio  | Formato nuevo. Valores: RAW (archivo con el tamaño completo del disco), QMIA (contenedor comprimido).        |
+*/
+
+// ConvertDisk cambia el formato del archivo de un disco
+func ConvertDisk(path, format string) (*estructuras.DiskConversion, error) {
+	utils.LogInfo("CONVERTDISK", fmt.Sprintf("Convirtiendo disco: path=%s, format=%s", path, format))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("CONVERTDISK", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != estructuras.DISK_FORMAT_RAW && format != estructuras.DISK_FORMAT_QMIA {
+		utils.LogError("CONVERTDISK", fmt.Sprintf("Formato no válido '%s', use RAW o QMIA", format))
+		return nil, fmt.Errorf("formato no válido '%s', use RAW o QMIA", format)
+	}
+
+	// Validar que el disco existe y es válido antes de reescribirlo
+	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+		utils.LogError("CONVERTDISK", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+
+	conversion, err := estructuras.ConvertDisk(path, format)
+	if err != nil {
+		utils.LogError("CONVERTDISK", err.Error())
+		return nil, err
+	}
+
+	utils.LogSuccess("CONVERTDISK", "Disco convertido exitosamente:")
+	utils.LogSuccess("CONVERTDISK", fmt.Sprintf("  → Ruta: %s", path))
+	utils.LogSuccess("CONVERTDISK", fmt.Sprintf("  → Formato: %s → %s", conversion.From, conversion.To))
+	utils.LogSuccess("CONVERTDISK", fmt.Sprintf("  → Archivo: %d → %d bytes (disco de %d bytes, %d con datos)",
+		conversion.OldFileSize, conversion.NewFileSize, conversion.DiskSize, conversion.DataBytes))
+
+	return conversion, nil
+}
@@ -0,0 +1,271 @@
+package disk
+
+/*
+ * CPDISK - Este comando copia un disco a un archivo nuevo.
+ * La copia conserva las regiones dispersas del archivo, recibe una firma nueva
+ * (para que el sistema de montaje no confunda ambos discos) y se limpia el estado
+ * de montaje de su MBR y de sus EBRs. Con -name se clona una sola partición
+ * dentro del espacio libre de otro disco existente.
+ */
+
+import (
+	utils "backend/Utils"
+	action "backend/action"
+	estructuras "backend/struct"
+	"fmt"
+	"strings"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA   | DESCRIPCIÓN                                                                                                                                        |
+|-----------|-------------|----------------------------------------------------------------------------------------------------------------------------------------------------|
+| -src      | Obligatorio | Ruta del disco origen.                                                                                                                             |
+| -dest     | Obligatorio | Ruta del disco destino. Sin -name no debe existir; con -name debe ser un disco existente distinto al origen.                                       |
+| -name     | Opcional    | Nombre de la partición a clonar. Se crea en el disco destino con el mismo nombre, tipo, ajuste y tamaño, usando el espacio libre disponible.      |
+*/
+
+// CpDiskResult contiene el resultado de la copia de un disco o de una partición
+type CpDiskResult struct {
+	Source    string `json:"source"`               // Disco origen
+	Dest      string `json:"dest"`                 // Disco destino
+	Size      int64  `json:"size"`                 // Bytes copiados (disco o partición)
+	DataBytes int64  `json:"data_bytes,omitempty"` // Bytes con datos escritos (sin contar regiones dispersas)
+	Signature int64  `json:"signature,omitempty"`  // Firma nueva del disco copiado
+	Partition string `json:"partition,omitempty"`  // Partición clonada
+	Start     int64  `json:"start,omitempty"`      // Inicio de la partición clonada en el destino
+}
+
+// CpDisk copia un disco completo, o una sola partición si se indica -name
+func CpDisk(src, dest, name string) (*CpDiskResult, error) {
+	utils.LogInfo("CpDisk", fmt.Sprintf("Iniciando copia: src=%s, dest=%s, name=%s", src, dest, name))
+
+	// Validar parámetros obligatorios
+	if src == "" {
+		utils.LogError("CpDisk", "El parámetro -src es obligatorio")
+		return nil, fmt.Errorf("el parámetro -src es obligatorio")
+	}
+
+	if dest == "" {
+		utils.LogError("CpDisk", "El parámetro -dest es obligatorio")
+		return nil, fmt.Errorf("el parámetro -dest es obligatorio")
+	}
+
+	// Validar que el disco origen existe y es válido
+	if err := estructuras.ValidateDiskIntegrity(src); err != nil {
+		utils.LogError("CpDisk", fmt.Sprintf("Error de integridad del disco origen: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco origen: %v", err)
+	}
+
+	if strings.TrimSpace(name) != "" {
+		return clonePartition(src, dest, strings.TrimSpace(name))
+	}
+
+	return copyWholeDisk(src, dest)
+}
+
+// copyDiskContent copia el contenido del disco a un archivo nuevo
+// Si el origen tiene snapshots se copia el estado actual leyendo a través de sus capas; si
+// alguno de los discos está en memoria también se copia a través de sus dispositivos
+func copyDiskContent(src, dest string) (int64, error) {
+	inMemory := estructuras.IsMemoryDisk(src) || estructuras.IsMemoryDisk(dest)
+	if !estructuras.HasSnapshots(src) && !inMemory {
+		return action.CopyDiskFile(src, dest)
+	}
+
+	info, err := estructuras.GetDiskInfo(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if inMemory {
+		utils.LogInfo("CpDisk", "Copiando a través de los dispositivos de los discos")
+	} else {
+		utils.LogInfo("CpDisk", "El disco origen tiene snapshots, se copia su estado actual")
+	}
+	if err := action.NewDisk(dest, info.Size); err != nil {
+		return 0, err
+	}
+
+	if err := estructuras.CopyBetweenDisks(src, 0, dest, 0, info.Size); err != nil {
+		// No se deja un disco a medias
+		action.DeleteDisk(dest)
+		return 0, fmt.Errorf("error al copiar el disco: %v", err)
+	}
+
+	return info.Size, nil
+}
+
+// copyWholeDisk copia el archivo del disco y prepara la copia como un disco independiente
+func copyWholeDisk(src, dest string) (*CpDiskResult, error) {
+	// Copiar el archivo conservando las regiones dispersas
+	dataBytes, err := copyDiskContent(src, dest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Un diario de un disco anterior con la misma ruta no corresponde a la copia
+	if err := estructuras.RemoveUndoJournal(dest); err != nil {
+		return nil, err
+	}
+
+	// Leer el MBR de la copia
+	mbr, err := estructuras.ReadMBR(dest)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR de la copia: %v", err)
+	}
+
+	// Firma nueva y sin particiones montadas
+	oldSignature := mbr.MbrDiskSignature
+	mbr.ResetSignature()
+	mbr.ClearMountState()
+
+	if err := writeUpdatedMBR(dest, mbr); err != nil {
+		return nil, fmt.Errorf("error al escribir MBR de la copia: %v", err)
+	}
+
+	// Las lógicas guardan su estado de montaje en el EBR
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		if _, err := estructuras.ClearEBRChainMounts(dest, extendedPartition.PartStart); err != nil {
+			return nil, fmt.Errorf("error al limpiar el montaje de las particiones lógicas: %v", err)
+		}
+	}
+
+	// Validar la copia
+	if err := estructuras.ValidateDiskIntegrity(dest); err != nil {
+		utils.LogError("CpDisk", fmt.Sprintf("La copia no pasó la validación de integridad: %v", err))
+		return nil, fmt.Errorf("la copia no pasó la validación de integridad: %v", err)
+	}
+
+	utils.LogSuccess("CpDisk", "Disco copiado exitosamente:")
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Origen: %s", src))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Destino: %s", dest))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Tamaño: %d bytes (%d con datos)", mbr.MbrTamanio, dataBytes))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Signature: %d (origen: %d)", mbr.MbrDiskSignature, oldSignature))
+
+	return &CpDiskResult{
+		Source:    src,
+		Dest:      dest,
+		Size:      mbr.MbrTamanio,
+		DataBytes: dataBytes,
+		Signature: mbr.MbrDiskSignature,
+	}, nil
+}
+
+// clonePartition crea una partición igual a la de origen en el espacio libre de otro disco y copia sus datos
+func clonePartition(src, dest, name string) (*CpDiskResult, error) {
+	if src == dest {
+		utils.LogError("CpDisk", "El disco destino debe ser distinto al origen, use fdisk -move para mover una partición")
+		return nil, fmt.Errorf("el disco destino debe ser distinto al origen, use fdisk -move para mover una partición")
+	}
+
+	// Validar que el disco destino existe y es válido
+	if err := estructuras.ValidateDiskIntegrity(dest); err != nil {
+		utils.LogError("CpDisk", fmt.Sprintf("Error de integridad del disco destino: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco destino: %v", err)
+	}
+
+	// Buscar la partición en el disco origen
+	srcMBR, err := estructuras.ReadMBR(src)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR del origen: %v", err)
+	}
+
+	var partType string
+	var fit byte
+	var srcStart, size int64
+
+	if partition := srcMBR.GetParticionByName(name); partition != nil {
+		partType = "P"
+		if partition.IsExtended() {
+			partType = "E"
+		}
+		fit, srcStart, size = partition.PartFit, partition.PartStart, partition.PartSize
+	} else if extendedPartition := srcMBR.GetParticionExtendida(); extendedPartition != nil {
+		ebr, _, err := estructuras.FindEBRByName(src, extendedPartition.PartStart, name)
+		if err != nil {
+			utils.LogError("CpDisk", fmt.Sprintf("No se encontró una partición con el nombre '%s' en el origen", name))
+			return nil, fmt.Errorf("no se encontró una partición con el nombre '%s' en %s", name, src)
+		}
+		partType = "L"
+		fit, srcStart, size = ebr.PartFit, ebr.PartStart, ebr.PartSize
+	} else {
+		utils.LogError("CpDisk", fmt.Sprintf("No se encontró una partición con el nombre '%s' en el origen", name))
+		return nil, fmt.Errorf("no se encontró una partición con el nombre '%s' en %s", name, src)
+	}
+
+	// Crear la partición en el destino con las mismas reglas que fdisk
+	if err := Fdisk(size, "B", dest, partType, fitToParam(fit), name, DefaultPlacement()); err != nil {
+		return nil, fmt.Errorf("no se pudo crear la partición en el destino: %v", err)
+	}
+
+	destStart, err := findPartitionStart(dest, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// La copia de los datos no se registra en el diario de deshacer del destino
+	if err := estructuras.RemoveUndoJournal(dest); err != nil {
+		return nil, err
+	}
+
+	// Copiar los datos de la partición
+	if err := estructuras.CopyBetweenDisks(src, srcStart, dest, destStart, size); err != nil {
+		return nil, fmt.Errorf("error al copiar los datos de la partición '%s': %v", name, err)
+	}
+
+	// Una extendida trae su cadena de EBRs con posiciones del disco origen
+	if partType == "E" {
+		if err := shiftEBRChain(dest, destStart, destStart-srcStart); err != nil {
+			return nil, err
+		}
+		if _, err := estructuras.ClearEBRChainMounts(dest, destStart); err != nil {
+			return nil, fmt.Errorf("error al limpiar el montaje de las particiones lógicas: %v", err)
+		}
+	}
+
+	utils.LogSuccess("CpDisk", "Partición clonada exitosamente:")
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Nombre: %s", name))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Origen: %s (byte %d)", src, srcStart))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Destino: %s (byte %d)", dest, destStart))
+	utils.LogSuccess("CpDisk", fmt.Sprintf("  → Tamaño: %d bytes", size))
+
+	return &CpDiskResult{
+		Source:    src,
+		Dest:      dest,
+		Size:      size,
+		Partition: name,
+		Start:     destStart,
+	}, nil
+}
+
+// findPartitionStart busca el byte de inicio de los datos de una partición por nombre
+func findPartitionStart(path, name string) (int64, error) {
+	mbr, err := estructuras.ReadMBR(path)
+	if err != nil {
+		return 0, fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	if partition := mbr.GetParticionByName(name); partition != nil {
+		return partition.PartStart, nil
+	}
+
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		if ebr, _, err := estructuras.FindEBRByName(path, extendedPartition.PartStart, name); err == nil {
+			return ebr.PartStart, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no se encontró una partición con el nombre '%s' en %s", name, path)
+}
+
+// fitToParam convierte el ajuste guardado en el disco al valor del parámetro -fit
+func fitToParam(fit byte) string {
+	switch fit {
+	case estructuras.PartitionFitBest:
+		return "BF"
+	case estructuras.PartitionFitFirst:
+		return "FF"
+	default:
+		return "WF"
+	}
+}
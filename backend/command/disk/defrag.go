@@ -0,0 +1,249 @@
+package disk
+
+/*
+ * DEFRAG - Este comando compacta la tabla de particiones de un disco.
+ * Mueve las particiones (y las lógicas dentro de la extendida) hacia el inicio
+ * del disco, copiando sus datos y reescribiendo el MBR y la cadena de EBRs, de
+ * modo que todo el espacio libre del disco queda en una sola región al final.
+ * El espacio libre dentro de la extendida queda al final de la extendida.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"sort"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                              |
+|-----------|--------------|----------------------------------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco a compactar. Ninguna partición del disco puede estar montada.                             |
+*/
+
+// DefragMove describe el movimiento de una partición durante la compactación
+type DefragMove struct {
+	Name string `json:"name"` // Nombre de la partición
+	Type string `json:"type"` // Tipo de partición
+	From int64  `json:"from"` // Posición original
+	To   int64  `json:"to"`   // Posición nueva
+	Size int64  `json:"size"` // Tamaño en bytes
+}
+
+// Defrag compacta las particiones del disco hacia el inicio
+func Defrag(path string) ([]DefragMove, error) {
+	utils.LogInfo("DEFRAG", fmt.Sprintf("Iniciando compactación del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("DEFRAG", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	// El disco se abre una sola vez para leer su tabla de particiones
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		utils.LogError("DEFRAG", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+	defer device.Close()
+
+	// Validar que el disco existe y es válido
+	if err := estructuras.ValidateDiskIntegrityOnDevice(device); err != nil {
+		utils.LogError("DEFRAG", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+
+	// No se puede mover nada mientras haya particiones montadas
+	if HasMountedPartitions(path) {
+		utils.LogError("DEFRAG", "El disco tiene particiones montadas, desmóntelas antes de compactar")
+		return nil, fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de compactar")
+	}
+
+	// Leer el MBR
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	// Los movimientos no se registran en el diario de deshacer
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return nil, err
+	}
+
+	var moves []DefragMove
+
+	// Compactar primero las lógicas dentro de la extendida
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		logicalMoves, err := compactLogicalPartitions(device, extendedPartition.PartStart)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, logicalMoves...)
+	}
+
+	// Ordenar las particiones activas por posición de inicio
+	var partitions []*estructuras.Partition
+	for i := range mbr.MbrParticiones {
+		if mbr.MbrParticiones[i].IsActive() {
+			partitions = append(partitions, &mbr.MbrParticiones[i])
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].PartStart < partitions[j].PartStart
+	})
+
+	// Mover cada partición inmediatamente después de la anterior
+	cursor := mbr.FirstUsableByte()
+	for _, partition := range partitions {
+		oldStart := partition.PartStart
+		if oldStart > cursor {
+			if err := estructuras.CopyRange(path, oldStart, cursor, partition.PartSize); err != nil {
+				return nil, fmt.Errorf("error al mover la partición '%s': %v", partition.GetName(), err)
+			}
+
+			// La cadena de EBRs usa posiciones absolutas, se debe desplazar
+			if partition.IsExtended() {
+				if err := shiftEBRChain(path, cursor, cursor-oldStart); err != nil {
+					return nil, err
+				}
+			}
+
+			partition.PartStart = cursor
+			moves = append(moves, DefragMove{
+				Name: partition.GetName(),
+				Type: partition.GetTypeString(),
+				From: oldStart,
+				To:   cursor,
+				Size: partition.PartSize,
+			})
+		}
+		cursor = partition.GetEndPosition()
+	}
+
+	// Escribir el MBR actualizado al disco
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return nil, fmt.Errorf("error al escribir MBR actualizado: %v", err)
+	}
+
+	utils.LogSuccess("DEFRAG", "Disco compactado exitosamente:")
+	utils.LogSuccess("DEFRAG", fmt.Sprintf("  → Particiones movidas: %d", len(moves)))
+	utils.LogSuccess("DEFRAG", fmt.Sprintf("  → Espacio libre al final: %d bytes (desde el byte %d)", mbr.LastUsableByte()-cursor, cursor))
+
+	return moves, nil
+}
+
+// compactLogicalPartitions mueve las lógicas hacia el inicio de la extendida y reescribe la cadena ordenada
+func compactLogicalPartitions(device *estructuras.DiskDevice, extendedStart int64) ([]DefragMove, error) {
+	path := device.Path()
+	chain, err := estructuras.ReadEBRChainFromDevice(device, extendedStart)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+	}
+
+	// El primer EBR siempre permanece al inicio de la extendida
+	head := chain[0]
+	var logicals []estructuras.EBRNode
+	for _, node := range chain[1:] {
+		if !node.EBR.IsEmpty() {
+			logicals = append(logicals, node)
+		}
+	}
+	sort.Slice(logicals, func(i, j int) bool {
+		return logicals[i].Position < logicals[j].Position
+	})
+
+	var moves []DefragMove
+	cursor := head.Position + int64(estructuras.EBR_SIZE)
+	if !head.EBR.IsEmpty() {
+		cursor = head.EBR.GetEndPosition()
+	}
+
+	// Calcular la nueva posición de cada lógica y mover sus datos
+	newPositions := make([]int64, len(logicals))
+	for i, node := range logicals {
+		newPositions[i] = cursor
+		newDataStart := cursor + int64(estructuras.EBR_SIZE)
+
+		if node.Position != cursor {
+			if err := estructuras.CopyRange(path, node.EBR.PartStart, newDataStart, node.EBR.PartSize); err != nil {
+				return nil, fmt.Errorf("error al mover la partición lógica '%s': %v", node.EBR.GetName(), err)
+			}
+
+			moves = append(moves, DefragMove{
+				Name: node.EBR.GetName(),
+				Type: "Lógica",
+				From: node.Position,
+				To:   cursor,
+				Size: node.EBR.PartSize,
+			})
+		}
+
+		node.EBR.PartStart = newDataStart
+		cursor = newDataStart + node.EBR.PartSize
+	}
+
+	// Reescribir la cadena completa, ordenada por posición
+	for i := range logicals {
+		logicals[i].Position = newPositions[i]
+	}
+	if err := writeSortedEBRChain(path, head, logicals); err != nil {
+		return nil, err
+	}
+
+	return moves, nil
+}
+
+// shiftEBRChain desplaza las posiciones absolutas de una cadena de EBRs ya copiada a su nuevo inicio
+func shiftEBRChain(path string, newExtendedStart, delta int64) error {
+	currentPosition := newExtendedStart
+
+	for currentPosition != -1 {
+		ebr, err := estructuras.ReadEBR(path, currentPosition)
+		if err != nil {
+			return fmt.Errorf("error al leer EBR en posición %d: %v", currentPosition, err)
+		}
+
+		if !ebr.IsEmpty() {
+			ebr.PartStart += delta
+		}
+		if ebr.PartNext != -1 {
+			ebr.PartNext += delta
+		}
+
+		if err := estructuras.WriteEBR(path, ebr, currentPosition); err != nil {
+			return fmt.Errorf("error al escribir EBR en posición %d: %v", currentPosition, err)
+		}
+
+		currentPosition = ebr.PartNext
+	}
+
+	return nil
+}
+
+// writeSortedEBRChain reescribe los enlaces de la cadena de EBRs ordenando las lógicas por posición
+func writeSortedEBRChain(path string, head estructuras.EBRNode, logicals []estructuras.EBRNode) error {
+	sort.Slice(logicals, func(i, j int) bool {
+		return logicals[i].Position < logicals[j].Position
+	})
+
+	for i, node := range logicals {
+		node.EBR.PartNext = -1
+		if i+1 < len(logicals) {
+			node.EBR.PartNext = logicals[i+1].Position
+		}
+		if err := estructuras.WriteEBR(path, node.EBR, node.Position); err != nil {
+			return fmt.Errorf("error al escribir EBR de '%s': %v", node.EBR.GetName(), err)
+		}
+	}
+
+	head.EBR.PartNext = -1
+	if len(logicals) > 0 {
+		head.EBR.PartNext = logicals[0].Position
+	}
+	if err := estructuras.WriteEBR(path, head.EBR, head.Position); err != nil {
+		return fmt.Errorf("error al escribir el EBR inicial: %v", err)
+	}
+
+	return nil
+}
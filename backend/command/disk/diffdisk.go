@@ -0,0 +1,70 @@
+package disk
+
+/*
+ * DIFFDISK - Este comando compara la tabla de particiones de dos discos:
+ * tamaño, firma, ajuste, tipo de tabla, las particiones de la tabla y las
+ * lógicas de las cadenas de EBRs de sus particiones extendidas.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                      |
+|-----------|--------------|--------------------------------------------------|
+| -path1    | Obligatorio  | Ruta del primer disco.                           |
+| -path2    | Obligatorio  | Ruta del segundo disco.                          |
+*/
+
+// DiffDiskResult es el resultado de comparar dos discos
+type DiffDiskResult struct {
+	Path1       string   `json:"path1"`       // Primer disco
+	Path2       string   `json:"path2"`       // Segundo disco
+	Equal       bool     `json:"equal"`       // Las tablas de particiones son iguales
+	Differences []string `json:"differences"` // Diferencias encontradas
+}
+
+// DiffDisk compara las tablas de particiones de dos discos
+func DiffDisk(path1, path2 string) (*DiffDiskResult, error) {
+	utils.LogInfo("DIFFDISK", fmt.Sprintf("Comparando discos: %s y %s", path1, path2))
+
+	// Validar parámetros obligatorios
+	if path1 == "" {
+		utils.LogError("DIFFDISK", "El parámetro -path1 es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path1 es obligatorio")
+	}
+
+	if path2 == "" {
+		utils.LogError("DIFFDISK", "El parámetro -path2 es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path2 es obligatorio")
+	}
+
+	differences, err := estructuras.CompareMBR(path1, path2)
+	if err != nil {
+		utils.LogError("DIFFDISK", err.Error())
+		return nil, err
+	}
+
+	result := &DiffDiskResult{
+		Path1:       path1,
+		Path2:       path2,
+		Equal:       len(differences) == 0,
+		Differences: []string{},
+	}
+	result.Differences = append(result.Differences, differences...)
+
+	for _, difference := range differences {
+		utils.LogInfo("DIFFDISK", fmt.Sprintf("  → %s", difference))
+	}
+
+	if result.Equal {
+		utils.LogSuccess("DIFFDISK", "Las tablas de particiones son iguales")
+	} else {
+		utils.LogSuccess("DIFFDISK", fmt.Sprintf("Se encontraron %d diferencias", len(differences)))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package disk
+
+/*
+ * Registro de letras por disco. Cada disco (identificado por su firma) recibe una
+ * única letra que se reutiliza en los IDs de todas sus particiones montadas
+ * (ej: 841A, 842A para el primer disco y 841B para el segundo). La letra se libera
+ * cuando se desmontan todas las particiones del disco.
+ */
+
+import (
+	"fmt"
+	"sort"
+)
+
+// diskLetterRegistry asigna letras de la A a la Z a los discos con particiones montadas
+type diskLetterRegistry struct {
+	letters map[string]byte // Key: firma del disco, Value: letra asignada
+}
+
+// newDiskLetterRegistry crea un registro de letras vacío
+func newDiskLetterRegistry() *diskLetterRegistry {
+	return &diskLetterRegistry{
+		letters: make(map[string]byte),
+	}
+}
+
+// Get retorna la letra asignada a un disco, si tiene una
+func (r *diskLetterRegistry) Get(diskKey string) (byte, bool) {
+	letter, exists := r.letters[diskKey]
+	return letter, exists
+}
+
+// Acquire retorna la letra del disco, asignándole la primera libre si aún no tiene
+func (r *diskLetterRegistry) Acquire(diskKey string) (byte, error) {
+	if letter, exists := r.letters[diskKey]; exists {
+		return letter, nil
+	}
+
+	letter, ok := r.NextFree()
+	if !ok {
+		return 0, fmt.Errorf("no hay letras disponibles: los 26 identificadores de disco (A-Z) están en uso, desmonte las particiones de algún disco")
+	}
+
+	r.letters[diskKey] = letter
+	return letter, nil
+}
+
+// Reserve asigna una letra específica a un disco (usado al restaurar montajes)
+func (r *diskLetterRegistry) Reserve(diskKey string, letter byte) error {
+	if letter < 'A' || letter > 'Z' {
+		return fmt.Errorf("letra inválida '%c'", letter)
+	}
+
+	if current, exists := r.letters[diskKey]; exists {
+		if current != letter {
+			return fmt.Errorf("el disco ya tiene asignada la letra '%c'", current)
+		}
+		return nil
+	}
+
+	if owner := r.ownerOf(letter); owner != "" {
+		return fmt.Errorf("la letra '%c' ya está asignada al disco %s", letter, owner)
+	}
+
+	r.letters[diskKey] = letter
+	return nil
+}
+
+// Release libera la letra de un disco
+func (r *diskLetterRegistry) Release(diskKey string) {
+	delete(r.letters, diskKey)
+}
+
+// NextFree retorna la primera letra sin asignar
+func (r *diskLetterRegistry) NextFree() (byte, bool) {
+	for letter := byte('A'); letter <= 'Z'; letter++ {
+		if r.ownerOf(letter) == "" {
+			return letter, true
+		}
+	}
+	return 0, false
+}
+
+// FreeCount retorna cuántas letras quedan disponibles
+func (r *diskLetterRegistry) FreeCount() int {
+	return 26 - len(r.letters)
+}
+
+// Allocation retorna la asignación actual de letras (Key: letra, Value: firma del disco)
+func (r *diskLetterRegistry) Allocation() map[string]string {
+	allocation := make(map[string]string)
+	for diskKey, letter := range r.letters {
+		allocation[string(letter)] = diskKey
+	}
+	return allocation
+}
+
+// AssignedLetters retorna las letras asignadas en orden alfabético
+func (r *diskLetterRegistry) AssignedLetters() []string {
+	var letters []string
+	for _, letter := range r.letters {
+		letters = append(letters, string(letter))
+	}
+	sort.Strings(letters)
+	return letters
+}
+
+// ownerOf retorna la firma del disco que tiene asignada la letra, o "" si está libre
+func (r *diskLetterRegistry) ownerOf(letter byte) string {
+	for diskKey, assigned := range r.letters {
+		if assigned == letter {
+			return diskKey
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package disk
+
+/*
+ * Registro de discos. Describe los discos de un directorio del espacio de trabajo
+ * (o los discos en memoria) leyendo su MBR y la cadena de EBRs de cada extendida,
+ * con el ID de montaje de cada partición según el MountSystem y los metadatos
+ * del disco (etiqueta, descripción y dueño, ver diskmeta.go). Un archivo que no se
+ * puede leer como disco se reporta con su error en lugar de omitirlo.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Estados de un disco del registro
+const (
+	DiskStatusMounted   = "mounted"   // Tiene al menos una partición montada
+	DiskStatusUnmounted = "unmounted" // Ninguna partición montada
+	DiskStatusInvalid   = "invalid"   // El archivo no se pudo leer como disco
+)
+
+// RegisteredDisk es un disco del registro
+type RegisteredDisk struct {
+	Name       string                   `json:"name"`            // Nombre del archivo (o del disco en memoria)
+	Path       string                   `json:"path"`            // Ruta del disco
+	Format     string                   `json:"format"`          // Formato del archivo (raw o qmia)
+	Size       int64                    `json:"size"`            // Tamaño del disco en bytes
+	FileSize   int64                    `json:"file_size"`       // Bytes que ocupa el archivo (0 en memoria)
+	TableType  string                   `json:"table_type"`      // Tipo de tabla de particiones
+	Status     string                   `json:"status"`          // DiskStatusMounted, DiskStatusUnmounted o DiskStatusInvalid
+	MountIDs   []string                 `json:"mount_ids"`       // IDs de las particiones montadas
+	Partitions []LsDiskEntry            `json:"partitions"`      // Particiones, incluidas las lógicas
+	Metadata   estructuras.DiskMetadata `json:"metadata"`        // Etiqueta, descripción y dueño
+	Error      string                   `json:"error,omitempty"` // Por qué el disco es inválido
+}
+
+// ListDisks describe los discos .mia y .dsk de un directorio (recursivamente)
+// Con mem:// describe los discos en memoria
+func ListDisks(diskDir string) ([]*RegisteredDisk, error) {
+	var paths []string
+	if diskDir == estructuras.MEMORY_DISK_SCHEME {
+		for _, disk := range estructuras.MemoryDisks() {
+			paths = append(paths, disk.Path)
+		}
+	} else {
+		files, err := findDiskFiles(diskDir)
+		if err != nil {
+			return nil, err
+		}
+		paths = files
+	}
+
+	disks := []*RegisteredDisk{}
+	for _, path := range paths {
+		disks = append(disks, DescribeDisk(path))
+	}
+	return disks, nil
+}
+
+// DescribeDisk lee un disco para el registro
+// El disco se bloquea para lectura mientras se lee, así que espera a los comandos que lo modifican
+func DescribeDisk(path string) *RegisteredDisk {
+	disk := &RegisteredDisk{
+		Name:       filepath.Base(path),
+		Path:       path,
+		Status:     DiskStatusInvalid,
+		MountIDs:   []string{},
+		Partitions: []LsDiskEntry{},
+	}
+	if estructuras.IsMemoryDisk(path) {
+		disk.Name = strings.TrimPrefix(path, estructuras.MEMORY_DISK_SCHEME)
+	}
+
+	// Los metadatos se reportan aunque el disco no se pueda leer
+	if metadata, err := estructuras.LoadDiskMetadata(path); err != nil {
+		utils.LogWarning("REGISTRY", fmt.Sprintf("%s: %v", path, err))
+	} else {
+		disk.Metadata = *metadata
+	}
+
+	lock, err := estructuras.LockDisks([]string{path}, nil)
+	if err != nil {
+		disk.Error = err.Error()
+		return disk
+	}
+	defer lock.Unlock()
+
+	devices := estructuras.OpenDevices([]string{path})
+	defer devices.Close()
+
+	if !estructuras.IsMemoryDisk(path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			disk.Error = err.Error()
+			return disk
+		}
+		disk.FileSize = info.Size()
+	}
+
+	format, err := estructuras.DiskFormat(path)
+	if err != nil {
+		disk.Error = err.Error()
+		return disk
+	}
+	disk.Format = format
+
+	partitions, err := readDiskPartitions(path)
+	if err != nil {
+		disk.Error = err.Error()
+		return disk
+	}
+	disk.Size = partitions.Size
+	disk.TableType = partitions.TableType
+	disk.Partitions = partitions.Partitions
+
+	disk.Status = DiskStatusUnmounted
+	for _, partition := range partitions.Partitions {
+		if partition.Mounted {
+			disk.MountIDs = append(disk.MountIDs, partition.ID)
+			disk.Status = DiskStatusMounted
+		}
+	}
+
+	return disk
+}
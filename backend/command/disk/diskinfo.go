@@ -0,0 +1,57 @@
+package disk
+
+/*
+ * DISKINFO - Este comando muestra las estadísticas de un disco: tamaño, fecha
+ * de creación, firma, ajuste, tipo de tabla, espacio usado y libre, porcentaje
+ * de uso y el detalle de cada partición de la tabla.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                      |
+|-----------|--------------|--------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco del que se mostrará información.  |
+*/
+
+// DiskInfo retorna las estadísticas del disco
+func DiskInfo(path string) (map[string]interface{}, error) {
+	utils.LogInfo("DISKINFO", fmt.Sprintf("Obteniendo información del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("DISKINFO", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	stats, err := estructuras.GetDiskStatistics(path)
+	if err != nil {
+		utils.LogError("DISKINFO", err.Error())
+		return nil, err
+	}
+	stats["path"] = path
+	stats["mounted_partitions"] = len(getDiskMounts(path))
+
+	utils.LogSuccess("DISKINFO", fmt.Sprintf("Disco %s: %d bytes, %d particiones activas, %.2f%% de uso",
+		path, stats["total_size"], stats["active_partitions"], stats["usage_percentage"]))
+
+	return stats, nil
+}
+
+// getDiskMounts retorna los IDs de las particiones montadas de un disco, por nombre
+func getDiskMounts(path string) map[string]string {
+	mountSystem.mutex.RLock()
+	defer mountSystem.mutex.RUnlock()
+
+	mounts := make(map[string]string)
+	for _, partition := range mountSystem.mountedPartitions {
+		if estructuras.SameDisk(partition.Path, path) {
+			mounts[partition.Name] = partition.ID
+		}
+	}
+	return mounts
+}
@@ -0,0 +1,88 @@
+package disk
+
+/*
+ * DISKMETA - Este comando muestra o cambia los metadatos de un disco: una
+ * etiqueta, una descripción y su dueño. Se guardan junto al disco (<disco>.meta),
+ * no en su MBR, y se muestran en el registro de discos (/api/filesystems).
+ * Sin parámetros de cambio solo se muestran.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO    | CATEGORÍA    | DESCRIPCIÓN                                                                                   |
+|--------------|--------------|-----------------------------------------------------------------------------------------------|
+| -path        | Obligatorio  | Ruta del disco. No puede ser un disco en memoria si se cambian los metadatos.                 |
+| -label       | Opcional     | Etiqueta del disco. Con un valor vacío (-label=) se elimina.                                  |
+| -description | Opcional     | Descripción del disco. Con un valor vacío se elimina.                                         |
+| -owner       | Opcional     | Dueño del disco. Con un valor vacío se elimina.                                               |
+| -clear       | Opcional     | Elimina todos los metadatos antes de aplicar los demás parámetros.                            |
+*/
+
+// Metadatos que se pueden cambiar con diskmeta
+const (
+	DiskMetaLabel       = "label"
+	DiskMetaDescription = "description"
+	DiskMetaOwner       = "owner"
+)
+
+// DiskMeta muestra o cambia los metadatos de un disco
+// changes tiene solo los metadatos que se cambian (un valor vacío elimina el metadato)
+func DiskMeta(path string, changes map[string]string, clear bool) (*estructuras.DiskMetadata, error) {
+	utils.LogInfo("DISKMETA", fmt.Sprintf("Metadatos del disco: path=%s, cambios=%d, clear=%t", path, len(changes), clear))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("DISKMETA", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	// El disco debe existir
+	if _, err := estructuras.DiskSize(path); err != nil {
+		utils.LogError("DISKMETA", err.Error())
+		return nil, err
+	}
+
+	metadata, err := estructuras.LoadDiskMetadata(path)
+	if err != nil {
+		utils.LogError("DISKMETA", err.Error())
+		return nil, err
+	}
+
+	if len(changes) == 0 && !clear {
+		utils.LogSuccess("DISKMETA", fmt.Sprintf("Disco %s: etiqueta='%s', dueño='%s', descripción='%s'", path, metadata.Label, metadata.Owner, metadata.Description))
+		return metadata, nil
+	}
+
+	if clear {
+		metadata = &estructuras.DiskMetadata{}
+	}
+	for field, value := range changes {
+		switch field {
+		case DiskMetaLabel:
+			metadata.Label = value
+		case DiskMetaDescription:
+			metadata.Description = value
+		case DiskMetaOwner:
+			metadata.Owner = value
+		default:
+			return nil, fmt.Errorf("metadato no válido '%s', use -label, -description o -owner", field)
+		}
+	}
+
+	if err := estructuras.SaveDiskMetadata(path, metadata); err != nil {
+		utils.LogError("DISKMETA", err.Error())
+		return nil, err
+	}
+
+	utils.LogSuccess("DISKMETA", "Metadatos del disco actualizados:")
+	utils.LogSuccess("DISKMETA", fmt.Sprintf("  → Etiqueta: %s", metadata.Label))
+	utils.LogSuccess("DISKMETA", fmt.Sprintf("  → Descripción: %s", metadata.Description))
+	utils.LogSuccess("DISKMETA", fmt.Sprintf("  → Dueño: %s", metadata.Owner))
+
+	return metadata, nil
+}
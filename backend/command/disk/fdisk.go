@@ -15,18 +15,27 @@ import (
 	utils "backend/Utils"
 	estructuras "backend/struct"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
 /*
 | PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                                                                                                                                                                                                                                                                                      |
 |-----------|--------------|-------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------|
-| -size     | Obligatorio  | Número que indica el tamaño de la partición a crear. Debe ser positivo y mayor que cero.                                                                                                                                                                                                                                                                        |
-| -unit     | Opcional     | Letra que indica las unidades del parámetro size. Valores: B (bytes), K (Kilobytes), M (Megabytes). Default: Kilobytes.                                                                                                                                                                                                                                        |
+| -size     | Obligatorio  | Número que indica el tamaño de la partición a crear. Debe ser positivo y mayor que cero. También acepta un porcentaje (50%) del espacio utilizable del disco, o de la extendida para una lógica, y rest para ocupar todo el espacio libre contiguo (el más grande, o el que sigue a -start).                                                                   |
+| -unit     | Opcional     | Letra que indica las unidades del parámetro size. Valores: B (bytes), K (Kilobytes), M (Megabytes), G (Gigabytes). Default: Kilobytes.                                                                                                                                                                                                                         |
 | -path     | Obligatorio  | Ruta del disco donde se creará la partición. El archivo debe existir.                                                                                                                                                                                                                                                                                           |
 | -type     | Opcional     | Tipo de partición a crear. Valores: P (Primaria), E (Extendida), L (Lógica). Default: Primaria.                                                                                                                                                                                                                                                                |
 | -fit      | Opcional     | Algoritmo de ajuste para asignar espacio. Valores: BF (Best Fit), FF (First Fit), WF (Worst Fit). Default: Worst Fit.                                                                                                                                                                                                                                          |
 | -name     | Obligatorio  | Nombre de la partición. No debe repetirse dentro de las particiones de cada disco.                                                                                                                                                                                                                                                                              |
+| -add      | Opcional     | Agrega (positivo) o quita (negativo) espacio a la partición indicada por -name, en las unidades de -unit. Solo usa el espacio libre contiguo al final de la partición.                                                                                                                                                                                     |
+| -delete   | Opcional     | Elimina la partición indicada por -name. Valores: Fast (solo limpia la tabla de particiones), Full (además rellena con ceros el espacio liberado).                                                                                                                                                                                                              |
+| -move     | Opcional     | Mueve la partición indicada por -name (con sus datos) al byte indicado por -start. El destino debe estar libre, aunque puede traslaparse con la posición actual de la partición.                                                                                                                                                                             |
+| -start    | Opcional     | Byte del disco donde iniciarán los datos de la partición al crearla o al usar -move. Para una lógica, su EBR se ubica justo antes de este byte y el destino debe estar dentro de la extendida. Al crear, el rango debe estar libre.                                                                                                                         |
+| -align    | Opcional     | Redondea hacia arriba el inicio de los datos de la partición nueva a un múltiplo de este valor. Valores: una potencia de dos múltiplo de 512 bytes, con unidad B, K, M o G (4K, 1M). Con -start, el inicio debe ser múltiplo de este valor.                                                                                                                 |
+| -encrypt  | Opcional     | Crea la partición cifrada: guarda al inicio una cabecera con la sal y el verificador de la frase de contraseña, y sus datos se cifran con AES-XTS. Requiere -passphrase. No aplica a extendidas. Se monta con mount -passphrase.                                                                                                                          |
+| -passphrase | Opcional   | Frase de contraseña de la partición creada con -encrypt.                                                                                                                                                                                                                                                                                                        |
 */
 
 // FdiskAction define las acciones posibles con FDISK
@@ -36,8 +45,28 @@ const (
 	ActionCreate FdiskAction = iota
 	ActionDelete
 	ActionAdd
+	ActionMove
 )
 
+// Modos del parámetro -size
+const (
+	SizeAbsolute = ""     // Tamaño en las unidades de -unit
+	SizePercent  = "%"    // Porcentaje del disco, o de la extendida para una lógica
+	SizeRest     = "rest" // Todo el espacio libre contiguo
+)
+
+// FdiskPlacement contiene las opciones de tamaño relativo y ubicación de una partición nueva
+type FdiskPlacement struct {
+	SizeMode string // SizeAbsolute, SizePercent o SizeRest
+	Align    int64  // Los inicios se redondean hacia arriba a un múltiplo de Align (0 o 1 sin alineación)
+	Start    int64  // Byte donde inician los datos de la partición, -1 para usar el algoritmo de ajuste
+}
+
+// DefaultPlacement retorna la ubicación por defecto: tamaño absoluto, sin alineación y según el ajuste
+func DefaultPlacement() FdiskPlacement {
+	return FdiskPlacement{SizeMode: SizeAbsolute, Align: 1, Start: -1}
+}
+
 // FdiskParams contiene los parámetros del comando FDISK
 type FdiskParams struct {
 	Size   int64
@@ -57,20 +86,32 @@ type FdiskResult struct {
 }
 
 // Fdisk ejecuta el comando fdisk con los parámetros especificados
-func Fdisk(size int64, unit, path, partType, fit, name string) error {
-	utils.LogInfo("FDISK", fmt.Sprintf("Iniciando administración de particiones: path=%s, name=%s, type=%s, size=%d, unit=%s, fit=%s",
-		path, name, partType, size, unit, fit))
+// Con SizePercent, size es el porcentaje; con SizeRest, size se ignora
+func Fdisk(size int64, unit, path, partType, fit, name string, placement FdiskPlacement) error {
+	utils.LogInfo("FDISK", fmt.Sprintf("Iniciando administración de particiones: path=%s, name=%s, type=%s, size=%d%s, unit=%s, fit=%s, align=%d, start=%d",
+		path, name, partType, size, placement.SizeMode, unit, fit, placement.Align, placement.Start))
+
+	// El tamaño de rest se calcula con el espacio libre
+	if placement.SizeMode == SizeRest {
+		size = 1
+	}
 
 	// Validar parámetros obligatorios
 	if err := validateFdiskParams(size, path, name); err != nil {
 		return err
 	}
 
-	// Validar que el disco existe
-	if err := validateDiskExists(path); err != nil {
+	if err := validatePlacement(size, placement); err != nil {
 		return err
 	}
 
+	// Validar que el disco existe; se abre una sola vez para todo el comando
+	device, err := openValidatedDisk(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
 	// Normalizar parámetros opcionales
 	unit = normalizeUnit(unit)
 	partType = normalizePartitionType(partType)
@@ -81,20 +122,24 @@ func Fdisk(size int64, unit, path, partType, fit, name string) error {
 		return err
 	}
 
-	// Calcular tamaño en bytes
-	sizeInBytes, err := calculateSizeInBytes(size, unit)
-	if err != nil {
-		return err
+	// Calcular tamaño en bytes (los tamaños relativos se calculan al ubicar la partición)
+	sizeInBytes := size
+	if placement.SizeMode == SizeAbsolute {
+		var err error
+		sizeInBytes, err = calculateSizeInBytes(size, unit)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Ejecutar la creación de partición según el tipo
 	switch partType {
 	case "P":
-		return createPrimaryPartition(path, name, sizeInBytes, fit)
+		return createPrimaryPartition(device, name, sizeInBytes, fit, placement)
 	case "E":
-		return createExtendedPartition(path, name, sizeInBytes, fit)
+		return createExtendedPartition(device, name, sizeInBytes, fit, placement)
 	case "L":
-		return createLogicalPartition(path, name, sizeInBytes, fit)
+		return createLogicalPartition(device, name, sizeInBytes, fit, placement)
 	default:
 		return fmt.Errorf("tipo de partición no válido: %s", partType)
 	}
@@ -126,16 +171,125 @@ func validateFdiskParams(size int64, path, name string) error {
 	return nil
 }
 
-// validateDiskExists verifica que el archivo del disco existe
-func validateDiskExists(path string) error {
+// validatePlacement valida las opciones de tamaño relativo y ubicación
+func validatePlacement(size int64, placement FdiskPlacement) error {
+	switch placement.SizeMode {
+	case SizeAbsolute, SizeRest:
+	case SizePercent:
+		if size > 100 {
+			utils.LogError("FDISK", "El porcentaje de -size no puede ser mayor que 100")
+			return fmt.Errorf("el porcentaje de -size debe estar entre 1 y 100")
+		}
+	default:
+		return fmt.Errorf("modo de tamaño no válido: %s", placement.SizeMode)
+	}
+
+	if placement.Align < 0 {
+		utils.LogError("FDISK", "El valor de -align debe ser positivo")
+		return fmt.Errorf("el valor de -align debe ser positivo")
+	}
+
+	// 0 y 1 indican que no se alinea
+	if placement.Align > 1 {
+		if err := validateAlignment(placement.Align); err != nil {
+			utils.LogError("FDISK", err.Error())
+			return err
+		}
+	}
+
+	if placement.Start >= 0 && placement.Align > 1 && placement.Start%placement.Align != 0 {
+		utils.LogError("FDISK", fmt.Sprintf("El byte %d de -start no es múltiplo de -align (%d)", placement.Start, placement.Align))
+		return fmt.Errorf("el byte %d de -start no es múltiplo de -align (%d bytes)", placement.Start, placement.Align)
+	}
+
+	return nil
+}
+
+// ParseFdiskSize interpreta el valor de -size: un número, un porcentaje (50%) o rest
+// Retorna el número (o porcentaje) y el modo de tamaño
+func ParseFdiskSize(value string) (int64, string, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.EqualFold(value, SizeRest) {
+		return 0, SizeRest, nil
+	}
+
+	mode := SizeAbsolute
+	if strings.HasSuffix(value, SizePercent) {
+		mode = SizePercent
+		value = strings.TrimSpace(strings.TrimSuffix(value, SizePercent))
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, mode, fmt.Errorf("el valor de -size debe ser un número, un porcentaje (50%%) o rest")
+	}
+
+	return size, mode, nil
+}
+
+// ParseAlignment interpreta el valor de -align: un número con unidad opcional B, K, M o G (4K, 1M)
+func ParseAlignment(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	unit := "B"
+	if value != "" && strings.ContainsAny(value[len(value)-1:], "BKMG") {
+		unit = value[len(value)-1:]
+		value = value[:len(value)-1]
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("el valor de -align debe ser un número positivo con unidad B, K, M o G (por ejemplo 4K o 1M)")
+	}
+
+	multiplier, err := unitMultiplier(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	if number > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("el valor de -align %s%s es demasiado grande", value, unit)
+	}
+
+	align := number * multiplier
+	if err := validateAlignment(align); err != nil {
+		return 0, err
+	}
+
+	return align, nil
+}
+
+// Las alineaciones son de sectores completos
+const alignmentSectorSize int64 = 512
+
+// validateAlignment verifica que una alineación sea una potencia de dos de al menos un sector
+// (con 512 bytes o más, ser potencia de dos implica ser múltiplo de 512)
+func validateAlignment(align int64) error {
+	if align < alignmentSectorSize || align&(align-1) != 0 {
+		return fmt.Errorf("el valor de -align (%d bytes) debe ser una potencia de dos múltiplo de %d bytes (por ejemplo 4K o 1M)", align, alignmentSectorSize)
+	}
+	return nil
+}
+
+// openValidatedDisk abre el disco para todo el comando y valida su integridad
+// El dispositivo retornado se pasa a las funciones que leen la tabla de particiones
+func openValidatedDisk(path string) (*estructuras.DiskDevice, error) {
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		utils.LogError("FDISK", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+
 	// Intentar validar la integridad del disco
-	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+	if err := estructuras.ValidateDiskIntegrityOnDevice(device); err != nil {
+		device.Close()
 		utils.LogError("FDISK", fmt.Sprintf("Error de integridad del disco: %v", err))
-		return fmt.Errorf("error de integridad del disco: %v", err)
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
 	}
 
 	utils.LogInfo("FDISK", "Disco validado correctamente")
-	return nil
+	return device, nil
 }
 
 // normalizeUnit normaliza la unidad especificada
@@ -168,10 +322,10 @@ func normalizeFit(fit string) string {
 // validateNormalizedParams valida los parámetros después de normalizar
 func validateNormalizedParams(unit, partType, fit string) error {
 	// Validar unidad
-	validUnits := map[string]bool{"B": true, "K": true, "M": true}
+	validUnits := map[string]bool{"B": true, "K": true, "M": true, "G": true}
 	if !validUnits[unit] {
-		utils.LogError("FDISK", fmt.Sprintf("Unidad no válida '%s', use B, K o M", unit))
-		return fmt.Errorf("unidad no válida '%s', use B, K o M", unit)
+		utils.LogError("FDISK", fmt.Sprintf("Unidad no válida '%s', use B, K, M o G", unit))
+		return fmt.Errorf("unidad no válida '%s', use B, K, M o G", unit)
 	}
 
 	// Validar tipo de partición
@@ -206,6 +360,9 @@ func calculateSizeInBytes(size int64, unit string) (int64, error) {
 	case "M":
 		multiplier = 1024 * 1024
 		unitName = "Megabytes"
+	case "G":
+		multiplier = 1024 * 1024 * 1024
+		unitName = "Gigabytes"
 	default:
 		return 0, fmt.Errorf("unidad no reconocida: %s", unit)
 	}
@@ -216,21 +373,37 @@ func calculateSizeInBytes(size int64, unit string) (int64, error) {
 	return sizeInBytes, nil
 }
 
+// unitMultiplier retorna la cantidad de bytes de una unidad
+func unitMultiplier(unit string) (int64, error) {
+	switch unit {
+	case "B":
+		return 1, nil
+	case "K":
+		return 1024, nil
+	case "M":
+		return 1024 * 1024, nil
+	case "G":
+		return 1024 * 1024 * 1024, nil
+	}
+	return 0, fmt.Errorf("unidad no reconocida: %s", unit)
+}
+
 // createPrimaryPartition crea una partición primaria
-func createPrimaryPartition(path, name string, sizeInBytes int64, fit string) error {
+func createPrimaryPartition(device *estructuras.DiskDevice, name string, sizeInBytes int64, fit string, placement FdiskPlacement) error {
+	path := device.Path()
 	utils.LogInfo("FDISK", fmt.Sprintf("Creando partición primaria: %s", name))
 
 	// Leer el MBR
-	mbr, err := estructuras.ReadMBR(path)
+	mbr, err := estructuras.ReadMBRFromDevice(device)
 	if err != nil {
 		return fmt.Errorf("error al leer MBR: %v", err)
 	}
 
-	// Verificar restricciones de particiones primarias y extendidas
+	// Verificar restricciones de particiones primarias y extendidas (4 en MBR, 128 en GPT)
 	activeCount := mbr.CountActivePartitions()
-	if activeCount >= 4 {
-		utils.LogError("FDISK", "No se pueden crear más de 4 particiones primarias y extendidas")
-		return fmt.Errorf("no se pueden crear más de 4 particiones primarias y extendidas (ya existen %d)", activeCount)
+	if activeCount >= len(mbr.MbrParticiones) {
+		utils.LogError("FDISK", fmt.Sprintf("No se pueden crear más de %d particiones primarias y extendidas", len(mbr.MbrParticiones)))
+		return fmt.Errorf("no se pueden crear más de %d particiones primarias y extendidas (ya existen %d)", len(mbr.MbrParticiones), activeCount)
 	}
 
 	// Verificar que el nombre no se repita
@@ -257,7 +430,7 @@ func createPrimaryPartition(path, name string, sizeInBytes int64, fit string) er
 	mbr.MbrFit = fitByte
 
 	// Encontrar la mejor posición para la partición
-	startPosition, err := mbr.FindBestFitPosition(sizeInBytes)
+	startPosition, sizeInBytes, err := placeMBRPartition(mbr, sizeInBytes, placement)
 	if err != nil {
 		utils.LogError("FDISK", fmt.Sprintf("No se pudo encontrar espacio para la partición: %v", err))
 		return fmt.Errorf("no se pudo encontrar espacio para la partición: %v", err)
@@ -292,26 +465,33 @@ func createPrimaryPartition(path, name string, sizeInBytes int64, fit string) er
 }
 
 // createExtendedPartition crea una partición extendida
-func createExtendedPartition(path, name string, sizeInBytes int64, fit string) error {
+func createExtendedPartition(device *estructuras.DiskDevice, name string, sizeInBytes int64, fit string, placement FdiskPlacement) error {
+	path := device.Path()
 	utils.LogInfo("FDISK", fmt.Sprintf("Creando partición extendida: %s", name))
 
 	// Leer el MBR
-	mbr, err := estructuras.ReadMBR(path)
+	mbr, err := estructuras.ReadMBRFromDevice(device)
 	if err != nil {
 		return fmt.Errorf("error al leer MBR: %v", err)
 	}
 
+	// Las tablas GPT no necesitan particiones extendidas
+	if mbr.IsGPT() {
+		utils.LogError("FDISK", "Los discos GPT no usan particiones extendidas, cree particiones primarias")
+		return fmt.Errorf("los discos GPT no usan particiones extendidas ni lógicas, cree particiones primarias (hasta %d)", len(mbr.MbrParticiones))
+	}
+
 	// Verificar que no exista ya una partición extendida
 	if mbr.HasExtendedPartition() {
 		utils.LogError("FDISK", "Solo puede haber una partición extendida por disco")
 		return fmt.Errorf("solo puede haber una partición extendida por disco")
 	}
 
-	// Verificar restricciones de particiones primarias y extendidas
+	// Verificar restricciones de particiones primarias y extendidas (4 en MBR, 128 en GPT)
 	activeCount := mbr.CountActivePartitions()
-	if activeCount >= 4 {
-		utils.LogError("FDISK", "No se pueden crear más de 4 particiones primarias y extendidas")
-		return fmt.Errorf("no se pueden crear más de 4 particiones primarias y extendidas (ya existen %d)", activeCount)
+	if activeCount >= len(mbr.MbrParticiones) {
+		utils.LogError("FDISK", fmt.Sprintf("No se pueden crear más de %d particiones primarias y extendidas", len(mbr.MbrParticiones)))
+		return fmt.Errorf("no se pueden crear más de %d particiones primarias y extendidas (ya existen %d)", len(mbr.MbrParticiones), activeCount)
 	}
 
 	// Verificar que el nombre no se repita
@@ -338,7 +518,7 @@ func createExtendedPartition(path, name string, sizeInBytes int64, fit string) e
 	mbr.MbrFit = fitByte
 
 	// Encontrar la mejor posición para la partición
-	startPosition, err := mbr.FindBestFitPosition(sizeInBytes)
+	startPosition, sizeInBytes, err := placeMBRPartition(mbr, sizeInBytes, placement)
 	if err != nil {
 		utils.LogError("FDISK", fmt.Sprintf("No se pudo encontrar espacio para la partición extendida: %v", err))
 		return fmt.Errorf("no se pudo encontrar espacio para la partición extendida: %v", err)
@@ -378,17 +558,23 @@ func createExtendedPartition(path, name string, sizeInBytes int64, fit string) e
 }
 
 // createLogicalPartition crea una partición lógica dentro de la extendida
-func createLogicalPartition(path, name string, sizeInBytes int64, fit string) error {
+func createLogicalPartition(device *estructuras.DiskDevice, name string, sizeInBytes int64, fit string, placement FdiskPlacement) error {
 	utils.LogInfo("FDISK", fmt.Sprintf("Creando partición lógica: %s", name))
 
 	// Leer el MBR
-	mbr, err := estructuras.ReadMBR(path)
+	mbr, err := estructuras.ReadMBRFromDevice(device)
 	if err != nil {
 		return fmt.Errorf("error al leer MBR: %v", err)
 	}
 
+	// Las tablas GPT no necesitan particiones lógicas
+	if mbr.IsGPT() {
+		utils.LogError("FDISK", "Los discos GPT no usan particiones lógicas, cree particiones primarias")
+		return fmt.Errorf("los discos GPT no usan particiones extendidas ni lógicas, cree particiones primarias (hasta %d)", len(mbr.MbrParticiones))
+	}
+
 	// Buscar la partición extendida
-	extendedPartition, extendedIndex, err := estructuras.GetExtendedPartition(path)
+	extendedPartition, extendedIndex, err := estructuras.GetExtendedPartitionFromDevice(device)
 	if err != nil {
 		utils.LogError("FDISK", "No se puede crear una partición lógica sin una partición extendida")
 		return fmt.Errorf("no se puede crear una partición lógica sin una partición extendida")
@@ -403,35 +589,27 @@ func createLogicalPartition(path, name string, sizeInBytes int64, fit string) er
 	}
 
 	// Verificar nombres en particiones lógicas existentes
-	if err := checkLogicalPartitionNameExists(path, extendedPartition.PartStart, name); err != nil {
+	if err := checkLogicalPartitionNameExists(device, extendedPartition.PartStart, name); err != nil {
 		return err
 	}
 
-	// Encontrar espacio dentro de la partición extendida para la partición lógica
-	logicalStart, err := findLogicalPartitionSpace(path, extendedPartition, sizeInBytes, fit)
-	if err != nil {
-		return fmt.Errorf("no se pudo encontrar espacio en la partición extendida: %v", err)
-	}
-
 	// Crear el EBR para la nueva partición lógica
 	fitByte := estructuras.ValidateFit(fit)
 	if fitByte == 0 {
 		fitByte = extendedPartition.PartFit
 	}
 
-	// Crear el EBR
-	nextEBRPosition := findNextEBRPosition(path, extendedPartition.PartStart, logicalStart+sizeInBytes+int64(estructuras.EBR_SIZE))
-
-	newEBR := estructuras.NewEBR(fitByte, logicalStart+int64(estructuras.EBR_SIZE), sizeInBytes, name, nextEBRPosition)
-
-	// Escribir el EBR al disco
-	if err := estructuras.WriteEBR(path, newEBR, logicalStart); err != nil {
-		return fmt.Errorf("error al escribir EBR: %v", err)
+	// Encontrar espacio dentro de la partición extendida para la partición lógica
+	logicalStart, sizeInBytes, err := placeLogicalPartition(device, extendedPartition, sizeInBytes, fitByte, placement)
+	if err != nil {
+		return fmt.Errorf("no se pudo encontrar espacio en la partición extendida: %v", err)
 	}
 
-	// Actualizar la cadena de EBRs si es necesario
-	if err := updateEBRChain(path, extendedPartition.PartStart, logicalStart); err != nil {
-		utils.LogWarning("FDISK", fmt.Sprintf("Advertencia al actualizar cadena de EBRs: %v", err))
+	// Crear el EBR y enlazarlo en la cadena
+	newEBR := estructuras.NewEBR(fitByte, logicalStart+int64(estructuras.EBR_SIZE), sizeInBytes, name, -1)
+
+	if err := updateEBRChain(device, extendedPartition.PartStart, newEBR, logicalStart); err != nil {
+		return fmt.Errorf("error al actualizar la cadena de EBRs: %v", err)
 	}
 
 	utils.LogSuccess("FDISK", "Partición lógica creada exitosamente:")
@@ -444,18 +622,604 @@ func createLogicalPartition(path, name string, sizeInBytes int64, fit string) er
 	return nil
 }
 
-// Helper functions
+// FdiskDelete elimina una partición primaria, extendida o lógica del disco
+func FdiskDelete(path, name, mode string) error {
+	utils.LogInfo("FDISK", fmt.Sprintf("Iniciando eliminación de partición: path=%s, name=%s, delete=%s", path, name, mode))
 
-// writeUpdatedMBR escribe el MBR actualizado al disco
-func writeUpdatedMBR(path string, mbr *estructuras.MBR) error {
-	mbrData, err := estructuras.SerializeMBR(mbr)
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("FDISK", "El parámetro -path es obligatorio")
+		return fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	if name == "" {
+		utils.LogError("FDISK", "El parámetro -name es obligatorio")
+		return fmt.Errorf("el parámetro -name es obligatorio")
+	}
+
+	// Validar el modo de eliminación
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode != "fast" && mode != "full" {
+		utils.LogError("FDISK", fmt.Sprintf("Modo de eliminación no válido '%s', use fast o full", mode))
+		return fmt.Errorf("modo de eliminación no válido '%s', use fast o full", mode)
+	}
+
+	// Validar que el disco existe; se abre una sola vez para todo el comando
+	device, err := openValidatedDisk(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	// No se puede eliminar una partición montada
+	if IsPartitionMounted(path, name) {
+		utils.LogError("FDISK", fmt.Sprintf("La partición '%s' está montada, desmóntela antes de eliminarla", name))
+		return fmt.Errorf("la partición '%s' está montada, desmóntela antes de eliminarla", name)
+	}
+
+	// Leer el MBR
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	// Buscar en particiones primarias y extendidas
+	if partition := mbr.GetParticionByName(name); partition != nil {
+		if partition.IsExtended() {
+			return deleteExtendedPartition(device, mbr, partition, mode)
+		}
+		return deletePrimaryPartition(path, mbr, partition, mode)
+	}
+
+	// Buscar en particiones lógicas
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		ebr, ebrPosition, err := estructuras.FindEBRByNameFromDevice(device, extendedPartition.PartStart, name)
+		if err == nil {
+			return deleteLogicalPartition(device, extendedPartition, ebr, ebrPosition, mode)
+		}
+	}
+
+	utils.LogError("FDISK", fmt.Sprintf("No se encontró una partición con el nombre '%s'", name))
+	return fmt.Errorf("no se encontró una partición con el nombre '%s'", name)
+}
+
+// deletePrimaryPartition elimina una partición primaria del MBR
+func deletePrimaryPartition(path string, mbr *estructuras.MBR, partition *estructuras.Partition, mode string) error {
+	name := partition.GetName()
+	start, size := partition.PartStart, partition.PartSize
+
+	// Limpiar la entrada en la tabla de particiones
+	partition.Delete()
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir MBR actualizado: %v", err)
+	}
+
+	// En modo full se rellena con ceros el espacio liberado
+	if mode == "full" {
+		if err := estructuras.ZeroRange(path, start, size); err != nil {
+			return fmt.Errorf("error al limpiar el espacio de la partición: %v", err)
+		}
+	}
+
+	utils.LogSuccess("FDISK", "Partición primaria eliminada exitosamente:")
+	logDeletedPartition(name, start, size, mode)
+
+	return nil
+}
+
+// deleteExtendedPartition elimina la partición extendida junto con todas sus lógicas
+func deleteExtendedPartition(device *estructuras.DiskDevice, mbr *estructuras.MBR, partition *estructuras.Partition, mode string) error {
+	path := device.Path()
+	name := partition.GetName()
+	start, size := partition.PartStart, partition.PartSize
+
+	// Leer la cadena de EBRs para verificar que ninguna lógica esté montada
+	chain, err := estructuras.ReadEBRChainFromDevice(device, start)
+	if err != nil {
+		return fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+	}
+
+	for _, node := range chain {
+		if !node.EBR.IsEmpty() && IsPartitionMounted(path, node.EBR.GetName()) {
+			utils.LogError("FDISK", fmt.Sprintf("La partición lógica '%s' está montada, desmóntela antes de eliminar la extendida", node.EBR.GetName()))
+			return fmt.Errorf("la partición lógica '%s' está montada, desmóntela antes de eliminar la extendida", node.EBR.GetName())
+		}
+	}
+
+	// Limpiar la entrada en la tabla de particiones
+	partition.Delete()
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir MBR actualizado: %v", err)
+	}
+
+	// Eliminar todas las particiones lógicas
+	if mode == "full" {
+		if err := estructuras.ZeroRange(path, start, size); err != nil {
+			return fmt.Errorf("error al limpiar el espacio de la partición extendida: %v", err)
+		}
+	} else {
+		for _, node := range chain {
+			if err := estructuras.WriteEBR(path, estructuras.NewEmptyEBR(), node.Position); err != nil {
+				return fmt.Errorf("error al limpiar EBR en posición %d: %v", node.Position, err)
+			}
+		}
+	}
+
+	utils.LogSuccess("FDISK", "Partición extendida eliminada exitosamente:")
+	logDeletedPartition(name, start, size, mode)
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Lógicas eliminadas: %d", countLogicalNodes(chain)))
+
+	return nil
+}
+
+// deleteLogicalPartition desenlaza el EBR de una partición lógica de la cadena
+func deleteLogicalPartition(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition, ebr *estructuras.EBR, ebrPosition int64, mode string) error {
+	path := device.Path()
+	name := ebr.GetName()
+	freedSize := ebr.GetEndPosition() - ebrPosition
+
+	if ebrPosition == extendedPartition.PartStart {
+		// El primer EBR no se puede desenlazar, queda como EBR vacío apuntando al siguiente
+		head := estructuras.NewEmptyEBR()
+		head.PartNext = ebr.PartNext
+		if err := estructuras.WriteEBR(path, head, ebrPosition); err != nil {
+			return fmt.Errorf("error al escribir EBR inicial: %v", err)
+		}
+	} else {
+		// Buscar el EBR anterior en la cadena
+		chain, err := estructuras.ReadEBRChainFromDevice(device, extendedPartition.PartStart)
+		if err != nil {
+			return fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+		}
+
+		var previous *estructuras.EBRNode
+		for i := range chain {
+			if chain[i].EBR.PartNext == ebrPosition {
+				previous = &chain[i]
+				break
+			}
+		}
+
+		if previous == nil {
+			return fmt.Errorf("no se encontró el EBR anterior a la posición %d", ebrPosition)
+		}
+
+		// Hacer que el EBR anterior apunte al siguiente de la lógica eliminada
+		previous.EBR.PartNext = ebr.PartNext
+		if err := estructuras.WriteEBR(path, previous.EBR, previous.Position); err != nil {
+			return fmt.Errorf("error al actualizar la cadena de EBRs: %v", err)
+		}
+
+		// Limpiar el EBR desenlazado
+		if err := estructuras.WriteEBR(path, estructuras.NewEmptyEBR(), ebrPosition); err != nil {
+			return fmt.Errorf("error al limpiar EBR: %v", err)
+		}
+	}
+
+	// En modo full se rellena con ceros el espacio de datos de la lógica
+	if mode == "full" {
+		if err := estructuras.ZeroRange(path, ebr.PartStart, ebr.PartSize); err != nil {
+			return fmt.Errorf("error al limpiar el espacio de la partición lógica: %v", err)
+		}
+	}
+
+	utils.LogSuccess("FDISK", "Partición lógica eliminada exitosamente:")
+	logDeletedPartition(name, ebrPosition, freedSize, mode)
+
+	return nil
+}
+
+// FdiskAdd agrega o quita espacio a una partición existente sin moverla
+func FdiskAdd(add int64, unit, path, name string) error {
+	utils.LogInfo("FDISK", fmt.Sprintf("Iniciando redimensionamiento de partición: path=%s, name=%s, add=%d, unit=%s",
+		path, name, add, unit))
+
+	// Validar parámetros obligatorios
+	if add == 0 {
+		utils.LogError("FDISK", "El valor de -add no puede ser cero")
+		return fmt.Errorf("el valor de -add no puede ser cero")
+	}
+
+	if path == "" {
+		utils.LogError("FDISK", "El parámetro -path es obligatorio")
+		return fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	if name == "" {
+		utils.LogError("FDISK", "El parámetro -name es obligatorio")
+		return fmt.Errorf("el parámetro -name es obligatorio")
+	}
+
+	// Validar que el disco existe; se abre una sola vez para todo el comando
+	device, err := openValidatedDisk(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	// Normalizar y validar la unidad
+	unit = normalizeUnit(unit)
+	if err := validateNormalizedParams(unit, "P", "WF"); err != nil {
+		return err
+	}
+
+	// Calcular el cambio en bytes
+	delta, err := calculateSizeInBytes(add, unit)
+	if err != nil {
+		return err
+	}
+
+	// No se puede redimensionar una partición montada
+	if IsPartitionMounted(path, name) {
+		utils.LogError("FDISK", fmt.Sprintf("La partición '%s' está montada, desmóntela antes de redimensionarla", name))
+		return fmt.Errorf("la partición '%s' está montada, desmóntela antes de redimensionarla", name)
+	}
+
+	// Leer el MBR
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	// Buscar en particiones primarias y extendidas
+	if partition := mbr.GetParticionByName(name); partition != nil {
+		return resizeMBRPartition(device, mbr, partition, delta)
+	}
+
+	// Buscar en particiones lógicas
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		ebr, ebrPosition, err := estructuras.FindEBRByNameFromDevice(device, extendedPartition.PartStart, name)
+		if err == nil {
+			return resizeLogicalPartition(device, extendedPartition, ebr, ebrPosition, delta)
+		}
+	}
+
+	utils.LogError("FDISK", fmt.Sprintf("No se encontró una partición con el nombre '%s'", name))
+	return fmt.Errorf("no se encontró una partición con el nombre '%s'", name)
+}
+
+// resizeMBRPartition cambia el tamaño de una partición primaria o extendida
+func resizeMBRPartition(device *estructuras.DiskDevice, mbr *estructuras.MBR, partition *estructuras.Partition, delta int64) error {
+	path := device.Path()
+	oldSize := partition.PartSize
+	newSize := oldSize + delta
+
+	if delta > 0 {
+		// Solo se puede crecer hacia el espacio libre contiguo al final de la partición
+		available := freeSpaceAt(mbr.GetFreeSpaces(), partition.GetEndPosition())
+		if delta > available {
+			utils.LogError("FDISK", fmt.Sprintf("Espacio insuficiente: se solicitaron %d bytes y hay %d bytes libres después de '%s'",
+				delta, available, partition.GetName()))
+			return fmt.Errorf("espacio insuficiente: se solicitaron %d bytes y hay %d bytes libres después de la partición '%s'",
+				delta, available, partition.GetName())
+		}
+	} else {
+		// El tamaño mínimo de una extendida es el que ocupan sus lógicas
+		minSize := int64(1)
+		if partition.IsExtended() {
+			used, err := getExtendedUsedEnd(device, partition)
+			if err != nil {
+				return err
+			}
+			minSize = used - partition.PartStart
+		}
+
+		if newSize < minSize {
+			utils.LogError("FDISK", fmt.Sprintf("No se puede reducir '%s' en %d bytes: como máximo se pueden quitar %d bytes",
+				partition.GetName(), -delta, oldSize-minSize))
+			return fmt.Errorf("no se puede reducir la partición '%s' en %d bytes: como máximo se pueden quitar %d bytes",
+				partition.GetName(), -delta, oldSize-minSize)
+		}
+	}
+
+	// Actualizar el tamaño y escribir el MBR
+	partition.PartSize = newSize
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir MBR actualizado: %v", err)
+	}
+
+	utils.LogSuccess("FDISK", "Partición redimensionada exitosamente:")
+	logResizedPartition(partition.GetName(), oldSize, newSize)
+
+	return nil
+}
+
+// resizeLogicalPartition cambia el tamaño de una partición lógica dentro de la extendida
+func resizeLogicalPartition(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition, ebr *estructuras.EBR, ebrPosition int64, delta int64) error {
+	path := device.Path()
+	oldSize := ebr.PartSize
+	newSize := oldSize + delta
+
+	if delta > 0 {
+		// Solo se puede crecer hacia el espacio libre contiguo dentro de la extendida
+		occupiedSpaces, err := getExtendedOccupiedSpaces(device, extendedPartition)
+		if err != nil {
+			return err
+		}
+
+		freeSpaces := findFreeSpacesInExtended(extendedPartition, occupiedSpaces)
+		available := freeSpaceAt(freeSpaces, ebr.GetEndPosition())
+		if delta > available {
+			utils.LogError("FDISK", fmt.Sprintf("Espacio insuficiente: se solicitaron %d bytes y hay %d bytes libres después de '%s'",
+				delta, available, ebr.GetName()))
+			return fmt.Errorf("espacio insuficiente: se solicitaron %d bytes y hay %d bytes libres después de la partición '%s'",
+				delta, available, ebr.GetName())
+		}
+	} else if newSize <= 0 {
+		utils.LogError("FDISK", fmt.Sprintf("No se puede reducir '%s' en %d bytes: como máximo se pueden quitar %d bytes",
+			ebr.GetName(), -delta, oldSize-1))
+		return fmt.Errorf("no se puede reducir la partición '%s' en %d bytes: como máximo se pueden quitar %d bytes",
+			ebr.GetName(), -delta, oldSize-1)
+	}
+
+	// Actualizar el tamaño y escribir el EBR
+	ebr.PartSize = newSize
+	if err := estructuras.WriteEBR(path, ebr, ebrPosition); err != nil {
+		return fmt.Errorf("error al escribir EBR actualizado: %v", err)
+	}
+
+	utils.LogSuccess("FDISK", "Partición lógica redimensionada exitosamente:")
+	logResizedPartition(ebr.GetName(), oldSize, newSize)
+
+	return nil
+}
+
+// FdiskMove mueve una partición existente (con sus datos) a un nuevo byte de inicio
+func FdiskMove(path, name string, start int64) error {
+	utils.LogInfo("FDISK", fmt.Sprintf("Iniciando movimiento de partición: path=%s, name=%s, start=%d", path, name, start))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("FDISK", "El parámetro -path es obligatorio")
+		return fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	if name == "" {
+		utils.LogError("FDISK", "El parámetro -name es obligatorio")
+		return fmt.Errorf("el parámetro -name es obligatorio")
+	}
+
+	if start < 0 {
+		utils.LogError("FDISK", "El valor de -start no puede ser negativo")
+		return fmt.Errorf("el valor de -start no puede ser negativo")
+	}
+
+	// Validar que el disco existe; se abre una sola vez para todo el comando
+	device, err := openValidatedDisk(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	// No se puede mover una partición montada
+	if IsPartitionMounted(path, name) {
+		utils.LogError("FDISK", fmt.Sprintf("La partición '%s' está montada, desmóntela antes de moverla", name))
+		return fmt.Errorf("la partición '%s' está montada, desmóntela antes de moverla", name)
+	}
+
+	// Leer el MBR
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	// Buscar en particiones primarias y extendidas
+	if partition := mbr.GetParticionByName(name); partition != nil {
+		return moveMBRPartition(path, mbr, partition, start)
+	}
+
+	// Buscar en particiones lógicas
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		ebr, ebrPosition, err := estructuras.FindEBRByNameFromDevice(device, extendedPartition.PartStart, name)
+		if err == nil {
+			return moveLogicalPartition(device, extendedPartition, ebr, ebrPosition, start)
+		}
+	}
+
+	utils.LogError("FDISK", fmt.Sprintf("No se encontró una partición con el nombre '%s'", name))
+	return fmt.Errorf("no se encontró una partición con el nombre '%s'", name)
+}
+
+// FdiskEncrypt cifra una partición primaria o lógica recién creada
+// Se escribe la cabecera de cifrado al inicio de sus datos; lo que hubiera en la partición se pierde
+func FdiskEncrypt(path, name, passphrase string) error {
+	utils.LogInfo("FDISK", fmt.Sprintf("Cifrando partición: path=%s, name=%s", path, name))
+
+	if IsPartitionMounted(path, name) {
+		return fmt.Errorf("la partición '%s' está montada, desmóntela antes de cifrarla", name)
+	}
+
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		return fmt.Errorf("error al abrir el disco: %v", err)
+	}
+	defer device.Close()
+
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	start, size := int64(-1), int64(0)
+	if partition := mbr.GetParticionByName(name); partition != nil {
+		if partition.IsExtended() {
+			return fmt.Errorf("no se puede cifrar una partición extendida, cifre sus particiones lógicas")
+		}
+		start, size = partition.PartStart, partition.PartSize
+	} else if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		if ebr, _, err := estructuras.FindEBRByNameFromDevice(device, extendedPartition.PartStart, name); err == nil {
+			start, size = ebr.PartStart, ebr.PartSize
+		}
+	}
+
+	if start < 0 {
+		utils.LogError("FDISK", fmt.Sprintf("No se encontró una partición con el nombre '%s'", name))
+		return fmt.Errorf("no se encontró una partición con el nombre '%s'", name)
+	}
+
+	if err := estructuras.EncryptPartition(path, start, size, passphrase); err != nil {
+		utils.LogError("FDISK", fmt.Sprintf("Error al cifrar la partición '%s': %v", name, err))
+		return fmt.Errorf("error al cifrar la partición '%s': %v", name, err)
+	}
+
+	utils.LogSuccess("FDISK", fmt.Sprintf("Partición '%s' cifrada (AES-256-XTS, PBKDF2-SHA256 con %d iteraciones)", name, estructuras.CRYPT_KDF_ITERATIONS))
+	return nil
+}
+
+// moveMBRPartition mueve una partición primaria o extendida dentro del disco
+func moveMBRPartition(path string, mbr *estructuras.MBR, partition *estructuras.Partition, start int64) error {
+	oldStart := partition.PartStart
+	if start == oldStart {
+		utils.LogInfo("FDISK", fmt.Sprintf("La partición '%s' ya inicia en el byte %d", partition.GetName(), start))
+		return nil
+	}
+
+	// El destino no puede traslaparse con ninguna otra partición
+	target := *partition
+	target.PartStart = start
+	for i := range mbr.MbrParticiones {
+		other := &mbr.MbrParticiones[i]
+		if other == partition || !other.IsActive() {
+			continue
+		}
+		if target.Overlaps(other) {
+			utils.LogError("FDISK", fmt.Sprintf("El destino se traslapa con la partición '%s'", other.GetName()))
+			return fmt.Errorf("el destino [%d, %d) se traslapa con la partición '%s'",
+				start, target.GetEndPosition(), other.GetName())
+		}
+	}
+
+	// El destino debe estar en espacio libre, contando como libre el espacio actual de la partición
+	withoutPartition := mbr.Clone()
+	for i := range withoutPartition.MbrParticiones {
+		if &mbr.MbrParticiones[i] == partition {
+			withoutPartition.MbrParticiones[i] = *estructuras.NewEmptyPartition()
+		}
+	}
+	if !rangeInFreeSpace(withoutPartition.GetFreeSpaces(), start, partition.PartSize) {
+		utils.LogError("FDISK", fmt.Sprintf("No hay espacio libre para '%s' en [%d, %d)", partition.GetName(), start, target.GetEndPosition()))
+		return fmt.Errorf("no hay espacio libre para la partición '%s' en [%d, %d)",
+			partition.GetName(), start, target.GetEndPosition())
+	}
+
+	// Copiar los datos (CopyRange maneja origen y destino traslapados)
+	if err := estructuras.CopyRange(path, oldStart, start, partition.PartSize); err != nil {
+		return fmt.Errorf("error al mover la partición '%s': %v", partition.GetName(), err)
+	}
+
+	// La cadena de EBRs usa posiciones absolutas, se debe desplazar
+	if partition.IsExtended() {
+		if err := shiftEBRChain(path, start, start-oldStart); err != nil {
+			return err
+		}
+	}
+
+	// Actualizar el inicio y escribir el MBR
+	partition.PartStart = start
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir MBR actualizado: %v", err)
+	}
+
+	utils.LogSuccess("FDISK", "Partición movida exitosamente:")
+	logMovedPartition(partition.GetName(), oldStart, start, partition.PartSize)
+
+	return nil
+}
+
+// moveLogicalPartition mueve una partición lógica dentro de la extendida y reordena la cadena de EBRs
+func moveLogicalPartition(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition, ebr *estructuras.EBR, ebrPosition int64, start int64) error {
+	path := device.Path()
+	oldStart := ebr.PartStart
+	if start == oldStart {
+		utils.LogInfo("FDISK", fmt.Sprintf("La partición '%s' ya inicia en el byte %d", ebr.GetName(), start))
+		return nil
+	}
+
+	// La lógica necesita su EBR justo antes de los datos, así que los datos deben iniciar
+	// al menos EBR_SIZE bytes después del inicio de la extendida
+	minStart := extendedPartition.PartStart + int64(estructuras.EBR_SIZE)
+	if start < minStart || start > extendedPartition.GetEndPosition()-ebr.PartSize {
+		utils.LogError("FDISK", fmt.Sprintf("El destino de '%s' está fuera de la partición extendida", ebr.GetName()))
+		return fmt.Errorf("el destino [%d, %d) de la partición '%s' está fuera de la partición extendida: los datos deben estar en [%d, %d)",
+			start, start+ebr.PartSize, ebr.GetName(), minStart, extendedPartition.GetEndPosition())
+	}
+	newEBRPosition := start - int64(estructuras.EBR_SIZE)
+	neededSize := int64(estructuras.EBR_SIZE) + ebr.PartSize
+
+	// Calcular los espacios libres sin contar el espacio actual de la lógica
+	occupiedSpaces, err := getExtendedOccupiedSpaces(device, extendedPartition)
 	if err != nil {
-		return fmt.Errorf("error al serializar MBR: %v", err)
+		return err
+	}
+	for i, occupied := range occupiedSpaces {
+		if occupied.Start == ebrPosition && occupied.Size == neededSize {
+			occupiedSpaces = append(occupiedSpaces[:i], occupiedSpaces[i+1:]...)
+			break
+		}
 	}
 
-	err = estructuras.WriteToDisk(path, mbrData, 0)
+	freeSpaces := findFreeSpacesInExtended(extendedPartition, occupiedSpaces)
+	if !rangeInFreeSpace(freeSpaces, newEBRPosition, neededSize) {
+		utils.LogError("FDISK", fmt.Sprintf("No hay espacio libre para '%s' en [%d, %d)", ebr.GetName(), newEBRPosition, start+ebr.PartSize))
+		return fmt.Errorf("no hay espacio libre para la partición '%s' en [%d, %d)",
+			ebr.GetName(), newEBRPosition, start+ebr.PartSize)
+	}
+
+	chain, err := estructuras.ReadEBRChainFromDevice(device, extendedPartition.PartStart)
 	if err != nil {
-		return fmt.Errorf("error al escribir MBR: %v", err)
+		return fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+	}
+
+	// Copiar los datos (CopyRange maneja origen y destino traslapados)
+	if err := estructuras.CopyRange(path, oldStart, start, ebr.PartSize); err != nil {
+		return fmt.Errorf("error al mover la partición lógica '%s': %v", ebr.GetName(), err)
+	}
+
+	// Armar la nueva cadena con la lógica en su nueva posición
+	head := chain[0]
+	var logicals []estructuras.EBRNode
+	for _, node := range chain {
+		if node.Position == ebrPosition {
+			// Si la lógica estaba en el primer EBR, este queda vacío al inicio de la extendida
+			if node.Position == head.Position {
+				head = estructuras.EBRNode{Position: head.Position, EBR: estructuras.NewEmptyEBR()}
+			}
+			continue
+		}
+		if node.Position != head.Position && !node.EBR.IsEmpty() {
+			logicals = append(logicals, node)
+		}
+	}
+
+	ebr.PartStart = start
+	logicals = append(logicals, estructuras.EBRNode{Position: newEBRPosition, EBR: ebr})
+
+	// Limpiar el EBR anterior si los datos movidos no lo sobrescribieron
+	oldEBRFreed := ebrPosition != head.Position &&
+		(ebrPosition+int64(estructuras.EBR_SIZE) <= newEBRPosition || ebrPosition >= start+ebr.PartSize)
+	if oldEBRFreed {
+		if err := estructuras.WriteEBR(path, estructuras.NewEmptyEBR(), ebrPosition); err != nil {
+			return fmt.Errorf("error al limpiar el EBR anterior: %v", err)
+		}
+	}
+
+	// Reescribir la cadena ordenada (actualiza PartNext de los EBRs afectados)
+	if err := writeSortedEBRChain(path, head, logicals); err != nil {
+		return err
+	}
+
+	utils.LogSuccess("FDISK", "Partición lógica movida exitosamente:")
+	logMovedPartition(ebr.GetName(), oldStart, start, ebr.PartSize)
+
+	return nil
+}
+
+// Helper functions
+
+// writeUpdatedMBR escribe el MBR actualizado al disco (en discos GPT también la tabla GPT y su respaldo)
+func writeUpdatedMBR(path string, mbr *estructuras.MBR) error {
+	if err := estructuras.WritePartitionTable(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir la tabla de particiones: %v", err)
 	}
 
 	return nil
@@ -468,8 +1232,8 @@ func createInitialEBR(path string, extendedStart int64) error {
 }
 
 // checkLogicalPartitionNameExists verifica si ya existe una partición lógica con el nombre dado
-func checkLogicalPartitionNameExists(path string, extendedStart int64, name string) error {
-	_, _, err := estructuras.FindEBRByName(path, extendedStart, name)
+func checkLogicalPartitionNameExists(device *estructuras.DiskDevice, extendedStart int64, name string) error {
+	_, _, err := estructuras.FindEBRByNameFromDevice(device, extendedStart, name)
 	if err == nil {
 		utils.LogError("FDISK", fmt.Sprintf("Ya existe una partición lógica con el nombre '%s'", name))
 		return fmt.Errorf("ya existe una partición lógica con el nombre '%s'", name)
@@ -477,12 +1241,12 @@ func checkLogicalPartitionNameExists(path string, extendedStart int64, name stri
 	return nil // No existe, está bien
 }
 
-// findLogicalPartitionSpace encuentra espacio disponible dentro de la partición extendida
-func findLogicalPartitionSpace(path string, extendedPartition *estructuras.Partition, sizeInBytes int64, fit string) (int64, error) {
+// getExtendedOccupiedSpaces calcula los espacios ocupados (EBR + datos) dentro de la partición extendida
+func getExtendedOccupiedSpaces(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition) ([]estructuras.FreeSpace, error) {
 	// Obtener todos los EBRs existentes
-	ebrs, err := estructuras.ReadAllEBRs(path, extendedPartition.PartStart)
+	ebrs, err := estructuras.ReadAllEBRsFromDevice(device, extendedPartition.PartStart)
 	if err != nil {
-		return 0, fmt.Errorf("error al leer EBRs existentes: %v", err)
+		return nil, fmt.Errorf("error al leer EBRs existentes: %v", err)
 	}
 
 	// Calcular espacios ocupados dentro de la partición extendida
@@ -501,45 +1265,93 @@ func findLogicalPartitionSpace(path string, extendedPartition *estructuras.Parti
 		}
 	}
 
-	// Encontrar espacios libres
+	return occupiedSpaces, nil
+}
+
+// placeMBRPartition calcula el inicio y el tamaño de una partición primaria o extendida nueva
+func placeMBRPartition(mbr *estructuras.MBR, sizeInBytes int64, placement FdiskPlacement) (int64, int64, error) {
+	// El porcentaje es del espacio utilizable del disco (sin la tabla de particiones)
+	if placement.SizeMode == SizePercent {
+		sizeInBytes = (mbr.LastUsableByte() - mbr.FirstUsableByte()) * sizeInBytes / 100
+	}
+
+	if placement.Start >= 0 || placement.SizeMode == SizeRest {
+		return placeInFreeSpaces(mbr.GetFreeSpaces(), sizeInBytes, 0, mbr.MbrFit, placement)
+	}
+
+	start, err := mbr.FindAlignedFitPosition(sizeInBytes, placement.Align)
+	return start, sizeInBytes, err
+}
+
+// placeLogicalPartition calcula la posición del EBR y el tamaño de una partición lógica nueva
+func placeLogicalPartition(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition, sizeInBytes int64, fitByte byte, placement FdiskPlacement) (int64, int64, error) {
+	// El porcentaje es del tamaño de la extendida
+	if placement.SizeMode == SizePercent {
+		sizeInBytes = extendedPartition.PartSize * sizeInBytes / 100
+	}
+
+	occupiedSpaces, err := getExtendedOccupiedSpaces(device, extendedPartition)
+	if err != nil {
+		return 0, 0, err
+	}
 	freeSpaces := findFreeSpacesInExtended(extendedPartition, occupiedSpaces)
 
-	// Aplicar algoritmo de ajuste
-	neededSize := sizeInBytes + int64(estructuras.EBR_SIZE) // EBR + datos
+	return placeInFreeSpaces(freeSpaces, sizeInBytes, int64(estructuras.EBR_SIZE), fitByte, placement)
+}
 
-	fitByte := estructuras.ValidateFit(fit)
-	switch fitByte {
-	case estructuras.PartitionFitFirst:
-		for _, space := range freeSpaces {
-			if space.Size >= neededSize {
-				return space.Start, nil
-			}
+// placeInFreeSpaces ubica una partición en los espacios libres y retorna su posición y tamaño
+// dataOffset es el espacio antes de los datos (el EBR de una lógica); la posición retornada lo incluye,
+// pero -start y -align se aplican al inicio de los datos. Con rest se usa el espacio más grande.
+func placeInFreeSpaces(freeSpaces []estructuras.FreeSpace, sizeInBytes, dataOffset int64, fitByte byte, placement FdiskPlacement) (int64, int64, error) {
+	// Espacios libres vistos desde el inicio de los datos
+	var dataSpaces []estructuras.FreeSpace
+	for _, space := range freeSpaces {
+		if space.Size > dataOffset {
+			dataSpaces = append(dataSpaces, estructuras.FreeSpace{Start: space.Start + dataOffset, Size: space.Size - dataOffset})
 		}
-	case estructuras.PartitionFitBest:
-		bestSpace := estructuras.FreeSpace{Size: extendedPartition.PartSize + 1}
-		found := false
-		for _, space := range freeSpaces {
-			if space.Size >= neededSize && space.Size < bestSpace.Size {
-				bestSpace = space
-				found = true
+	}
+
+	// Inicio exacto: debe estar dentro de un espacio libre con lugar para los datos
+	if placement.Start >= 0 {
+		for _, space := range dataSpaces {
+			if !space.Contains(placement.Start) {
+				continue
 			}
-		}
-		if found {
-			return bestSpace.Start, nil
-		}
-	case estructuras.PartitionFitWorst:
-		worstSpace := estructuras.FreeSpace{Size: -1}
-		for _, space := range freeSpaces {
-			if space.Size >= neededSize && space.Size > worstSpace.Size {
-				worstSpace = space
+			if placement.SizeMode == SizeRest {
+				sizeInBytes = space.GetEndPosition() - placement.Start
 			}
+			if placement.Start+sizeInBytes > space.GetEndPosition() {
+				return 0, 0, fmt.Errorf("solo hay %d bytes libres desde el byte %d, se necesitan %d",
+					space.GetEndPosition()-placement.Start, placement.Start, sizeInBytes)
+			}
+			return placement.Start - dataOffset, sizeInBytes, nil
 		}
-		if worstSpace.Size >= neededSize {
-			return worstSpace.Start, nil
-		}
+		return 0, 0, fmt.Errorf("el byte %d no está en un espacio libre", placement.Start)
+	}
+
+	alignedSpaces := make([]estructuras.FreeSpace, 0, len(dataSpaces))
+	for _, space := range dataSpaces {
+		alignedSpaces = append(alignedSpaces, space.Aligned(placement.Align))
+	}
+
+	// rest ocupa el espacio libre más grande
+	if placement.SizeMode == SizeRest {
+		fitByte = estructuras.PartitionFitWorst
+		sizeInBytes = 1
+	}
+
+	space, found := estructuras.SelectFreeSpace(alignedSpaces, sizeInBytes, fitByte)
+	if !found && placement.SizeMode == SizeRest {
+		return 0, 0, fmt.Errorf("no hay espacio libre")
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("no hay espacio suficiente para %d bytes", sizeInBytes)
+	}
+	if placement.SizeMode == SizeRest {
+		sizeInBytes = space.Size
 	}
 
-	return 0, fmt.Errorf("no hay espacio suficiente en la partición extendida")
+	return space.Start - dataOffset, sizeInBytes, nil
 }
 
 // findFreeSpacesInExtended encuentra espacios libres dentro de la partición extendida
@@ -580,74 +1392,106 @@ func findFreeSpacesInExtended(extendedPartition *estructuras.Partition, occupied
 	return freeSpaces
 }
 
-// findNextEBRPosition encuentra la posición del siguiente EBR en la cadena
-func findNextEBRPosition(path string, extendedStart, afterPosition int64) int64 {
-	// Buscar el siguiente EBR después de la posición especificada
-	currentPos := extendedStart
+// updateEBRChain escribe un EBR nuevo y lo enlaza en la cadena, ordenada por posición
+// El EBR nuevo se escribe completo (apuntando al siguiente) antes de enlazarlo, de modo
+// que la cadena solo cambia con una única escritura: la del EBR anterior.
+func updateEBRChain(device *estructuras.DiskDevice, extendedStart int64, newEBR *estructuras.EBR, newEBRPosition int64) error {
+	path := device.Path()
+	chain, err := estructuras.ReadEBRChainFromDevice(device, extendedStart)
+	if err != nil {
+		return err
+	}
 
-	for currentPos != -1 {
-		ebr, err := estructuras.ReadEBR(path, currentPos)
-		if err != nil {
-			break
+	// El EBR anterior es el de mayor posición antes del nuevo (el primero siempre está al inicio de la extendida)
+	previous := chain[0]
+	for _, node := range chain {
+		if node.Position < newEBRPosition && node.Position > previous.Position {
+			previous = node
 		}
+	}
 
-		if ebr.PartStart > afterPosition {
-			return currentPos
-		}
+	newEBR.PartNext = previous.EBR.PartNext
+	if err := estructuras.WriteEBR(path, newEBR, newEBRPosition); err != nil {
+		return fmt.Errorf("error al escribir EBR: %v", err)
+	}
 
-		currentPos = ebr.PartNext
+	previous.EBR.PartNext = newEBRPosition
+	if err := estructuras.WriteEBR(path, previous.EBR, previous.Position); err != nil {
+		return fmt.Errorf("error al enlazar el EBR en la posición %d: %v", previous.Position, err)
 	}
 
-	return -1 // No hay siguiente EBR
+	return nil
 }
 
-// updateEBRChain actualiza la cadena de EBRs para mantener la consistencia
-func updateEBRChain(path string, extendedStart, newEBRPosition int64) error {
-	// Encontrar el EBR anterior que debe apuntar al nuevo EBR
-	currentPos := extendedStart
-	var previousPos int64 = -1
-
-	for currentPos != -1 && currentPos < newEBRPosition {
-		ebr, err := estructuras.ReadEBR(path, currentPos)
-		if err != nil {
-			return err
-		}
-
-		if ebr.PartNext == -1 || ebr.PartNext > newEBRPosition {
-			// Este EBR debe apuntar al nuevo EBR
-			previousPos = currentPos
-			break
+// freeSpaceAt retorna el tamaño del espacio libre que inicia exactamente en la posición dada
+func freeSpaceAt(freeSpaces []estructuras.FreeSpace, position int64) int64 {
+	for _, space := range freeSpaces {
+		if space.Start == position {
+			return space.Size
 		}
+	}
+	return 0
+}
 
-		previousPos = currentPos
-		currentPos = ebr.PartNext
+// getExtendedUsedEnd calcula el byte final ocupado por el último EBR o lógica de la extendida
+func getExtendedUsedEnd(device *estructuras.DiskDevice, extendedPartition *estructuras.Partition) (int64, error) {
+	occupiedSpaces, err := getExtendedOccupiedSpaces(device, extendedPartition)
+	if err != nil {
+		return 0, err
 	}
 
-	// Actualizar el EBR anterior para que apunte al nuevo EBR
-	if previousPos != -1 {
-		prevEBR, err := estructuras.ReadEBR(path, previousPos)
-		if err != nil {
-			return err
+	usedEnd := extendedPartition.PartStart
+	for _, occupied := range occupiedSpaces {
+		if occupied.GetEndPosition() > usedEnd {
+			usedEnd = occupied.GetEndPosition()
 		}
+	}
 
-		oldNext := prevEBR.PartNext
-		prevEBR.PartNext = newEBRPosition
+	return usedEnd, nil
+}
 
-		if err := estructuras.WriteEBR(path, prevEBR, previousPos); err != nil {
-			return err
+// rangeInFreeSpace verifica si el rango [start, start+size) cabe completo en alguno de los espacios libres
+func rangeInFreeSpace(freeSpaces []estructuras.FreeSpace, start, size int64) bool {
+	for _, space := range freeSpaces {
+		if start >= space.Start && start+size <= space.GetEndPosition() {
+			return true
 		}
+	}
+	return false
+}
 
-		// Hacer que el nuevo EBR apunte al siguiente en la cadena original
-		newEBR, err := estructuras.ReadEBR(path, newEBRPosition)
-		if err != nil {
-			return err
-		}
+// logMovedPartition registra información de la partición movida
+func logMovedPartition(name string, oldStart, newStart, size int64) {
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Nombre: %s", name))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Inicio anterior: %d", oldStart))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Inicio nuevo: %d", newStart))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Tamaño: %d bytes", size))
+}
 
-		newEBR.PartNext = oldNext
-		return estructuras.WriteEBR(path, newEBR, newEBRPosition)
+// logResizedPartition registra información de la partición redimensionada
+func logResizedPartition(name string, oldSize, newSize int64) {
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Nombre: %s", name))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Tamaño anterior: %d bytes", oldSize))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Tamaño nuevo: %d bytes", newSize))
+}
+
+// countLogicalNodes cuenta las particiones lógicas (EBRs no vacíos) de una cadena
+func countLogicalNodes(chain []estructuras.EBRNode) int {
+	count := 0
+	for _, node := range chain {
+		if !node.EBR.IsEmpty() {
+			count++
+		}
 	}
+	return count
+}
 
-	return nil
+// logDeletedPartition registra información de la partición eliminada
+func logDeletedPartition(name string, start, size int64, mode string) {
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Nombre: %s", name))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Espacio liberado: %d bytes", size))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Inicio: %d", start))
+	utils.LogSuccess("FDISK", fmt.Sprintf("  → Modo: %s", mode))
 }
 
 // logPartitionInfo registra información de la partición creada
@@ -0,0 +1,353 @@
+package disk
+
+/*
+ * Montaje automático al estilo fstab. Un archivo de texto lista las particiones
+ * que se montan al iniciar el backend (y con mount -all), una por línea:
+ *
+ *   # <disco>                    <partición>  <id>   <opciones>
+ *   /home/user/Discos/Disco1.mia  Part1        841A   defaults
+ *   "/home/user/Mis Discos/D2.mia" Logica1     -      noauto
+ *
+ * El ID es opcional ("-" o ausente para generarlo al montar). Las opciones se
+ * separan por comas: defaults (sin efecto) y noauto (no se monta al iniciar ni
+ * con mount -all, pero sí se desmonta con unmount -all). Las líneas vacías y las
+ * que inician con # se ignoran.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultFstabPath es el archivo de montaje automático si no se configura otro
+const DefaultFstabPath = "./fstab"
+
+// Opciones de una entrada del archivo de montaje automático
+const (
+	FstabOptionDefaults = "defaults"
+	FstabOptionNoAuto   = "noauto"
+)
+
+// Estados del resultado de una entrada
+const (
+	FstabMounted        = "mounted"         // Se montó
+	FstabAlreadyMounted = "already_mounted" // Ya estaba montada (con el ID pedido, si hay)
+	FstabSkipped        = "skipped"         // Tiene noauto
+	FstabUnmounted      = "unmounted"       // Se desmontó
+	FstabNotMounted     = "not_mounted"     // No estaba montada
+	FstabFailed         = "failed"          // Error al leer la línea o al montar/desmontar
+)
+
+// FstabEntry es una línea del archivo de montaje automático
+type FstabEntry struct {
+	Line    int      `json:"line"`    // Número de línea en el archivo
+	Path    string   `json:"path"`    // Ruta del disco
+	Name    string   `json:"name"`    // Nombre de la partición
+	ID      string   `json:"id"`      // ID deseado, vacío para generarlo al montar
+	Options []string `json:"options"` // Opciones de montaje
+}
+
+// HasOption indica si la entrada tiene una opción
+func (e *FstabEntry) HasOption(option string) bool {
+	for _, current := range e.Options {
+		if current == option {
+			return true
+		}
+	}
+	return false
+}
+
+// FstabResult es el resultado de aplicar una entrada
+type FstabResult struct {
+	FstabEntry
+	Status    string `json:"status"`          // Uno de los estados Fstab*
+	MountedID string `json:"mounted_id"`      // ID con el que quedó (o estaba) montada
+	Error     string `json:"error,omitempty"` // Motivo del fallo
+}
+
+// FstabReport resume la aplicación del archivo de montaje automático
+type FstabReport struct {
+	File    string        `json:"file"`    // Archivo aplicado
+	Results []FstabResult `json:"results"` // Resultado de cada entrada, en el orden del archivo
+	Failed  int           `json:"failed"`  // Entradas con error
+}
+
+// add agrega un resultado al reporte
+func (r *FstabReport) add(result FstabResult) {
+	if result.Status == FstabFailed {
+		r.Failed++
+		utils.LogWarning("FSTAB", fmt.Sprintf("Línea %d (%s '%s'): %s", result.Line, result.Path, result.Name, result.Error))
+	}
+	r.Results = append(r.Results, result)
+}
+
+var (
+	fstabMutex sync.RWMutex
+	fstabPath  = DefaultFstabPath
+)
+
+// SetFstabPath configura el archivo de montaje automático
+func SetFstabPath(path string) {
+	fstabMutex.Lock()
+	defer fstabMutex.Unlock()
+
+	if path == "" {
+		path = DefaultFstabPath
+	}
+	fstabPath = path
+	utils.LogInfo("FSTAB", fmt.Sprintf("Archivo de montaje automático: %s", path))
+}
+
+// GetFstabPath retorna el archivo de montaje automático configurado
+func GetFstabPath() string {
+	fstabMutex.RLock()
+	defer fstabMutex.RUnlock()
+
+	return fstabPath
+}
+
+// ReadFstab lee las entradas del archivo de montaje automático
+// Las líneas mal formadas se retornan como resultados fallidos para reportarlas sin detener el resto
+func ReadFstab(path string) ([]FstabEntry, []FstabResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no se pudo abrir el archivo de montaje automático %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []FstabEntry
+	var invalid []FstabResult
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseFstabLine(line)
+		entry.Line = lineNumber
+		if err != nil {
+			invalid = append(invalid, FstabResult{FstabEntry: entry, Status: FstabFailed, Error: err.Error()})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error al leer el archivo de montaje automático %s: %v", path, err)
+	}
+
+	return entries, invalid, nil
+}
+
+// parseFstabLine interpreta una línea: disco, partición, ID opcional y opciones opcionales
+func parseFstabLine(line string) (FstabEntry, error) {
+	fields, err := splitFstabFields(line)
+	if err != nil {
+		return FstabEntry{}, err
+	}
+
+	if len(fields) < 2 || len(fields) > 4 {
+		return FstabEntry{}, fmt.Errorf("se esperaban de 2 a 4 campos (disco, partición, id, opciones), hay %d", len(fields))
+	}
+
+	entry := FstabEntry{Path: fields[0], Name: fields[1], Options: []string{}}
+	if len(fields) > 2 && fields[2] != "-" {
+		entry.ID = strings.ToUpper(fields[2])
+	}
+
+	if len(fields) > 3 {
+		for _, option := range strings.Split(fields[3], ",") {
+			option = strings.ToLower(strings.TrimSpace(option))
+			switch option {
+			case FstabOptionDefaults, FstabOptionNoAuto:
+				entry.Options = append(entry.Options, option)
+			default:
+				return entry, fmt.Errorf("opción no válida '%s', use defaults o noauto", option)
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+// splitFstabFields separa una línea en campos por espacios, respetando las comillas dobles
+func splitFstabFields(line string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inQuotes, hasField := false, false
+
+	for _, char := range line {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case (char == ' ' || char == '\t') && !inQuotes:
+			if hasField {
+				fields = append(fields, current.String())
+				current.Reset()
+				hasField = false
+			}
+		default:
+			current.WriteRune(char)
+			hasField = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("comillas sin cerrar")
+	}
+	if hasField {
+		fields = append(fields, current.String())
+	}
+
+	return fields, nil
+}
+
+// FstabDisks retorna los discos que aparecen en el archivo de montaje automático, sin repetir
+func FstabDisks() []string {
+	entries, _, err := ReadFstab(GetFstabPath())
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var disks []string
+	for _, entry := range entries {
+		if !seen[entry.Path] {
+			seen[entry.Path] = true
+			disks = append(disks, entry.Path)
+		}
+	}
+	return disks
+}
+
+// MountAll monta las entradas del archivo de montaje automático que no tienen noauto
+// Las entradas con ID se montan primero para que los IDs generados no los ocupen
+func MountAll() (*FstabReport, error) {
+	path := GetFstabPath()
+	utils.LogInfo("FSTAB", fmt.Sprintf("Montando particiones de %s", path))
+
+	entries, invalid, err := ReadFstab(path)
+	if err != nil {
+		utils.LogError("FSTAB", err.Error())
+		return nil, err
+	}
+
+	results := make([]FstabResult, len(entries))
+	for _, withID := range []bool{true, false} {
+		for i, entry := range entries {
+			if (entry.ID != "") == withID {
+				results[i] = mountFstabEntry(entry)
+			}
+		}
+	}
+
+	report := newFstabReport(path, invalid, results)
+	utils.LogSuccess("FSTAB", fmt.Sprintf("Montaje automático aplicado: %d entradas, %d con errores", len(report.Results), report.Failed))
+
+	return report, nil
+}
+
+// UnmountAll desmonta las particiones montadas que aparecen en el archivo de montaje automático
+func UnmountAll() (*FstabReport, error) {
+	path := GetFstabPath()
+	utils.LogInfo("FSTAB", fmt.Sprintf("Desmontando particiones de %s", path))
+
+	entries, invalid, err := ReadFstab(path)
+	if err != nil {
+		utils.LogError("FSTAB", err.Error())
+		return nil, err
+	}
+
+	results := make([]FstabResult, len(entries))
+	for i, entry := range entries {
+		results[i] = FstabResult{FstabEntry: entry, Status: FstabNotMounted}
+
+		id, mounted := findMountedID(entry.Path, entry.Name)
+		if !mounted {
+			continue
+		}
+
+		results[i].MountedID = id
+		if err := Unmount(id); err != nil {
+			results[i].Status = FstabFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = FstabUnmounted
+	}
+
+	report := newFstabReport(path, invalid, results)
+	utils.LogSuccess("FSTAB", fmt.Sprintf("Desmontaje automático aplicado: %d entradas, %d con errores", len(report.Results), report.Failed))
+
+	return report, nil
+}
+
+// mountFstabEntry monta una entrada y retorna su resultado
+func mountFstabEntry(entry FstabEntry) FstabResult {
+	result := FstabResult{FstabEntry: entry}
+
+	if entry.HasOption(FstabOptionNoAuto) {
+		result.Status = FstabSkipped
+		return result
+	}
+
+	// Una partición ya montada (por ejemplo, restaurada desde el disco) no se vuelve a montar
+	if id, mounted := findMountedID(entry.Path, entry.Name); mounted {
+		result.MountedID = id
+		result.Status = FstabAlreadyMounted
+		if entry.ID != "" && entry.ID != id {
+			result.Status = FstabFailed
+			result.Error = fmt.Sprintf("ya está montada con el ID %s, no con %s", id, entry.ID)
+		}
+		return result
+	}
+
+	id, err := MountWithID(entry.Path, entry.Name, entry.ID, "")
+	if err != nil {
+		result.Status = FstabFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.MountedID = id
+	result.Status = FstabMounted
+	return result
+}
+
+// newFstabReport arma el reporte con los resultados en el orden de las líneas del archivo
+func newFstabReport(path string, invalid, results []FstabResult) *FstabReport {
+	report := &FstabReport{File: path, Results: []FstabResult{}}
+
+	for len(invalid) > 0 || len(results) > 0 {
+		if len(results) == 0 || (len(invalid) > 0 && invalid[0].Line < results[0].Line) {
+			report.add(invalid[0])
+			invalid = invalid[1:]
+		} else {
+			report.add(results[0])
+			results = results[1:]
+		}
+	}
+
+	return report
+}
+
+// findMountedID busca el ID de una partición montada por disco y nombre
+func findMountedID(path, name string) (string, bool) {
+	mountSystem.mutex.RLock()
+	defer mountSystem.mutex.RUnlock()
+
+	for id, partition := range mountSystem.mountedPartitions {
+		if estructuras.SameDisk(partition.Path, path) && partition.Name == name {
+			return id, true
+		}
+	}
+	return "", false
+}
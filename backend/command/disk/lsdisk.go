@@ -0,0 +1,135 @@
+package disk
+
+/*
+ * LSDISK - Este comando lista las particiones de un disco, incluyendo las
+ * lógicas de la partición extendida en el orden de su cadena de EBRs, junto
+ * con el ID de montaje de las que están montadas.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                      |
+|-----------|--------------|--------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco cuyas particiones se listarán.    |
+*/
+
+// LsDiskEntry describe una partición del disco
+type LsDiskEntry struct {
+	Name        string `json:"name"`                   // Nombre de la partición
+	Type        string `json:"type"`                   // Primaria, Extendida o Lógica
+	Fit         string `json:"fit"`                    // Tipo de ajuste
+	Start       int64  `json:"start"`                  // Byte donde inician los datos
+	Size        int64  `json:"size"`                   // Tamaño en bytes
+	End         int64  `json:"end"`                    // Byte final (exclusivo)
+	Mounted     bool   `json:"mounted"`                // La partición está montada
+	ID          string `json:"id,omitempty"`           // ID de montaje
+	EBRPosition int64  `json:"ebr_position,omitempty"` // Posición del EBR (solo lógicas)
+}
+
+// LsDiskResult es el resultado del comando lsdisk
+type LsDiskResult struct {
+	Path       string        `json:"path"`       // Ruta del disco
+	Size       int64         `json:"size"`       // Tamaño del disco
+	TableType  string        `json:"table_type"` // Tipo de tabla de particiones
+	Usage      float64       `json:"usage"`      // Porcentaje de uso del disco
+	Partitions []LsDiskEntry `json:"partitions"` // Particiones en orden de la tabla
+}
+
+// LsDisk lista las particiones primarias, extendidas y lógicas de un disco
+func LsDisk(path string) (*LsDiskResult, error) {
+	utils.LogInfo("LSDISK", fmt.Sprintf("Listando particiones del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("LSDISK", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	result, err := readDiskPartitions(path)
+	if err != nil {
+		utils.LogError("LSDISK", err.Error())
+		return nil, err
+	}
+
+	for _, entry := range result.Partitions {
+		utils.LogInfo("LSDISK", fmt.Sprintf("  → %-16s %-9s [%d, %d) %d bytes %s", entry.Name, entry.Type, entry.Start, entry.End, entry.Size, entry.ID))
+	}
+	utils.LogSuccess("LSDISK", fmt.Sprintf("%d particiones en %s (%.2f%% de uso)", len(result.Partitions), path, result.Usage))
+
+	return result, nil
+}
+
+// readDiskPartitions lee la tabla y la cadena de EBRs del disco y las cruza con los montajes
+func readDiskPartitions(path string) (*LsDiskResult, error) {
+	info, err := estructuras.GetDiskInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := estructuras.ListPartitions(path)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := estructuras.GetDiskUsage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LsDiskResult{
+		Path:       path,
+		Size:       info.Size,
+		TableType:  info.TableType,
+		Usage:      usage,
+		Partitions: []LsDiskEntry{},
+	}
+
+	mounts := getDiskMounts(path)
+	for _, partition := range partitions {
+		id, mounted := mounts[partition.GetName()]
+		result.Partitions = append(result.Partitions, LsDiskEntry{
+			Name:    partition.GetName(),
+			Type:    partition.GetTypeString(),
+			Fit:     partition.GetFitString(),
+			Start:   partition.PartStart,
+			Size:    partition.PartSize,
+			End:     partition.GetEndPosition(),
+			Mounted: mounted,
+			ID:      id,
+		})
+
+		if !partition.IsExtended() {
+			continue
+		}
+
+		chain, err := estructuras.ReadEBRChain(path, partition.PartStart)
+		if err != nil {
+			return nil, fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+		}
+
+		for _, node := range chain {
+			if node.EBR.IsEmpty() {
+				continue
+			}
+			id, mounted := mounts[node.EBR.GetName()]
+			result.Partitions = append(result.Partitions, LsDiskEntry{
+				Name:        node.EBR.GetName(),
+				Type:        "Lógica",
+				Fit:         node.EBR.GetFitString(),
+				Start:       node.EBR.PartStart,
+				Size:        node.EBR.PartSize,
+				End:         node.EBR.GetEndPosition(),
+				Mounted:     mounted,
+				ID:          id,
+				EBRPosition: node.Position,
+			})
+		}
+	}
+
+	return result, nil
+}
@@ -3,7 +3,8 @@ package disk
 /*
  * Este comando creará un archivo binario que simulará un disco, estos archivos
  * binarios tendrán la extensión .mia y su contenido al inicio será 0 binarios.
- * Deberá ocupar físicamente el tamaño indicado por los parámetros
+ * Deberá ocupar físicamente el tamaño indicado por los parámetros, salvo con
+ * -format=qmia, donde el archivo solo crece con los datos que se escriben.
  */
 
 import (
@@ -19,13 +20,15 @@ import (
 |-----------|------------|-------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------|
 | -size     | Obligatorio | Recibe un número que indica el tamaño del disco a crear. Debe ser positivo y mayor que cero, de lo contrario se mostrará un error.                                                                                                                                                                                                                              |
 | -fit      | Opcional    | Indica el ajuste para crear particiones dentro del disco. Valores posibles: <br>BF: Mejor ajuste (Best Fit)<br>FF: Primer ajuste (First Fit)<br>WF: Peor ajuste (Worst Fit)<br>Si no se especifica, se usa FF. Si se usa otro valor, se muestra un mensaje de error.                                                     |
-| -unit     | Opcional    | Recibe una letra que indica las unidades para el parámetro size. Valores posibles:<br>K: Kilobytes (1024 bytes)<br>M: Megabytes (1024 * 1024 bytes)<br>Si no se especifica, se usa Megabytes. Si se usa otro valor, se muestra un mensaje de error.                                                                      |
-| -path     | Obligatorio | Ruta donde se creará el archivo que representa el disco duro. Si las carpetas de la ruta no existen, deben crearse.                                                                                                                                                                                                      |
+| -unit     | Opcional    | Recibe una letra que indica las unidades para el parámetro size. Valores posibles:<br>K: Kilobytes (1024 bytes)<br>M: Megabytes (1024 * 1024 bytes)<br>G: Gigabytes (1024 * 1024 * 1024 bytes)<br>Si no se especifica, se usa Megabytes. Si se usa otro valor, se muestra un mensaje de error.                                 |
+| -path     | Obligatorio | Ruta donde se creará el archivo que representa el disco duro. Si las carpetas de la ruta no existen, deben crearse. Con mem://<nombre> el disco se crea en memoria.                                                                                                                                                      |
+| -table    | Opcional    | Tipo de tabla de particiones del disco. Valores posibles:<br>MBR: Tabla MBR con 4 particiones (primarias o extendida)<br>GPT: Tabla GPT con 128 particiones primarias, con copia de respaldo al final del disco<br>Si no se especifica, se usa MBR. Si se usa otro valor, se muestra un mensaje de error.                 |
+| -format   | Opcional    | Formato del archivo del disco. Valores posibles:<br>RAW: Archivo con el tamaño completo del disco<br>QMIA: Contenedor con clusters comprimidos que ocupan espacio al escribirse<br>Si no se especifica, se usa RAW. Los discos en memoria solo admiten RAW. Se puede cambiar después con convertdisk. |
 */
 
 // MkDisk crea un archivo binario que simula un disco duro
-func MkDisk(size int64, fit string, unit string, path string) error {
-	utils.LogInfo("MkDisk", fmt.Sprintf("Iniciando creación de disco: size=%d, fit=%s, unit=%s, path=%s", size, fit, unit, path))
+func MkDisk(size int64, fit string, unit string, path string, table string, format string) error {
+	utils.LogInfo("MkDisk", fmt.Sprintf("Iniciando creación de disco: size=%d, fit=%s, unit=%s, path=%s, table=%s, format=%s", size, fit, unit, path, table, format))
 
 	// Validar tamaño
 	if size <= 0 {
@@ -59,14 +62,37 @@ func MkDisk(size int64, fit string, unit string, path string) error {
 		case "M":
 			unitMultiplier = 1024 * 1024
 			unitName = "Megabytes"
+		case "G":
+			unitMultiplier = 1024 * 1024 * 1024
+			unitName = "Gigabytes"
 		default:
-			utils.LogError("MkDisk", fmt.Sprintf("Unidad no válida '%s', use K para Kilobytes o M para Megabytes", unit))
-			return fmt.Errorf("unidad no válida '%s', use K para Kilobytes o M para Megabytes", unit)
+			utils.LogError("MkDisk", fmt.Sprintf("Unidad no válida '%s', use K para Kilobytes, M para Megabytes o G para Gigabytes", unit))
+			return fmt.Errorf("unidad no válida '%s', use K para Kilobytes, M para Megabytes o G para Gigabytes", unit)
 		}
 	} else {
 		utils.LogInfo("MkDisk", "Usando unidad por defecto: Megabytes")
 	}
 
+	// Validar y normalizar el tipo de tabla
+	table = strings.ToUpper(strings.TrimSpace(table))
+	if table == "" {
+		table = "MBR"
+	}
+	if table != "MBR" && table != "GPT" {
+		utils.LogError("MkDisk", fmt.Sprintf("Tipo de tabla no válido '%s', use MBR o GPT", table))
+		return fmt.Errorf("tipo de tabla no válido '%s', use MBR o GPT", table)
+	}
+
+	// Validar y normalizar el formato del archivo
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = estructuras.DISK_FORMAT_RAW
+	}
+	if format != estructuras.DISK_FORMAT_RAW && format != estructuras.DISK_FORMAT_QMIA {
+		utils.LogError("MkDisk", fmt.Sprintf("Formato no válido '%s', use RAW o QMIA", format))
+		return fmt.Errorf("formato no válido '%s', use RAW o QMIA", format)
+	}
+
 	// Calcular el tamaño en bytes
 	sizeInBytes := size * unitMultiplier
 	utils.LogInfo("MkDisk", fmt.Sprintf("Tamaño calculado: %d %s = %d bytes", size, unitName, sizeInBytes))
@@ -77,22 +103,55 @@ func MkDisk(size int64, fit string, unit string, path string) error {
 		return fmt.Errorf("el parámetro -path es obligatorio")
 	}
 
-	// Verificar que el path tenga la extensión .mia
-	if !strings.HasSuffix(strings.ToLower(path), ".mia") {
+	// La tabla GPT necesita espacio para sus dos copias
+	if table == "GPT" && sizeInBytes < estructuras.GPT_MIN_DISK_SIZE {
+		utils.LogError("MkDisk", "El disco es demasiado pequeño para una tabla GPT")
+		return fmt.Errorf("el disco es demasiado pequeño para una tabla GPT (mínimo %d bytes)", estructuras.GPT_MIN_DISK_SIZE)
+	}
+
+	// Verificar que el path tenga la extensión .mia (los discos en memoria no tienen archivo)
+	if !estructuras.IsMemoryDisk(path) && !strings.HasSuffix(strings.ToLower(path), ".mia") {
 		utils.LogWarning("MkDisk", "Se recomienda usar la extensión .mia para archivos de disco")
 	}
 
 	// Crear el Disco físico
 	utils.LogInfo("MkDisk", "Creando archivo físico del disco...")
-	err := action.NewDisk(path, sizeInBytes)
+	var err error
+	if format == estructuras.DISK_FORMAT_QMIA {
+		err = action.NewQmiaDisk(path, sizeInBytes)
+	} else {
+		err = action.NewDisk(path, sizeInBytes)
+	}
 	if err != nil {
 		utils.LogError("MkDisk", fmt.Sprintf("Error al crear el archivo del disco: %v", err))
 		return fmt.Errorf("error al crear el archivo del disco: %v", err)
 	}
 
-	// Crear y escribir el MBR (Master Boot Record) al disco
-	utils.LogInfo("MkDisk", "Escribiendo MBR (Master Boot Record)...")
-	err = estructuras.WriteMBR(path, sizeInBytes, fit)
+	// Snapshots de un disco anterior con la misma ruta no corresponden al disco nuevo
+	if estructuras.HasSnapshots(path) {
+		utils.LogWarning("MkDisk", "Se eliminan los snapshots de un disco anterior en la misma ruta")
+		if err := estructuras.RemoveSnapshots(path); err != nil {
+			return err
+		}
+	}
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return err
+	}
+	if err := estructuras.RemoveChecksums(path); err != nil {
+		return err
+	}
+	if err := estructuras.RemoveDiskMetadata(path); err != nil {
+		return err
+	}
+
+	// Crear y escribir el MBR (Master Boot Record) o la tabla GPT al disco
+	if table == "GPT" {
+		utils.LogInfo("MkDisk", "Escribiendo tabla GPT (MBR protector, cabecera, entradas y copia de respaldo)...")
+		err = estructuras.WriteGPT(path, sizeInBytes, fit)
+	} else {
+		utils.LogInfo("MkDisk", "Escribiendo MBR (Master Boot Record)...")
+		err = estructuras.WriteMBR(path, sizeInBytes, fit)
+	}
 	if err != nil {
 		utils.LogError("MkDisk", fmt.Sprintf("Error al escribir el MBR: %v", err))
 		return fmt.Errorf("error al escribir el MBR: %v", err)
@@ -121,6 +180,8 @@ func MkDisk(size int64, fit string, unit string, path string) error {
 	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Ruta: %s", path))
 	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Tamaño: %d %s (%d bytes)", size, unitName, sizeInBytes))
 	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Ajuste: %s", fit))
+	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Tabla: %s", mbr.GetTipoTablaString()))
+	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Formato: %s", format))
 	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Signature: %d", mbr.MbrDiskSignature))
 	utils.LogSuccess("MkDisk", fmt.Sprintf("  → Fecha creación: %d", mbr.MbrFechaCreacion))
 
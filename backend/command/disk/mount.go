@@ -10,6 +10,7 @@ import (
 	estructuras "backend/struct"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +20,8 @@ import (
 |-----------|--------------|-------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------|
 | -path     | Obligatorio  | Ruta del disco que se montará en el sistema. Este archivo ya debe existir.                                                                                                                                                                                                                                                                                      |
 | -name     | Obligatorio  | Indica el nombre de la partición a cargar. Si no existe debe mostrar error.                                                                                                                                                                                                                                                                                     |
+| -passphrase | Opcional   | Frase de contraseña de una partición cifrada (fdisk -encrypt). Es obligatoria para montarla: la desbloquea y sus datos se leen y escriben descifrados hasta desmontarla.                                                                                                                                                                                       |
+| -all      | Opcional     | Monta las particiones del archivo de montaje automático (ver fstab.go) en lugar de -path y -name. Con unmount, desmonta las particiones del archivo que estén montadas.                                                                                                                                                                                         |
 */
 
 // MountedPartition representa una partición montada en el sistema
@@ -26,13 +29,15 @@ type MountedPartition struct {
 	ID             string `json:"id"`              // ID generado (ej: 341A, 342B)
 	Name           string `json:"name"`            // Nombre de la partición
 	Path           string `json:"path"`            // Ruta del disco
-	Type           string `json:"type"`            // Tipo: Primary, Extended, Logical
+	Type           string `json:"type"`            // Tipo: Primaria o Lógica
 	Size           int64  `json:"size"`            // Tamaño en bytes
 	PartitionIndex int    `json:"partition_index"` // Índice en el MBR (para primarias/extendidas)
 	EBRPosition    int64  `json:"ebr_position"`    // Posición del EBR (para lógicas)
 	Correlative    int64  `json:"correlative"`     // Número correlativo de montaje
 	MountTime      string `json:"mount_time"`      // Timestamp de montaje
 	DiskSignature  int64  `json:"disk_signature"`  // Firma del disco
+	Encrypted      bool   `json:"encrypted"`       // Partición cifrada, desbloqueada mientras está montada
+	start          int64  // Inicio de la partición en el disco
 }
 
 // MountSystem maneja el sistema de montaje de particiones
@@ -40,8 +45,9 @@ type MountSystem struct {
 	mutex              sync.RWMutex
 	mountedPartitions  map[string]*MountedPartition // Key: ID de partición
 	diskPartitionCount map[string]int               // Key: firma del disco, Value: contador de particiones
-	nextLetter         byte                         // Próxima letra a usar (A, B, C, ...)
+	diskLetters        *diskLetterRegistry          // Letra asignada a cada disco (A, B, C, ...)
 	carnetSuffix       string                       // Últimos dos dígitos del carnet
+	persistent         bool                         // Si es true, el montaje se escribe en el MBR/EBR
 }
 
 // Instancia global del sistema de montaje
@@ -52,7 +58,7 @@ func init() {
 	mountSystem = &MountSystem{
 		mountedPartitions:  make(map[string]*MountedPartition),
 		diskPartitionCount: make(map[string]int),
-		nextLetter:         'A',
+		diskLetters:        newDiskLetterRegistry(),
 		carnetSuffix:       "84", // Últimos dos dígitos del carnet (ajustar según corresponda)
 	}
 }
@@ -72,29 +78,57 @@ func SetCarnetSuffix(suffix string) {
 
 // Mount monta una partición en el sistema
 func Mount(path, name string) error {
-	utils.LogInfo("MOUNT", fmt.Sprintf("Iniciando montaje de partición: path=%s, name=%s", path, name))
+	_, err := MountWithID(path, name, "", "")
+	return err
+}
+
+// MountWithID monta una partición con el ID indicado (vacío para generarlo) y retorna el ID asignado
+// El ID debe tener el formato del sistema: sufijo del carnet, correlativo y la letra del disco
+// Una partición cifrada necesita su frase de contraseña y queda desbloqueada mientras está montada
+func MountWithID(path, name, wantedID, passphrase string) (string, error) {
+	utils.LogInfo("MOUNT", fmt.Sprintf("Iniciando montaje de partición: path=%s, name=%s, id=%s", path, name, wantedID))
 
 	// Validar parámetros
 	if err := validateMountParams(path, name); err != nil {
-		return err
+		return "", err
+	}
+
+	// El disco se abre una sola vez para leer su tabla de particiones
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		utils.LogError("MOUNT", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return "", fmt.Errorf("error de integridad del disco: %v", err)
 	}
+	defer device.Close()
 
 	// Validar que el disco existe y es válido
-	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+	if err := estructuras.ValidateDiskIntegrityOnDevice(device); err != nil {
 		utils.LogError("MOUNT", fmt.Sprintf("Error de integridad del disco: %v", err))
-		return fmt.Errorf("error de integridad del disco: %v", err)
+		return "", fmt.Errorf("error de integridad del disco: %v", err)
 	}
 
 	// Leer el MBR
-	mbr, err := estructuras.ReadMBR(path)
+	mbr, err := estructuras.ReadMBRFromDevice(device)
 	if err != nil {
-		return fmt.Errorf("error al leer MBR: %v", err)
+		return "", fmt.Errorf("error al leer MBR: %v", err)
 	}
 
 	// Buscar la partición por nombre
-	mountedPartition, err := findAndMountPartition(path, name, mbr)
+	mountedPartition, err := findAndMountPartition(device, name, mbr)
+	if err != nil {
+		return "", err
+	}
+
+	mountedPartition.Encrypted, err = estructuras.IsEncryptedPartition(path, mountedPartition.start, mountedPartition.Size)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if mountedPartition.Encrypted && passphrase == "" {
+		utils.LogError("MOUNT", fmt.Sprintf("La partición '%s' está cifrada", name))
+		return "", fmt.Errorf("la partición '%s' está cifrada, use -passphrase para montarla", name)
+	}
+	if !mountedPartition.Encrypted && passphrase != "" {
+		return "", fmt.Errorf("la partición '%s' no está cifrada, -passphrase no aplica", name)
 	}
 
 	// Agregar al sistema de montaje
@@ -104,29 +138,53 @@ func Mount(path, name string) error {
 	// Verificar que no esté ya montada
 	if isPartitionAlreadyMounted(path, name) {
 		utils.LogError("MOUNT", fmt.Sprintf("La partición '%s' en '%s' ya está montada", name, path))
-		return fmt.Errorf("la partición '%s' ya está montada", name)
+		return "", fmt.Errorf("la partición '%s' ya está montada", name)
 	}
 
-	// Generar ID único
-	id := generatePartitionID(mbr.MbrDiskSignature)
+	if mountedPartition.Encrypted {
+		if err := estructuras.UnlockPartition(path, mountedPartition.start, mountedPartition.Size, passphrase); err != nil {
+			utils.LogError("MOUNT", fmt.Sprintf("No se pudo desbloquear la partición '%s': %v", name, err))
+			return "", fmt.Errorf("no se pudo desbloquear la partición '%s': %v", name, err)
+		}
+	}
+
+	// Generar ID único, o reservar el ID pedido
+	id := wantedID
+	if id == "" {
+		id, mountedPartition.Correlative, err = generatePartitionID(mbr.MbrDiskSignature)
+	} else {
+		mountedPartition.Correlative, err = reservePartitionID(mbr.MbrDiskSignature, id)
+	}
+	if err != nil {
+		lockIfEncrypted(mountedPartition)
+		return "", err
+	}
 	mountedPartition.ID = id
 
 	// Actualizar la partición en el disco con el correlativo y ID
 	if err := updatePartitionInDisk(path, name, mountedPartition, mbr); err != nil {
-		return fmt.Errorf("error al actualizar partición en disco: %v", err)
+		releaseDiskIfUnused(mbr.MbrDiskSignature)
+		lockIfEncrypted(mountedPartition)
+		return "", fmt.Errorf("error al actualizar partición en disco: %v", err)
 	}
 
 	// Agregar al sistema de montaje
 	mountSystem.mountedPartitions[id] = mountedPartition
 
+	// Deshacer el montaje también lo quita del sistema de montaje
+	estructuras.RecordUndoMount(path, id)
+
 	utils.LogSuccess("MOUNT", "Partición montada exitosamente:")
 	utils.LogSuccess("MOUNT", fmt.Sprintf("  → ID: %s", id))
 	utils.LogSuccess("MOUNT", fmt.Sprintf("  → Nombre: %s", name))
 	utils.LogSuccess("MOUNT", fmt.Sprintf("  → Tipo: %s", mountedPartition.Type))
 	utils.LogSuccess("MOUNT", fmt.Sprintf("  → Tamaño: %d bytes", mountedPartition.Size))
 	utils.LogSuccess("MOUNT", fmt.Sprintf("  → Correlativo: %d", mountedPartition.Correlative))
+	if mountedPartition.Encrypted {
+		utils.LogSuccess("MOUNT", "  → Cifrada: desbloqueada")
+	}
 
-	return nil
+	return id, nil
 }
 
 // Unmount desmonta una partición del sistema
@@ -148,14 +206,12 @@ func Unmount(id string) error {
 		utils.LogWarning("UNMOUNT", fmt.Sprintf("Advertencia al desmontar en disco: %v", err))
 	}
 
-	// Remover del sistema de montaje
+	// Remover del sistema de montaje; una partición cifrada vuelve a quedar bloqueada
 	delete(mountSystem.mountedPartitions, id)
+	lockIfEncrypted(mountedPartition)
 
-	// Decrementar contador del disco
-	diskKey := strconv.FormatInt(mountedPartition.DiskSignature, 10)
-	if count, exists := mountSystem.diskPartitionCount[diskKey]; exists && count > 0 {
-		mountSystem.diskPartitionCount[diskKey] = count - 1
-	}
+	// Liberar la letra y el contador si el disco ya no tiene particiones montadas
+	releaseDiskIfUnused(mountedPartition.DiskSignature)
 
 	utils.LogSuccess("UNMOUNT", fmt.Sprintf("Partición %s desmontada exitosamente", id))
 	return nil
@@ -173,6 +229,27 @@ func GetMountedPartitionByID(id string) (*MountedPartition, error) {
 	return nil, fmt.Errorf("no se encontró una partición montada con ID: %s", id)
 }
 
+// IsPartitionMounted verifica si una partición de un disco está registrada en el sistema de montaje
+func IsPartitionMounted(path, name string) bool {
+	mountSystem.mutex.RLock()
+	defer mountSystem.mutex.RUnlock()
+
+	return isPartitionAlreadyMounted(path, name)
+}
+
+// HasMountedPartitions verifica si alguna partición del disco está montada
+func HasMountedPartitions(path string) bool {
+	mountSystem.mutex.RLock()
+	defer mountSystem.mutex.RUnlock()
+
+	for _, partition := range mountSystem.mountedPartitions {
+		if estructuras.SameDisk(partition.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateMountParams valida los parámetros del comando mount
 func validateMountParams(path, name string) error {
 	if path == "" {
@@ -189,20 +266,19 @@ func validateMountParams(path, name string) error {
 }
 
 // findAndMountPartition busca una partición por nombre y prepara el montaje
-func findAndMountPartition(path, name string, mbr *estructuras.MBR) (*MountedPartition, error) {
+// El correlativo se asigna junto con el ID, con el sistema de montaje bloqueado
+func findAndMountPartition(device *estructuras.DiskDevice, name string, mbr *estructuras.MBR) (*MountedPartition, error) {
+	path := device.Path()
+
 	// Buscar en particiones primarias y extendidas
 	for i, partition := range mbr.MbrParticiones {
 		if partition.IsActive() && partition.GetName() == name {
-			// Verificar que solo se monten particiones primarias (según especificaciones)
-			if !partition.IsPrimary() {
-				utils.LogError("MOUNT", "Solo se pueden montar particiones primarias")
-				return nil, fmt.Errorf("solo se pueden montar particiones primarias")
+			// Una partición extendida solo contiene lógicas, no se puede montar
+			if partition.IsExtended() {
+				utils.LogError("MOUNT", "No se puede montar una partición extendida, monte una de sus particiones lógicas")
+				return nil, fmt.Errorf("no se puede montar una partición extendida, monte una de sus particiones lógicas")
 			}
 
-			// CALCULAR EL CORRELATIVO
-			diskKey := strconv.FormatInt(mbr.MbrDiskSignature, 10)
-			nextCorrelative := int64(mountSystem.diskPartitionCount[diskKey] + 1)
-
 			return &MountedPartition{
 				Name:           name,
 				Path:           path,
@@ -210,26 +286,25 @@ func findAndMountPartition(path, name string, mbr *estructuras.MBR) (*MountedPar
 				Size:           partition.PartSize,
 				PartitionIndex: i,
 				EBRPosition:    -1, // No aplica para primarias
+				start:          partition.PartStart,
 				DiskSignature:  mbr.MbrDiskSignature,
-				Correlative:    nextCorrelative, // ✅ ASIGNAR EL CORRELATIVO
 				MountTime:      fmt.Sprintf("%d", time.Now().Unix()),
 			}, nil
 		}
 	}
 
-	// Si no se encuentra en primarias, buscar en lógicas (para referencia futura)
-	extendedPartition, _, err := estructuras.GetExtendedPartition(path)
-	if err == nil {
-		ebr, ebrPosition, err := estructuras.FindEBRByName(path, extendedPartition.PartStart, name)
+	// Si no se encuentra en primarias, buscar en lógicas
+	if extendedPartition := mbr.GetParticionExtendida(); extendedPartition != nil {
+		ebr, ebrPosition, err := estructuras.FindEBRByNameFromDevice(device, extendedPartition.PartStart, name)
 		if err == nil {
-			utils.LogWarning("MOUNT", "Particiones lógicas no se implementarán en este proyecto según especificaciones")
 			return &MountedPartition{
 				Name:           name,
 				Path:           path,
-				Type:           "Logical",
+				Type:           "Lógica",
 				Size:           ebr.PartSize,
 				PartitionIndex: -1, // No aplica para lógicas
 				EBRPosition:    ebrPosition,
+				start:          ebr.PartStart,
 				DiskSignature:  mbr.MbrDiskSignature,
 				MountTime:      fmt.Sprintf("%d", time.Now().Unix()),
 			}, nil
@@ -240,39 +315,98 @@ func findAndMountPartition(path, name string, mbr *estructuras.MBR) (*MountedPar
 	return nil, fmt.Errorf("no se encontró una partición con el nombre '%s'", name)
 }
 
-// generatePartitionID genera un ID único para la partición
-func generatePartitionID(diskSignature int64) string {
+// generatePartitionID genera un ID único para la partición y retorna también su correlativo
+// Se debe llamar con mountSystem.mutex bloqueado para escritura
+func generatePartitionID(diskSignature int64) (string, int64, error) {
 	diskKey := strconv.FormatInt(diskSignature, 10)
 
-	// Obtener o inicializar contador para este disco
-	count, exists := mountSystem.diskPartitionCount[diskKey]
-	if !exists {
-		count = 0
+	// Obtener la letra del disco (todas sus particiones comparten la misma letra)
+	letter, err := mountSystem.diskLetters.Acquire(diskKey)
+	if err != nil {
+		utils.LogError("MOUNT", err.Error())
+		return "", 0, err
 	}
 
-	// Incrementar contador (inicia en 1)
-	count++
+	// Incrementar contador del disco (inicia en 1)
+	count := mountSystem.diskPartitionCount[diskKey] + 1
 	mountSystem.diskPartitionCount[diskKey] = count
 
 	// ✅ FORMATO CORRECTO DEL ID: carnet + correlativo + letra
 	// Ejemplo: 34 (carnet) + 1 (correlativo) + A (letra) = 341A
-	id := fmt.Sprintf("%s%d%c", mountSystem.carnetSuffix, count, mountSystem.nextLetter)
+	id := fmt.Sprintf("%s%d%c", mountSystem.carnetSuffix, count, letter)
+
+	utils.LogInfo("MOUNT", fmt.Sprintf("ID generado: %s para disco %d", id, diskSignature))
+	return id, int64(count), nil
+}
 
-	// ✅ INCREMENTAR LA LETRA PARA EL SIGUIENTE DISCO
-	// Solo incrementar si es un disco diferente o cuando se agote el alfabeto
-	mountSystem.nextLetter++
-	if mountSystem.nextLetter > 'Z' {
-		mountSystem.nextLetter = 'A' // Reiniciar si se agota el alfabeto
+// reservePartitionID reserva un ID pedido para una partición del disco y retorna su correlativo
+func reservePartitionID(diskSignature int64, id string) (int64, error) {
+	diskKey := strconv.FormatInt(diskSignature, 10)
+
+	// Formato: sufijo del carnet + correlativo + letra (ej: 841A)
+	suffix := mountSystem.carnetSuffix
+	if len(id) < len(suffix)+2 || !strings.HasPrefix(id, suffix) {
+		return 0, fmt.Errorf("el ID '%s' no tiene el formato %s<correlativo><letra>", id, suffix)
+	}
+	letter := id[len(id)-1]
+	correlative, err := strconv.ParseInt(id[len(suffix):len(id)-1], 10, 64)
+	if err != nil || correlative <= 0 {
+		return 0, fmt.Errorf("el ID '%s' no tiene el formato %s<correlativo><letra>", id, suffix)
 	}
 
-	utils.LogInfo("MOUNT", fmt.Sprintf("ID generado: %s para disco %d", id, diskSignature))
-	return id
+	if existing, exists := mountSystem.mountedPartitions[id]; exists {
+		return 0, fmt.Errorf("el ID %s ya está en uso por '%s' en %s", id, existing.Name, existing.Path)
+	}
+
+	// Dos particiones del mismo disco no pueden compartir correlativo
+	for _, partition := range mountSystem.mountedPartitions {
+		if partition.DiskSignature == diskSignature && partition.Correlative == correlative {
+			return 0, fmt.Errorf("el correlativo %d ya está en uso por '%s' (%s)", correlative, partition.Name, partition.ID)
+		}
+	}
+
+	// La letra del ID debe ser la misma para todas las particiones del disco
+	if err := mountSystem.diskLetters.Reserve(diskKey, letter); err != nil {
+		return 0, fmt.Errorf("no se puede usar el ID %s: %v", id, err)
+	}
+
+	// El contador del disco continúa desde el correlativo más alto
+	if int(correlative) > mountSystem.diskPartitionCount[diskKey] {
+		mountSystem.diskPartitionCount[diskKey] = int(correlative)
+	}
+
+	utils.LogInfo("MOUNT", fmt.Sprintf("ID reservado: %s para disco %d", id, diskSignature))
+	return correlative, nil
+}
+
+// lockIfEncrypted bloquea la partición si es cifrada
+func lockIfEncrypted(partition *MountedPartition) {
+	if partition.Encrypted {
+		estructuras.LockPartition(partition.Path, partition.start)
+	}
+}
+
+// releaseDiskIfUnused libera la letra y el contador de un disco sin particiones montadas
+func releaseDiskIfUnused(diskSignature int64) {
+	for _, partition := range mountSystem.mountedPartitions {
+		if partition.DiskSignature == diskSignature {
+			return
+		}
+	}
+
+	diskKey := strconv.FormatInt(diskSignature, 10)
+	if letter, exists := mountSystem.diskLetters.Get(diskKey); exists {
+		utils.LogInfo("MOUNT", fmt.Sprintf("Letra %c liberada del disco %d", letter, diskSignature))
+	}
+
+	mountSystem.diskLetters.Release(diskKey)
+	delete(mountSystem.diskPartitionCount, diskKey)
 }
 
 // isPartitionAlreadyMounted verifica si una partición ya está montada
 func isPartitionAlreadyMounted(path, name string) bool {
 	for _, partition := range mountSystem.mountedPartitions {
-		if partition.Path == path && partition.Name == name {
+		if estructuras.SameDisk(partition.Path, path) && partition.Name == name {
 			return true
 		}
 	}
@@ -281,14 +415,16 @@ func isPartitionAlreadyMounted(path, name string) bool {
 
 // updatePartitionInDisk actualiza la partición en el disco con información de montaje
 func updatePartitionInDisk(path, name string, mountedPartition *MountedPartition, mbr *estructuras.MBR) error {
-	// ❌ ELIMINAR TODA LA ESCRITURA AL DISCO
-	// Solo mantener la lógica en memoria RAM
+	// Las lógicas llevan su estado de montaje en el EBR
+	if mountedPartition.EBRPosition > 0 {
+		return updateLogicalMountInDisk(path, name, mountedPartition.EBRPosition, true)
+	}
 
-	// Encontrar la partición en el MBR (solo en memoria)
+	// Encontrar la partición en el MBR
 	for i := range mbr.MbrParticiones {
 		if mbr.MbrParticiones[i].IsActive() && mbr.MbrParticiones[i].GetName() == name {
 			// ✅ Actualizar estatus a 'M' (Montada)
-			mbr.MbrParticiones[i].PartStatus = 'M'
+			mbr.MbrParticiones[i].PartStatus = estructuras.StatusMontada
 
 			// ✅ Asignar correlativo correcto (inicia en 1)
 			mbr.MbrParticiones[i].PartCorrelativo = int64(mountedPartition.Correlative)
@@ -296,8 +432,10 @@ func updatePartitionInDisk(path, name string, mountedPartition *MountedPartition
 			// ✅ Asignar ID generado
 			mbr.MbrParticiones[i].SetID(mountedPartition.ID)
 
-			// ❌ NO ESCRIBIR AL DISCO
-			// return estructuras.WriteToDisk(path, mbrData, 0)
+			// Solo se escribe al disco en modo persistente
+			if mountSystem.persistent {
+				return writeUpdatedMBR(path, mbr)
+			}
 
 			return nil // Solo operación en memoria
 		}
@@ -306,30 +444,35 @@ func updatePartitionInDisk(path, name string, mountedPartition *MountedPartition
 	return fmt.Errorf("no se pudo actualizar la partición en memoria")
 }
 
-// unmountPartitionInDisk actualiza la partición para desmontar (solo en memoria)
+// unmountPartitionInDisk actualiza la partición para desmontar (en disco solo en modo persistente)
 func unmountPartitionInDisk(mountedPartition *MountedPartition) error {
-	// Solo operaciones en memoria RAM, no escribir al disco
+	// Las lógicas llevan su estado de montaje en el EBR
+	if mountedPartition.EBRPosition > 0 {
+		return updateLogicalMountInDisk(mountedPartition.Path, mountedPartition.Name, mountedPartition.EBRPosition, false)
+	}
 
-	// Leer el MBR (esto no escribe, solo lee)
+	// Leer el MBR
 	mbr, err := estructuras.ReadMBR(mountedPartition.Path)
 	if err != nil {
 		return fmt.Errorf("error al leer MBR: %v", err)
 	}
 
-	// Encontrar y actualizar la partición (solo en memoria)
+	// Encontrar y actualizar la partición
 	for i := range mbr.MbrParticiones {
 		if mbr.MbrParticiones[i].IsActive() &&
 			mbr.MbrParticiones[i].GetName() == mountedPartition.Name {
 
-			// ✅ Cambiar estatus a no montada
-			mbr.MbrParticiones[i].PartStatus = 'U' // Unmounted o el valor por defecto
+			// ✅ Cambiar estatus a activa no montada
+			mbr.MbrParticiones[i].PartStatus = estructuras.StatusActiva
 
 			// Limpiar información de montaje
 			mbr.MbrParticiones[i].PartCorrelativo = -1
 			mbr.MbrParticiones[i].SetID("")
 
-			// ❌ NO ESCRIBIR AL DISCO
-			// return estructuras.WriteToDisk(mountedPartition.Path, mbrData, 0)
+			// Solo se escribe al disco en modo persistente
+			if mountSystem.persistent {
+				return writeUpdatedMBR(mountedPartition.Path, mbr)
+			}
 
 			return nil // Solo operación en memoria
 		}
@@ -338,18 +481,50 @@ func unmountPartitionInDisk(mountedPartition *MountedPartition) error {
 	return fmt.Errorf("no se pudo actualizar la partición en memoria")
 }
 
+// updateLogicalMountInDisk actualiza el campo PartMount del EBR de una partición lógica
+func updateLogicalMountInDisk(path, name string, ebrPosition int64, mounted bool) error {
+	ebr, err := estructuras.ReadEBR(path, ebrPosition)
+	if err != nil {
+		return fmt.Errorf("error al leer EBR: %v", err)
+	}
+
+	// Verificar que el EBR sigue correspondiendo a la partición
+	if ebr.IsEmpty() || ebr.GetName() != name {
+		return fmt.Errorf("el EBR en la posición %d ya no corresponde a la partición '%s'", ebrPosition, name)
+	}
+
+	if mounted {
+		ebr.Mount()
+	} else {
+		ebr.Unmount()
+	}
+
+	return estructuras.WriteEBR(path, ebr, ebrPosition)
+}
+
 // GetMountSystemStats retorna estadísticas del sistema de montaje
 func GetMountSystemStats() map[string]interface{} {
 	mountSystem.mutex.RLock()
 	defer mountSystem.mutex.RUnlock()
 
+	nextLetter := "-" // Sin letras disponibles
+	if letter, ok := mountSystem.diskLetters.NextFree(); ok {
+		nextLetter = string(letter)
+	}
+
 	stats := map[string]interface{}{
 		"total_mounted": len(mountSystem.mountedPartitions),
 		"unique_disks":  len(mountSystem.diskPartitionCount),
-		"next_letter":   string(mountSystem.nextLetter),
+		"next_letter":   nextLetter,
 		"carnet_suffix": mountSystem.carnetSuffix,
+		"persistent":    mountSystem.persistent,
 	}
 
+	// Asignación de letras por disco
+	stats["letter_allocation"] = mountSystem.diskLetters.Allocation()
+	stats["letters_in_use"] = mountSystem.diskLetters.AssignedLetters()
+	stats["free_letters"] = mountSystem.diskLetters.FreeCount()
+
 	// Información por disco
 	diskStats := make(map[string]int)
 	for diskKey, count := range mountSystem.diskPartitionCount {
@@ -374,7 +549,8 @@ func ClearMountSystem() {
 
 	mountSystem.mountedPartitions = make(map[string]*MountedPartition)
 	mountSystem.diskPartitionCount = make(map[string]int)
-	mountSystem.nextLetter = 'A'
+	mountSystem.diskLetters = newDiskLetterRegistry()
+	estructuras.LockAllPartitions()
 
 	utils.LogInfo("MOUNT", "Sistema de montaje limpiado")
 }
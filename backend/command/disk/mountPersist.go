@@ -0,0 +1,304 @@
+package disk
+
+/*
+ * Persistencia del sistema de montaje. En modo persistente el estado de montaje
+ * (PartStatus, PartCorrelativo y PartID) se escribe en el MBR, de modo que al
+ * reiniciar el backend el MountSystem se puede reconstruir a partir de los discos
+ * del espacio de trabajo.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MountRestoreReport resume el resultado de reconstruir el sistema de montaje
+type MountRestoreReport struct {
+	DiskDir      string   `json:"disk_dir"`      // Directorio escaneado
+	ScannedDisks int      `json:"scanned_disks"` // Discos leídos correctamente
+	Restored     []string `json:"restored"`      // IDs restaurados tal como estaban en el disco
+	Reassigned   []string `json:"reassigned"`    // IDs nuevos asignados a particiones lógicas montadas
+	Conflicts    []string `json:"conflicts"`     // Montajes que no se pudieron restaurar
+	Errors       []string `json:"errors"`        // Discos que no se pudieron leer
+}
+
+// pendingLogicalMount representa una partición lógica marcada como montada en su EBR
+type pendingLogicalMount struct {
+	path          string
+	name          string
+	size          int64
+	ebrPosition   int64
+	diskSignature int64
+}
+
+// SetPersistentMounts activa o desactiva la escritura del estado de montaje en el disco
+func SetPersistentMounts(enabled bool) {
+	mountSystem.mutex.Lock()
+	defer mountSystem.mutex.Unlock()
+
+	mountSystem.persistent = enabled
+	utils.LogInfo("MOUNT", fmt.Sprintf("Montaje persistente: %t", enabled))
+}
+
+// IsPersistentMounts indica si el estado de montaje se escribe en el disco
+func IsPersistentMounts() bool {
+	mountSystem.mutex.RLock()
+	defer mountSystem.mutex.RUnlock()
+
+	return mountSystem.persistent
+}
+
+// RestoreMountSystem reconstruye el sistema de montaje a partir de los discos de un directorio
+func RestoreMountSystem(diskDir string) (*MountRestoreReport, error) {
+	utils.LogInfo("MOUNT", fmt.Sprintf("Reconstruyendo sistema de montaje desde: %s", diskDir))
+
+	files, err := findDiskFiles(diskDir)
+	if err != nil {
+		utils.LogError("MOUNT", fmt.Sprintf("Error al buscar discos: %v", err))
+		return nil, fmt.Errorf("error al buscar discos en %s: %v", diskDir, err)
+	}
+
+	report := &MountRestoreReport{
+		DiskDir:    diskDir,
+		Restored:   []string{},
+		Reassigned: []string{},
+		Conflicts:  []string{},
+		Errors:     []string{},
+	}
+
+	mountSystem.mutex.Lock()
+	defer mountSystem.mutex.Unlock()
+
+	seenSignatures := make(map[string]string) // Key: firma del disco, Value: ruta
+	var pendingLogicals []pendingLogicalMount
+
+	for _, path := range files {
+		mbr, err := estructuras.ReadMBR(path)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		report.ScannedDisks++
+
+		// Un disco sin montajes guardados no tiene nada que restaurar: su firma solo es
+		// ambigua si otro disco con montajes guardados la comparte
+		if !hasPersistedMounts(path, mbr) {
+			continue
+		}
+
+		// Dos discos con la misma firma compartirían el contador de particiones
+		diskKey := strconv.FormatInt(mbr.MbrDiskSignature, 10)
+		if otherPath, exists := seenSignatures[diskKey]; exists {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: tiene la misma firma que %s, no se restauran sus montajes", path, otherPath))
+			continue
+		}
+		seenSignatures[diskKey] = path
+
+		restoreDiskMounts(path, mbr, report)
+
+		// Las lógicas no guardan ID ni correlativo, se montan después de las primarias
+		pendingLogicals = append(pendingLogicals, findMountedLogicals(path, mbr, report)...)
+	}
+
+	for _, pending := range pendingLogicals {
+		if isPartitionAlreadyMounted(pending.path, pending.name) {
+			continue
+		}
+
+		id, correlative, err := generatePartitionID(pending.diskSignature)
+		if err != nil {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: no se pudo asignar ID a la lógica '%s': %v", pending.path, pending.name, err))
+			continue
+		}
+
+		mountSystem.mountedPartitions[id] = &MountedPartition{
+			ID:             id,
+			Name:           pending.name,
+			Path:           pending.path,
+			Type:           "Lógica",
+			Size:           pending.size,
+			PartitionIndex: -1,
+			EBRPosition:    pending.ebrPosition,
+			DiskSignature:  pending.diskSignature,
+			Correlative:    correlative,
+			MountTime:      fmt.Sprintf("%d", time.Now().Unix()),
+		}
+		report.Reassigned = append(report.Reassigned, id)
+	}
+
+	for _, conflict := range report.Conflicts {
+		utils.LogWarning("MOUNT", fmt.Sprintf("Conflicto al restaurar montaje: %s", conflict))
+	}
+
+	utils.LogSuccess("MOUNT", fmt.Sprintf("Sistema de montaje reconstruido: %d discos, %d restauradas, %d reasignadas, %d conflictos",
+		report.ScannedDisks, len(report.Restored), len(report.Reassigned), len(report.Conflicts)))
+
+	return report, nil
+}
+
+// restoreDiskMounts registra en el sistema de montaje las particiones del MBR marcadas como montadas
+func restoreDiskMounts(path string, mbr *estructuras.MBR, report *MountRestoreReport) {
+	diskKey := strconv.FormatInt(mbr.MbrDiskSignature, 10)
+	usedCorrelatives := make(map[int64]bool)
+
+	for i := range mbr.MbrParticiones {
+		partition := &mbr.MbrParticiones[i]
+		if partition.PartStatus != estructuras.StatusMontada {
+			continue
+		}
+
+		name := partition.GetName()
+		id := partition.GetID()
+
+		if id == "" || !partition.IsMounted() {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: la partición '%s' está marcada como montada sin ID o correlativo", path, name))
+			continue
+		}
+
+		// Una partición cifrada necesita su frase de contraseña, no se restaura sola
+		if encrypted, _ := estructuras.IsEncryptedPartition(path, partition.PartStart, partition.PartSize); encrypted {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: la partición '%s' está cifrada, móntela con -passphrase", path, name))
+			continue
+		}
+
+		if existing, exists := mountSystem.mountedPartitions[id]; exists {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: el ID %s de '%s' ya está en uso por '%s' en %s", path, id, name, existing.Name, existing.Path))
+			continue
+		}
+
+		if usedCorrelatives[partition.PartCorrelativo] {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: el correlativo %d de '%s' está repetido en el disco", path, partition.PartCorrelativo, name))
+			continue
+		}
+
+		if isPartitionAlreadyMounted(path, name) {
+			continue
+		}
+
+		// La letra del ID debe ser la misma para todas las particiones del disco
+		if err := mountSystem.diskLetters.Reserve(diskKey, id[len(id)-1]); err != nil {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: no se puede restaurar el ID %s de '%s': %v", path, id, name, err))
+			continue
+		}
+
+		usedCorrelatives[partition.PartCorrelativo] = true
+		mountSystem.mountedPartitions[id] = &MountedPartition{
+			ID:             id,
+			Name:           name,
+			Path:           path,
+			Type:           partition.GetTypeString(),
+			Size:           partition.PartSize,
+			PartitionIndex: i,
+			EBRPosition:    -1,
+			DiskSignature:  mbr.MbrDiskSignature,
+			Correlative:    partition.PartCorrelativo,
+			MountTime:      fmt.Sprintf("%d", time.Now().Unix()),
+		}
+		report.Restored = append(report.Restored, id)
+
+		// El contador del disco continúa desde el correlativo más alto
+		if int(partition.PartCorrelativo) > mountSystem.diskPartitionCount[diskKey] {
+			mountSystem.diskPartitionCount[diskKey] = int(partition.PartCorrelativo)
+		}
+	}
+}
+
+// hasPersistedMounts indica si el disco tiene particiones (primarias o lógicas) marcadas como montadas
+// Si la cadena de EBRs no se puede leer se asume que sí, para que findMountedLogicals reporte el error
+func hasPersistedMounts(path string, mbr *estructuras.MBR) bool {
+	for i := range mbr.MbrParticiones {
+		if mbr.MbrParticiones[i].PartStatus == estructuras.StatusMontada {
+			return true
+		}
+	}
+
+	extendedPartition := mbr.GetParticionExtendida()
+	if extendedPartition == nil {
+		return false
+	}
+
+	chain, err := estructuras.ReadEBRChain(path, extendedPartition.PartStart)
+	if err != nil {
+		return true
+	}
+	for _, node := range chain {
+		if !node.EBR.IsEmpty() && node.EBR.IsMounted() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findMountedLogicals busca las particiones lógicas con PartMount activo en el EBR
+func findMountedLogicals(path string, mbr *estructuras.MBR, report *MountRestoreReport) []pendingLogicalMount {
+	extendedPartition := mbr.GetParticionExtendida()
+	if extendedPartition == nil {
+		return nil
+	}
+
+	chain, err := estructuras.ReadEBRChain(path, extendedPartition.PartStart)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", path, err))
+		return nil
+	}
+
+	var pending []pendingLogicalMount
+	for _, node := range chain {
+		if node.EBR.IsEmpty() || !node.EBR.IsMounted() {
+			continue
+		}
+
+		if encrypted, _ := estructuras.IsEncryptedPartition(path, node.EBR.PartStart, node.EBR.PartSize); encrypted {
+			report.Conflicts = append(report.Conflicts,
+				fmt.Sprintf("%s: la partición '%s' está cifrada, móntela con -passphrase", path, node.EBR.GetName()))
+			continue
+		}
+
+		pending = append(pending, pendingLogicalMount{
+			path:          path,
+			name:          node.EBR.GetName(),
+			size:          node.EBR.PartSize,
+			ebrPosition:   node.Position,
+			diskSignature: mbr.MbrDiskSignature,
+		})
+	}
+
+	return pending
+}
+
+// findDiskFiles busca recursivamente los archivos .mia y .dsk de un directorio
+func findDiskFiles(diskDir string) ([]string, error) {
+	if _, err := os.Stat(diskDir); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err := filepath.WalkDir(diskDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if !entry.IsDir() && (ext == ".mia" || ext == ".dsk") {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files, err
+}
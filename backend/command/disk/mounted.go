@@ -55,6 +55,7 @@ func ShowMountedPartitions() string {
 	result.WriteString("Estadísticas del sistema:\n")
 	result.WriteString(fmt.Sprintf("  • Discos únicos: %d\n", stats["unique_disks"]))
 	result.WriteString(fmt.Sprintf("  • Próxima letra: %s\n", stats["next_letter"]))
+	result.WriteString(fmt.Sprintf("  • Letras en uso: %v\n", stats["letters_in_use"]))
 	result.WriteString(fmt.Sprintf("  • Sufijo carnet: %s\n\n", stats["carnet_suffix"]))
 
 	// Agrupar por disco para mejor presentación
@@ -247,6 +248,13 @@ func ShowMountedPartitionsDetailed() string {
 	result.WriteString(fmt.Sprintf("Próxima letra disponible.... : %s\n", stats["next_letter"]))
 	result.WriteString(fmt.Sprintf("Sufijo del carnet........... : %s\n", stats["carnet_suffix"]))
 
+	if allocation, ok := stats["letter_allocation"].(map[string]string); ok && len(allocation) > 0 {
+		result.WriteString("\nLetras asignadas:\n")
+		for letter, diskSig := range allocation {
+			result.WriteString(fmt.Sprintf("  %s → Disco %s\n", letter, diskSig))
+		}
+	}
+
 	if diskStats, ok := stats["partitions_per_disk"].(map[string]int); ok {
 		result.WriteString("\nParticiones por disco:\n")
 		for diskSig, count := range diskStats {
@@ -0,0 +1,171 @@
+package disk
+
+/*
+ * REPAIRMBR - Este comando repara el MBR de un disco usando su copia de respaldo.
+ * Cada escritura del MBR actualiza una copia con checksum cerca del final del disco.
+ * Si el MBR principal está dañado se reescribe a partir de la copia; si el principal
+ * es válido pero la copia falta o está desactualizada, se reescribe la copia.
+ * En discos GPT también se verifican las cabeceras GPT y sus arreglos de entradas:
+ * si la tabla principal está dañada se reescribe desde la de respaldo, y viceversa.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"bytes"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                      |
+|-----------|--------------|--------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco cuyo MBR se va a reparar.         |
+*/
+
+// Acciones realizadas por repairmbr
+const (
+	RepairMBRNone            = "none"                 // Ambas copias son válidas e iguales
+	RepairMBRPrimaryRestored = "primary_restored"     // El MBR principal se reescribió desde la copia
+	RepairMBRBackupRewritten = "backup_rewritten"     // La copia se reescribió desde el MBR principal
+	RepairGPTPrimaryRestored = "gpt_primary_restored" // La tabla GPT principal se reescribió desde la de respaldo
+	RepairGPTBackupRewritten = "gpt_backup_rewritten" // La tabla GPT de respaldo se reescribió desde la principal
+)
+
+// RepairMBRResult describe el estado de las copias del MBR y la acción realizada
+type RepairMBRResult struct {
+	Path            string `json:"path"`              // Ruta del disco
+	PrimaryValid    bool   `json:"primary_valid"`     // El MBR principal era válido
+	PrimaryError    string `json:"primary_error"`     // Motivo por el que el MBR principal no era válido
+	BackupValid     bool   `json:"backup_valid"`      // La copia de respaldo era válida
+	BackupError     string `json:"backup_error"`      // Motivo por el que la copia no era válida
+	BackupOffset    int64  `json:"backup_offset"`     // Posición de la copia de respaldo
+	Action          string `json:"action"`            // Acción realizada sobre el MBR
+	GPT             bool   `json:"gpt"`               // El disco tiene tabla GPT
+	GPTPrimaryValid bool   `json:"gpt_primary_valid"` // La tabla GPT principal era válida
+	GPTPrimaryError string `json:"gpt_primary_error"` // Motivo por el que la tabla GPT principal no era válida
+	GPTBackupValid  bool   `json:"gpt_backup_valid"`  // La tabla GPT de respaldo era válida
+	GPTBackupError  string `json:"gpt_backup_error"`  // Motivo por el que la tabla GPT de respaldo no era válida
+	GPTAction       string `json:"gpt_action"`        // Acción realizada sobre la tabla GPT
+}
+
+// RepairMBR reescribe el MBR principal o su copia de respaldo según cuál esté dañado
+// En discos GPT hace lo mismo con las dos copias de la tabla GPT
+func RepairMBR(path string) (*RepairMBRResult, error) {
+	utils.LogInfo("REPAIRMBR", fmt.Sprintf("Verificando copias del MBR del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("REPAIRMBR", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	primary, primaryErr := estructuras.ReadPrimaryMBR(path)
+	backup, backupErr := estructuras.ReadBackupMBR(path)
+
+	result := &RepairMBRResult{
+		Path:         path,
+		PrimaryValid: primaryErr == nil,
+		BackupValid:  backupErr == nil,
+		Action:       RepairMBRNone,
+		GPTAction:    RepairMBRNone,
+	}
+	if primaryErr != nil {
+		result.PrimaryError = primaryErr.Error()
+	}
+	if backupErr != nil {
+		result.BackupError = backupErr.Error()
+	}
+
+	if primaryErr != nil && backupErr != nil {
+		utils.LogError("REPAIRMBR", fmt.Sprintf("El MBR y su copia de respaldo están dañados: %v; %v", primaryErr, backupErr))
+		return nil, fmt.Errorf("no se puede reparar el MBR: el principal está dañado (%v) y la copia de respaldo no es válida (%v)", primaryErr, backupErr)
+	}
+
+	switch {
+	case primaryErr != nil:
+		result.Action = RepairMBRPrimaryRestored
+	case backupErr != nil || !sameMBR(primary, backup):
+		result.Action = RepairMBRBackupRewritten
+	}
+
+	// El MBR protector de un disco GPT no describe las particiones, hay que verificar la tabla GPT
+	valid := primary
+	if primaryErr != nil {
+		valid = backup
+	}
+	if valid.IsGPT() {
+		result.GPT = true
+		gptPrimaryErr, gptBackupErr := estructuras.CheckGPTTables(path, valid.MbrTamanio)
+		result.GPTPrimaryValid = gptPrimaryErr == nil
+		result.GPTBackupValid = gptBackupErr == nil
+		if gptPrimaryErr != nil {
+			result.GPTPrimaryError = gptPrimaryErr.Error()
+		}
+		if gptBackupErr != nil {
+			result.GPTBackupError = gptBackupErr.Error()
+		}
+
+		switch {
+		case gptPrimaryErr != nil && gptBackupErr != nil:
+			utils.LogError("REPAIRMBR", fmt.Sprintf("La tabla GPT y su copia de respaldo están dañadas: %v; %v", gptPrimaryErr, gptBackupErr))
+			return nil, fmt.Errorf("no se puede reparar la tabla GPT: la principal está dañada (%v) y la de respaldo no es válida (%v)", gptPrimaryErr, gptBackupErr)
+		case gptPrimaryErr != nil:
+			result.GPTAction = RepairGPTPrimaryRestored
+		case gptBackupErr != nil:
+			result.GPTAction = RepairGPTBackupRewritten
+		}
+	}
+
+	if result.Action == RepairMBRNone && result.GPTAction == RepairMBRNone {
+		result.BackupOffset = valid.GetBackupMBROffset()
+		if result.GPT {
+			utils.LogSuccess("REPAIRMBR", "El MBR, la tabla GPT y sus copias de respaldo son válidos, no hay nada que reparar")
+		} else {
+			utils.LogSuccess("REPAIRMBR", "El MBR y su copia de respaldo son válidos, no hay nada que reparar")
+		}
+		return result, nil
+	}
+
+	// ReadMBR usa la copia de respaldo si el principal está dañado (y la tabla GPT de
+	// respaldo si la principal está dañada); al reescribir la tabla se actualizan ambas copias
+	mbr, err := estructuras.ReadMBR(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+	result.BackupOffset = mbr.GetBackupMBROffset()
+
+	// La reparación no se registra en el diario de deshacer
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return nil, err
+	}
+
+	if err := writeUpdatedMBR(path, mbr); err != nil {
+		return nil, err
+	}
+
+	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+		utils.LogWarning("REPAIRMBR", fmt.Sprintf("El MBR se reescribió pero el disco tiene errores de integridad: %v", err))
+	}
+
+	switch result.Action {
+	case RepairMBRPrimaryRestored:
+		utils.LogSuccess("REPAIRMBR", fmt.Sprintf("MBR principal restaurado desde la copia del byte %d", result.BackupOffset))
+	case RepairMBRBackupRewritten:
+		utils.LogSuccess("REPAIRMBR", fmt.Sprintf("Copia de respaldo del MBR reescrita en el byte %d", result.BackupOffset))
+	}
+	switch result.GPTAction {
+	case RepairGPTPrimaryRestored:
+		utils.LogSuccess("REPAIRMBR", "Tabla GPT principal restaurada desde la tabla de respaldo")
+	case RepairGPTBackupRewritten:
+		utils.LogSuccess("REPAIRMBR", "Tabla GPT de respaldo reescrita desde la tabla principal")
+	}
+
+	return result, nil
+}
+
+// sameMBR indica si dos MBR tienen el mismo contenido en disco
+func sameMBR(a, b *estructuras.MBR) bool {
+	dataA, errA := estructuras.SerializeMBR(a)
+	dataB, errB := estructuras.SerializeMBR(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
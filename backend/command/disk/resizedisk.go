@@ -0,0 +1,158 @@
+package disk
+
+/*
+ * RESIZEDISK - Este comando cambia el tamaño de un disco existente.
+ * Al crecer se extiende el archivo .mia y se actualiza MbrTamanio; al reducir,
+ * solo se permite recortar el final del disco si ninguna partición lo usa.
+ * En discos GPT la copia de respaldo de la tabla se reubica al nuevo final.
+ * Después de la operación se valida nuevamente la integridad del disco.
+ */
+
+import (
+	utils "backend/Utils"
+	action "backend/action"
+	estructuras "backend/struct"
+	"fmt"
+	"strings"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA   | DESCRIPCIÓN                                                                                                                          |
+|-----------|-------------|--------------------------------------------------------------------------------------------------------------------------------------|
+| -size     | Obligatorio | Nuevo tamaño total del disco. Debe ser positivo y mayor que cero.                                                                    |
+| -unit     | Opcional    | Unidades del parámetro size. Valores: K (Kilobytes), M (Megabytes). Default: Megabytes.                                              |
+| -path     | Obligatorio | Ruta del disco a redimensionar. Al reducir, el espacio que se elimina al final del disco no puede pertenecer a ninguna partición.    |
+*/
+
+// ResizeDiskResult contiene el resultado del cambio de tamaño del disco
+type ResizeDiskResult struct {
+	Path    string `json:"path"`     // Ruta del disco
+	OldSize int64  `json:"old_size"` // Tamaño anterior en bytes
+	NewSize int64  `json:"new_size"` // Tamaño nuevo en bytes
+}
+
+// ResizeDisk cambia el tamaño de un disco y de su MBR
+func ResizeDisk(size int64, unit, path string) (*ResizeDiskResult, error) {
+	utils.LogInfo("ResizeDisk", fmt.Sprintf("Iniciando cambio de tamaño del disco: size=%d, unit=%s, path=%s", size, unit, path))
+
+	// Validar parámetros obligatorios
+	if size <= 0 {
+		utils.LogError("ResizeDisk", "El tamaño del disco debe ser un número positivo mayor que cero")
+		return nil, fmt.Errorf("el tamaño del disco debe ser un número positivo mayor que cero")
+	}
+
+	if path == "" {
+		utils.LogError("ResizeDisk", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	// Validar y procesar unidad
+	unit = strings.ToUpper(strings.TrimSpace(unit))
+	unitMultiplier := int64(1024 * 1024) // Por defecto Megabytes
+
+	switch unit {
+	case "", "M":
+		unitMultiplier = 1024 * 1024
+	case "K":
+		unitMultiplier = 1024
+	default:
+		utils.LogError("ResizeDisk", fmt.Sprintf("Unidad no válida '%s', use K para Kilobytes o M para Megabytes", unit))
+		return nil, fmt.Errorf("unidad no válida '%s', use K para Kilobytes o M para Megabytes", unit)
+	}
+
+	newSize := size * unitMultiplier
+
+	// Las capas de los snapshots tienen el tamaño del disco al crearlas
+	if estructuras.HasSnapshots(path) {
+		utils.LogError("ResizeDisk", "El disco tiene snapshots, elimínelos antes de cambiar su tamaño")
+		return nil, fmt.Errorf("el disco tiene snapshots, elimínelos antes de cambiar su tamaño")
+	}
+
+	// Validar que el disco existe y es válido antes de modificarlo
+	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+		utils.LogError("ResizeDisk", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+
+	// Leer el MBR
+	mbr, err := estructuras.ReadMBR(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	result := &ResizeDiskResult{
+		Path:    path,
+		OldSize: mbr.MbrTamanio,
+		NewSize: newSize,
+	}
+
+	if newSize == mbr.MbrTamanio {
+		utils.LogInfo("ResizeDisk", fmt.Sprintf("El disco ya tiene %d bytes, no hay cambios", newSize))
+		return result, nil
+	}
+
+	if newSize > mbr.MbrTamanio {
+		// El cambio de tamaño no se registra en el diario de deshacer
+		if err := estructuras.RemoveUndoJournal(path); err != nil {
+			return nil, err
+		}
+
+		// Crecer: primero el archivo, luego el MBR
+		if err := action.ResizeDiskFile(path, newSize); err != nil {
+			return nil, err
+		}
+
+		mbr.MbrTamanio = newSize
+		if err := writeUpdatedMBR(path, mbr); err != nil {
+			return nil, fmt.Errorf("error al escribir MBR actualizado: %v", err)
+		}
+	} else {
+		// Reducir: el final eliminado no puede pertenecer a ninguna partición
+		// (en GPT también se necesita espacio para reubicar la copia de respaldo de la tabla)
+		usedEnd := getDiskUsedEnd(mbr)
+		minSize := usedEnd + (mbr.MbrTamanio - mbr.LastUsableByte())
+		if newSize < minSize {
+			utils.LogError("ResizeDisk", fmt.Sprintf("No se puede reducir el disco a %d bytes: las particiones ocupan hasta el byte %d (mínimo %d bytes)", newSize, usedEnd, minSize))
+			return nil, fmt.Errorf("no se puede reducir el disco a %d bytes: las particiones ocupan hasta el byte %d (mínimo %d bytes)", newSize, usedEnd, minSize)
+		}
+
+		if err := estructuras.RemoveUndoJournal(path); err != nil {
+			return nil, err
+		}
+
+		// Reducir: primero el MBR, luego el archivo
+		mbr.MbrTamanio = newSize
+		if err := writeUpdatedMBR(path, mbr); err != nil {
+			return nil, fmt.Errorf("error al escribir MBR actualizado: %v", err)
+		}
+
+		if err := action.ResizeDiskFile(path, newSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validar nuevamente la integridad del disco
+	if err := estructuras.ValidateDiskIntegrity(path); err != nil {
+		utils.LogError("ResizeDisk", fmt.Sprintf("El disco no pasó la validación después del cambio de tamaño: %v", err))
+		return nil, fmt.Errorf("el disco no pasó la validación después del cambio de tamaño: %v", err)
+	}
+
+	utils.LogSuccess("ResizeDisk", "Disco redimensionado exitosamente:")
+	utils.LogSuccess("ResizeDisk", fmt.Sprintf("  → Ruta: %s", path))
+	utils.LogSuccess("ResizeDisk", fmt.Sprintf("  → Tamaño anterior: %d bytes", result.OldSize))
+	utils.LogSuccess("ResizeDisk", fmt.Sprintf("  → Tamaño nuevo: %d bytes", result.NewSize))
+
+	return result, nil
+}
+
+// getDiskUsedEnd calcula el byte final ocupado por la tabla de particiones o la última partición del disco
+func getDiskUsedEnd(mbr *estructuras.MBR) int64 {
+	usedEnd := mbr.FirstUsableByte()
+	for i := range mbr.MbrParticiones {
+		partition := &mbr.MbrParticiones[i]
+		if partition.IsActive() && partition.GetEndPosition() > usedEnd {
+			usedEnd = partition.GetEndPosition()
+		}
+	}
+	return usedEnd
+}
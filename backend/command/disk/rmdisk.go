@@ -2,11 +2,13 @@ package disk
 
 /*
  * Este comando elimina un archivo que representa a un disco duro virtual.
- * El archivo debe existir para poder ser eliminado.
+ * El archivo debe existir para poder ser eliminado. Con una ruta mem://<nombre>
+ * se libera el disco en memoria, que no debe tener particiones montadas.
  */
 
 import (
 	utils "backend/Utils"
+	estructuras "backend/struct"
 	"fmt"
 	"os"
 	"strings"
@@ -31,6 +33,11 @@ func RmDisk(path string) error {
 	// Limpiar el path
 	path = strings.TrimSpace(path)
 
+	// Un disco en memoria no tiene archivo que verificar
+	if estructuras.IsMemoryDisk(path) {
+		return rmMemoryDisk(path)
+	}
+
 	// Verificar si el archivo existe
 	fileInfo, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -69,6 +76,9 @@ func RmDisk(path string) error {
 	// Confirmar la eliminación (en un entorno real, aquí se podría pedir confirmación al usuario)
 	utils.LogWarning("RmDisk", fmt.Sprintf("Se eliminará permanentemente el archivo: %s", path))
 
+	// Cerrar el dispositivo si el comando lo abrió (en Windows no se puede eliminar un archivo abierto)
+	estructuras.CloseDevice(path)
+
 	// Eliminar el archivo
 	err = os.Remove(path)
 	if err != nil {
@@ -76,15 +86,60 @@ func RmDisk(path string) error {
 		return fmt.Errorf("error al eliminar el archivo: %v", err)
 	}
 
+	// Sus particiones cifradas desbloqueadas no deben aplicarse a otro disco con la misma ruta
+	estructuras.LockDiskPartitions(path)
+
 	// Verificar que el archivo fue eliminado correctamente
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
 		utils.LogError("RmDisk", "El archivo no fue eliminado correctamente")
 		return fmt.Errorf("error: el archivo no fue eliminado correctamente")
 	}
 
+	// Los snapshots, el diario de deshacer, las sumas de verificación y los metadatos del disco ya no sirven sin él
+	if err := estructuras.RemoveSnapshots(path); err != nil {
+		utils.LogWarning("RmDisk", err.Error())
+	}
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		utils.LogWarning("RmDisk", err.Error())
+	}
+	if err := estructuras.RemoveChecksums(path); err != nil {
+		utils.LogWarning("RmDisk", err.Error())
+	}
+	if err := estructuras.RemoveDiskMetadata(path); err != nil {
+		utils.LogWarning("RmDisk", err.Error())
+	}
+
 	utils.LogSuccess("RmDisk", "Disco eliminado exitosamente:")
 	utils.LogSuccess("RmDisk", fmt.Sprintf("  → Ruta: %s", path))
 	utils.LogSuccess("RmDisk", fmt.Sprintf("  → Tamaño eliminado: %.2f MB", float64(fileSize)/(1024*1024)))
 
 	return nil
 }
+
+// rmMemoryDisk libera un disco en memoria
+// Sus particiones montadas quedarían apuntando a un disco que ya no existe, así que se rechaza
+func rmMemoryDisk(path string) error {
+	size, err := estructuras.DiskSize(path)
+	if err != nil {
+		utils.LogError("RmDisk", err.Error())
+		return err
+	}
+
+	if HasMountedPartitions(path) {
+		utils.LogError("RmDisk", "El disco tiene particiones montadas, desmóntelas antes de eliminarlo")
+		return fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de eliminarlo")
+	}
+
+	estructuras.CloseDevice(path)
+	if err := estructuras.RemoveMemoryDisk(path); err != nil {
+		utils.LogError("RmDisk", err.Error())
+		return err
+	}
+	estructuras.LockDiskPartitions(path)
+
+	utils.LogSuccess("RmDisk", "Disco en memoria eliminado exitosamente:")
+	utils.LogSuccess("RmDisk", fmt.Sprintf("  → Ruta: %s", path))
+	utils.LogSuccess("RmDisk", fmt.Sprintf("  → Memoria liberada: %.2f MB", float64(size)/(1024*1024)))
+
+	return nil
+}
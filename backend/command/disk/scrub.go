@@ -0,0 +1,102 @@
+package disk
+
+/*
+ * SCRUB - Este comando detecta datos dañados en un disco. Con -enable el disco
+ * guarda el CRC32C de cada región de 4KB (en <disco>.sums) y cada escritura
+ * actualiza las sumas de las regiones que toca. Sin opciones se recalculan las
+ * sumas de todo el disco y se reportan los rangos que ya no coinciden: bytes
+ * modificados por fuera del programa o dañados en el almacenamiento.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                              |
+|-----------|--------------|----------------------------------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco. No puede ser un disco en memoria.                                                        |
+| -enable   | Opcional     | Calcula las sumas con el contenido actual del disco. Si ya tenía sumas, las diferencias se aceptan.      |
+| -disable  | Opcional     | Elimina las sumas del disco; las escrituras dejan de actualizarlas.                                      |
+*/
+
+// Acciones del comando scrub
+const (
+	ScrubVerify  = "verify"
+	ScrubEnable  = "enable"
+	ScrubDisable = "disable"
+)
+
+// ScrubResult es el resultado del comando scrub
+type ScrubResult struct {
+	Path     string                    `json:"path"`     // Ruta del disco
+	Action   string                    `json:"action"`   // Acción realizada
+	Enabled  bool                      `json:"enabled"`  // El disco tiene sumas después de la acción
+	Checksum *estructuras.ChecksumInfo `json:"checksum"` // Tabla calculada (-enable)
+	Report   *estructuras.ScrubReport  `json:"report"`   // Resultado de la verificación
+}
+
+// Scrub verifica, activa o desactiva las sumas de verificación de un disco
+func Scrub(path, action string) (*ScrubResult, error) {
+	utils.LogInfo("SCRUB", fmt.Sprintf("Scrub: path=%s, acción=%s", path, action))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("SCRUB", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	if !estructuras.DiskExists(path) {
+		utils.LogError("SCRUB", fmt.Sprintf("El disco no existe: %s", path))
+		return nil, fmt.Errorf("el disco no existe: %s", path)
+	}
+
+	result := &ScrubResult{Path: path, Action: action}
+
+	switch action {
+	case ScrubEnable:
+		info, err := estructuras.EnableChecksums(path)
+		if err != nil {
+			utils.LogError("SCRUB", err.Error())
+			return nil, err
+		}
+		result.Enabled, result.Checksum = true, info
+		utils.LogSuccess("SCRUB", fmt.Sprintf("Sumas de verificación activadas: %d regiones de %d bytes", info.Regions, info.RegionSize))
+
+	case ScrubDisable:
+		if !estructuras.HasChecksums(path) {
+			utils.LogError("SCRUB", "El disco no tiene sumas de verificación")
+			return nil, fmt.Errorf("el disco %s no tiene sumas de verificación", path)
+		}
+		if err := estructuras.RemoveChecksums(path); err != nil {
+			utils.LogError("SCRUB", err.Error())
+			return nil, err
+		}
+		utils.LogSuccess("SCRUB", fmt.Sprintf("Sumas de verificación de %s eliminadas", path))
+
+	case ScrubVerify:
+		report, err := estructuras.ScrubDisk(path)
+		if err != nil {
+			utils.LogError("SCRUB", err.Error())
+			return nil, err
+		}
+		result.Enabled, result.Report = true, report
+
+		for _, bad := range report.BadRanges {
+			utils.LogWarning("SCRUB", fmt.Sprintf("  → Bytes [%d, %d) no coinciden con sus sumas", bad.Offset, bad.Offset+bad.Length))
+		}
+		if report.BadRegions == 0 {
+			utils.LogSuccess("SCRUB", fmt.Sprintf("Las %d regiones de %s coinciden con sus sumas", report.Regions, path))
+		} else {
+			utils.LogWarning("SCRUB", fmt.Sprintf("%d de %d regiones de %s no coinciden con sus sumas", report.BadRegions, report.Regions, path))
+		}
+
+	default:
+		utils.LogError("SCRUB", fmt.Sprintf("Acción no válida: %s", action))
+		return nil, fmt.Errorf("acción no válida '%s', use -enable, -disable o ninguna para verificar", action)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package disk
+
+/*
+ * SNAPSHOT - Este comando administra los snapshots copy-on-write de un disco.
+ * Crear un snapshot no copia el disco: las escrituras posteriores se guardan en
+ * una capa aparte y todos los comandos siguen funcionando sobre el estado actual.
+ * Restaurar regresa el disco al estado del snapshot y eliminar un snapshot
+ * conserva el estado actual del disco.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+	"strings"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                                              |
+|-----------|--------------|----------------------------------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco.                                                                                          |
+| -create   | Opcional     | Crea un snapshot con el estado actual del disco. Requiere -name.                                         |
+| -list     | Opcional     | Lista los snapshots del disco.                                                                           |
+| -restore  | Opcional     | Regresa el disco al estado del snapshot -name. Descarta los snapshots posteriores. Sin montajes.         |
+| -delete   | Opcional     | Elimina el snapshot -name conservando el estado actual del disco.                                        |
+| -name     | Obligatorio* | Nombre del snapshot (obligatorio para -create, -restore y -delete).                                      |
+*/
+
+// Acciones del comando snapshot
+const (
+	SnapshotCreate  = "create"
+	SnapshotList    = "list"
+	SnapshotRestore = "restore"
+	SnapshotDelete  = "delete"
+)
+
+// SnapshotResult es el resultado del comando snapshot
+type SnapshotResult struct {
+	Path        string                     `json:"path"`         // Ruta del disco
+	Action      string                     `json:"action"`       // Acción realizada
+	Name        string                     `json:"name"`         // Snapshot creado, restaurado o eliminado
+	Snapshots   []estructuras.SnapshotInfo `json:"snapshots"`    // Snapshots del disco después de la acción
+	Discarded   []string                   `json:"discarded"`    // Snapshots descartados al restaurar
+	MergedBytes int64                      `json:"merged_bytes"` // Bytes combinados al eliminar
+}
+
+// Snapshot ejecuta una acción sobre los snapshots de un disco
+func Snapshot(path, action, name string) (*SnapshotResult, error) {
+	utils.LogInfo("SNAPSHOT", fmt.Sprintf("Snapshot: path=%s, acción=%s, name=%s", path, action, name))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("SNAPSHOT", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	name = strings.TrimSpace(name)
+	if action != SnapshotList && name == "" {
+		utils.LogError("SNAPSHOT", "El parámetro -name es obligatorio")
+		return nil, fmt.Errorf("el parámetro -name es obligatorio para -%s", action)
+	}
+
+	result := &SnapshotResult{
+		Path:      path,
+		Action:    action,
+		Name:      name,
+		Discarded: []string{},
+	}
+
+	switch action {
+	case SnapshotCreate:
+		snapshot, err := estructuras.CreateSnapshot(path, name)
+		if err != nil {
+			utils.LogError("SNAPSHOT", err.Error())
+			return nil, err
+		}
+		utils.LogSuccess("SNAPSHOT", fmt.Sprintf("Snapshot '%s' creado, las escrituras siguientes se guardan en %s", name, snapshot.Layer))
+
+	case SnapshotRestore:
+		// Los montajes en memoria no coincidirían con el disco restaurado
+		if HasMountedPartitions(path) {
+			utils.LogError("SNAPSHOT", "El disco tiene particiones montadas, desmóntelas antes de restaurar")
+			return nil, fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de restaurar un snapshot")
+		}
+
+		discarded, err := estructuras.RestoreSnapshot(path, name)
+		if err != nil {
+			utils.LogError("SNAPSHOT", err.Error())
+			return nil, err
+		}
+		result.Discarded = discarded
+
+		// El diario describe cambios posteriores al snapshot
+		if err := estructuras.RemoveUndoJournal(path); err != nil {
+			return nil, err
+		}
+		utils.LogSuccess("SNAPSHOT", fmt.Sprintf("Disco restaurado al snapshot '%s'", name))
+		for _, snapshot := range discarded {
+			utils.LogWarning("SNAPSHOT", fmt.Sprintf("  → Snapshot posterior descartado: %s", snapshot))
+		}
+
+	case SnapshotDelete:
+		merged, err := estructuras.DeleteSnapshot(path, name)
+		if err != nil {
+			utils.LogError("SNAPSHOT", err.Error())
+			return nil, err
+		}
+		result.MergedBytes = merged
+		utils.LogSuccess("SNAPSHOT", fmt.Sprintf("Snapshot '%s' eliminado (%d bytes combinados)", name, merged))
+
+	case SnapshotList:
+		// Solo se listan
+
+	default:
+		utils.LogError("SNAPSHOT", fmt.Sprintf("Acción no válida: %s", action))
+		return nil, fmt.Errorf("acción no válida '%s', use -create, -list, -restore o -delete", action)
+	}
+
+	snapshots, err := estructuras.ListSnapshots(path)
+	if err != nil {
+		return nil, err
+	}
+	result.Snapshots = snapshots
+
+	if action == SnapshotList {
+		for _, snapshot := range snapshots {
+			utils.LogInfo("SNAPSHOT", fmt.Sprintf("  → %s (%s): %d bytes modificados desde entonces", snapshot.Name, snapshot.CreatedAt, snapshot.Bytes))
+		}
+		utils.LogSuccess("SNAPSHOT", fmt.Sprintf("%d snapshots en %s", len(snapshots), path))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package disk
+
+/*
+ * UNDO - Este comando deshace los últimos cambios estructurales hechos en un
+ * disco por fdisk, mount o mkfs. Cada uno de esos comandos guarda en el diario
+ * del disco el contenido que sobrescribió; deshacer lo reescribe y quita del
+ * sistema de montaje las particiones que el comando había montado.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                      |
+|-----------|--------------|----------------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco.                                                                  |
+| -count    | Opcional     | Cantidad de cambios a deshacer, del más reciente al más antiguo. Por defecto 1.  |
+| -list     | Opcional     | Lista los cambios que se pueden deshacer, del más reciente al más antiguo.       |
+*/
+
+// UndoResult es el resultado del comando undo
+type UndoResult struct {
+	Path      string                  `json:"path"`      // Ruta del disco
+	Undone    []estructuras.UndoEntry `json:"undone"`    // Cambios deshechos, del más reciente al más antiguo
+	Unmounted []string                `json:"unmounted"` // IDs quitados del sistema de montaje
+	Entries   []estructuras.UndoEntry `json:"entries"`   // Cambios que quedan en el diario, del más reciente al más antiguo
+}
+
+// Undo deshace los últimos count cambios del disco, o solo los lista si list es true
+func Undo(path string, count int, list bool) (*UndoResult, error) {
+	utils.LogInfo("UNDO", fmt.Sprintf("Undo: path=%s, count=%d, list=%t", path, count, list))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("UNDO", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	if count <= 0 {
+		utils.LogError("UNDO", "El parámetro -count debe ser mayor que cero")
+		return nil, fmt.Errorf("el parámetro -count debe ser mayor que cero")
+	}
+
+	result := &UndoResult{
+		Path:      path,
+		Undone:    []estructuras.UndoEntry{},
+		Unmounted: []string{},
+	}
+
+	if !list {
+		undone, undoErr := estructuras.Undo(path, count)
+		result.Undone = append(result.Undone, undone...)
+
+		for _, entry := range undone {
+			utils.LogSuccess("UNDO", fmt.Sprintf("Deshecho: %s (%d bytes)", entry.Command, entry.Bytes))
+		}
+		result.Unmounted = forgetUndoneMounts(path, undone)
+
+		if undoErr != nil {
+			utils.LogError("UNDO", undoErr.Error())
+			if len(undone) == 0 {
+				return nil, undoErr
+			}
+			return result, undoErr
+		}
+	}
+
+	entries, err := estructuras.ListUndo(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		result.Entries = append(result.Entries, entries[i])
+		if list {
+			utils.LogInfo("UNDO", fmt.Sprintf("  → [%d] %s %s (%d bytes)", entries[i].ID, entries[i].CreatedAt, entries[i].Command, entries[i].Bytes))
+		}
+	}
+	if result.Entries == nil {
+		result.Entries = []estructuras.UndoEntry{}
+	}
+
+	return result, nil
+}
+
+// forgetUndoneMounts quita del sistema de montaje las particiones montadas por los cambios
+// deshechos y las que ya no existen en el disco (el disco ya está restaurado)
+func forgetUndoneMounts(path string, undone []estructuras.UndoEntry) []string {
+	forget := make(map[string]bool)
+	for _, entry := range undone {
+		for _, id := range entry.Mounts {
+			forget[id] = true
+		}
+	}
+
+	existing := make(map[string]bool)
+	if listing, err := LsDisk(path); err == nil {
+		for _, partition := range listing.Partitions {
+			existing[partition.Name] = true
+		}
+	}
+
+	mountSystem.mutex.Lock()
+	defer mountSystem.mutex.Unlock()
+
+	unmounted := []string{}
+	for id, partition := range mountSystem.mountedPartitions {
+		if !estructuras.SameDisk(partition.Path, path) || (!forget[id] && existing[partition.Name]) {
+			continue
+		}
+
+		delete(mountSystem.mountedPartitions, id)
+		releaseDiskIfUnused(partition.DiskSignature)
+		lockIfEncrypted(partition)
+		unmounted = append(unmounted, id)
+		utils.LogWarning("UNDO", fmt.Sprintf("Partición %s ('%s') quitada del sistema de montaje", id, partition.Name))
+	}
+
+	return unmounted
+}
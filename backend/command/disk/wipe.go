@@ -0,0 +1,87 @@
+package disk
+
+/*
+ * WIPE - Este comando llena con ceros el contenido de un disco. Se conservan
+ * el MBR (o la tabla GPT), su copia de respaldo y la cadena de EBRs, por lo que
+ * las particiones siguen existiendo pero sus datos se pierden.
+ */
+
+import (
+	utils "backend/Utils"
+	estructuras "backend/struct"
+	"fmt"
+)
+
+/*
+| PARÁMETRO | CATEGORÍA    | DESCRIPCIÓN                                                                |
+|-----------|--------------|----------------------------------------------------------------------------|
+| -path     | Obligatorio  | Ruta del disco a limpiar. Ninguna partición del disco puede estar montada. |
+*/
+
+// WipeResult es el resultado del comando wipe
+type WipeResult struct {
+	Path         string `json:"path"`          // Ruta del disco
+	ZeroedStart  int64  `json:"zeroed_start"`  // Primer byte llenado con ceros
+	ZeroedEnd    int64  `json:"zeroed_end"`    // Último byte (exclusivo) llenado con ceros
+	ZeroedBytes  int64  `json:"zeroed_bytes"`  // Cantidad de bytes llenados con ceros
+	Partitions   int    `json:"partitions"`    // Particiones conservadas en la tabla
+	TableType    string `json:"table_type"`    // Tipo de tabla de particiones
+	BackupOffset int64  `json:"backup_offset"` // Posición de la copia de respaldo del MBR (conservada)
+}
+
+// Wipe llena con ceros el espacio utilizable del disco conservando la tabla de particiones
+func Wipe(path string) (*WipeResult, error) {
+	utils.LogInfo("WIPE", fmt.Sprintf("Limpiando contenido del disco: %s", path))
+
+	// Validar parámetros obligatorios
+	if path == "" {
+		utils.LogError("WIPE", "El parámetro -path es obligatorio")
+		return nil, fmt.Errorf("el parámetro -path es obligatorio")
+	}
+
+	device, err := estructuras.OpenDiskDevice(path)
+	if err != nil {
+		utils.LogError("WIPE", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+	defer device.Close()
+
+	if err := estructuras.ValidateDiskIntegrityOnDevice(device); err != nil {
+		utils.LogError("WIPE", fmt.Sprintf("Error de integridad del disco: %v", err))
+		return nil, fmt.Errorf("error de integridad del disco: %v", err)
+	}
+
+	// Se perderían los datos de particiones en uso
+	if HasMountedPartitions(path) {
+		utils.LogError("WIPE", "El disco tiene particiones montadas, desmóntelas antes de limpiarlo")
+		return nil, fmt.Errorf("el disco tiene particiones montadas, desmóntelas antes de limpiarlo")
+	}
+
+	mbr, err := estructuras.ReadMBRFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer MBR: %v", err)
+	}
+
+	// Las imágenes previas del diario ya no corresponden al contenido del disco
+	if err := estructuras.RemoveUndoJournal(path); err != nil {
+		return nil, err
+	}
+
+	if err := estructuras.ZeroDisk(path); err != nil {
+		utils.LogError("WIPE", err.Error())
+		return nil, err
+	}
+
+	result := &WipeResult{
+		Path:         path,
+		ZeroedStart:  mbr.FirstUsableByte(),
+		ZeroedEnd:    mbr.LastUsableByte(),
+		ZeroedBytes:  mbr.LastUsableByte() - mbr.FirstUsableByte(),
+		Partitions:   mbr.CountActivePartitions(),
+		TableType:    mbr.GetTipoTablaString(),
+		BackupOffset: mbr.GetBackupMBROffset(),
+	}
+
+	utils.LogSuccess("WIPE", fmt.Sprintf("Disco %s limpiado: %d bytes llenados con ceros", path, result.ZeroedBytes))
+	return result, nil
+}
@@ -4,11 +4,13 @@ import (
 	utils "backend/Utils"
 	"backend/command"
 	diskCommands "backend/command/disk"
+	estructuras "backend/struct"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -56,6 +58,80 @@ func ConfigureCarnet() {
 	}
 }
 
+// ConfigureMountPersistence activa el montaje persistente y reconstruye el sistema de montaje desde los discos
+func ConfigureMountPersistence() {
+	// El modo persistente es opcional y se activa con una variable de entorno
+	persistent := strings.ToLower(os.Getenv("MOUNT_PERSISTENT"))
+	if persistent != "1" && persistent != "true" {
+		fmt.Printf("ℹ️  Montaje en memoria: los montajes se pierden al reiniciar el backend\n")
+		fmt.Printf("   Para persistirlos en el disco: export MOUNT_PERSISTENT=true\n")
+		return
+	}
+
+	diskDir := os.Getenv("DISK_WORKSPACE")
+	if diskDir == "" {
+		diskDir = "./Discos" // Ruta por defecto
+	}
+
+	diskCommands.SetPersistentMounts(true)
+
+	report, err := diskCommands.RestoreMountSystem(diskDir)
+	if err != nil {
+		fmt.Printf("⚠️  No se pudo reconstruir el sistema de montaje: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Montaje persistente activo: %d discos escaneados en %s, %d particiones restauradas, %d reasignadas\n",
+		report.ScannedDisks, diskDir, len(report.Restored), len(report.Reassigned))
+	for _, conflict := range report.Conflicts {
+		fmt.Printf("⚠️  Conflicto: %s\n", conflict)
+	}
+	for _, diskErr := range report.Errors {
+		fmt.Printf("⚠️  Error: %s\n", diskErr)
+	}
+}
+
+// ConfigureAutomount aplica el archivo de montaje automático (estilo fstab) al iniciar
+func ConfigureAutomount() {
+	fstabPath := os.Getenv("MOUNT_FSTAB")
+	if fstabPath == "" {
+		fstabPath = diskCommands.DefaultFstabPath
+	}
+	diskCommands.SetFstabPath(fstabPath)
+
+	// Sin archivo no hay nada que montar
+	if _, err := os.Stat(fstabPath); err != nil {
+		fmt.Printf("ℹ️  Sin montaje automático: no existe %s\n", fstabPath)
+		fmt.Printf("   Para configurarlo: export MOUNT_FSTAB=/ruta/al/fstab\n")
+		return
+	}
+
+	// Otro proceso del backend podría estar usando los mismos discos
+	disks := diskCommands.FstabDisks()
+	lock, err := estructuras.LockDisks(nil, disks)
+	if err != nil {
+		fmt.Printf("⚠️  No se pudo aplicar el montaje automático: %v\n", err)
+		return
+	}
+	defer lock.Unlock()
+
+	devices := estructuras.OpenDevices(disks)
+	defer devices.Close()
+
+	report, err := diskCommands.MountAll()
+	if err != nil {
+		fmt.Printf("⚠️  No se pudo aplicar el montaje automático: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Montaje automático desde %s: %d entradas, %d con errores\n", fstabPath, len(report.Results), report.Failed)
+	for _, result := range report.Results {
+		if result.Status == diskCommands.FstabFailed {
+			fmt.Printf("⚠️  Línea %d (%s '%s'): %s\n", result.Line, result.Path, result.Name, result.Error)
+		}
+	}
+}
+
 // Estructuras de respuesta
 type ApiResponse struct {
 	Message string      `json:"message"`
@@ -70,13 +146,22 @@ type HealthResponse struct {
 	Uptime    string    `json:"uptime"`
 }
 
+// FileSystemInfo es un disco del registro (ver diskCommands.ListDisks)
 type FileSystemInfo struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Size       int64  `json:"size"`
-	MountPoint string `json:"mountPoint"`
-	Status     string `json:"status"`
-	Path       string `json:"path"`
+	Name        string                     `json:"name"`
+	Type        string                     `json:"type"`     // Tipo de tabla de particiones (MBR o GPT)
+	Format      string                     `json:"format"`   // Formato del archivo (raw o qmia)
+	Size        int64                      `json:"size"`     // Tamaño del disco
+	FileSize    int64                      `json:"fileSize"` // Bytes que ocupa el archivo
+	MountPoint  string                     `json:"mountPoint"`
+	MountIDs    []string                   `json:"mountIds"`
+	Status      string                     `json:"status"` // mounted, unmounted o invalid
+	Path        string                     `json:"path"`
+	Label       string                     `json:"label"`
+	Description string                     `json:"description"`
+	Owner       string                     `json:"owner"`
+	Partitions  []diskCommands.LsDiskEntry `json:"partitions"`
+	Error       string                     `json:"error,omitempty"`
 }
 
 type ExecuteRequest struct {
@@ -121,7 +206,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getFileSystemsHandler obtiene información de los sistemas de archivos montados
+// getFileSystemsHandler lista los discos de un directorio con sus particiones, montajes y metadatos
 func getFileSystemsHandler(w http.ResponseWriter, r *http.Request) {
 	// Obtener parámetro de ruta de la query string
 	searchPath := r.URL.Query().Get("path")
@@ -132,95 +217,66 @@ func getFileSystemsHandler(w http.ResponseWriter, r *http.Request) {
 	// Limpiar y expandir la ruta
 	searchPath = strings.TrimSpace(searchPath)
 
-	// Convertir rutas relativas a absolutas si es necesario
-	if strings.HasPrefix(searchPath, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			searchPath = filepath.Join(homeDir, searchPath[2:])
+	// Los discos en memoria no están en un directorio: ?path=mem:// los lista
+	if searchPath != estructuras.MEMORY_DISK_SCHEME {
+		// Convertir rutas relativas a absolutas si es necesario
+		if strings.HasPrefix(searchPath, "~/") {
+			homeDir, err := os.UserHomeDir()
+			if err == nil {
+				searchPath = filepath.Join(homeDir, searchPath[2:])
+			}
 		}
-	}
-
-	// Limpiar la ruta
-	searchPath = filepath.Clean(searchPath)
 
-	var fileSystems []FileSystemInfo
+		// Limpiar la ruta
+		searchPath = filepath.Clean(searchPath)
 
-	// Verificar si el directorio existe
-	if _, err := os.Stat(searchPath); os.IsNotExist(err) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(ApiResponse{
-			Message: fmt.Sprintf("Directorio no encontrado: %s", searchPath),
-			Data:    []FileSystemInfo{},
-			Status:  "warning",
-		})
-		return
+		// Verificar si el directorio existe
+		if _, err := os.Stat(searchPath); os.IsNotExist(err) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ApiResponse{
+				Message: fmt.Sprintf("Directorio no encontrado: %s", searchPath),
+				Data:    []FileSystemInfo{},
+				Status:  "warning",
+			})
+			return
+		}
 	}
 
-	// Buscar archivos .mia del directorio especificado
-	pattern := filepath.Join(searchPath, "*.mia")
-	files, err := filepath.Glob(pattern)
+	// El registro lee el MBR y los EBRs de cada disco y los cruza con el sistema de montaje
+	disks, err := diskCommands.ListDisks(searchPath)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(ApiResponse{
-			Message: fmt.Sprintf("Error buscando archivos .mia: %v", err),
+			Message: fmt.Sprintf("Error buscando discos en %s: %v", searchPath, err),
 			Data:    []FileSystemInfo{},
 			Status:  "error",
 		})
 		return
 	}
 
-	// También buscar archivos .dsk
-	dskPattern := filepath.Join(searchPath, "*.dsk")
-	dskFiles, err := filepath.Glob(dskPattern)
-	if err == nil {
-		files = append(files, dskFiles...)
-	}
-
-	// Búsqueda manual adicional si Glob falla
-	if len(files) == 0 {
-		entries, err := os.ReadDir(searchPath)
-		if err == nil {
-			for _, entry := range entries {
-				if !entry.IsDir() {
-					name := entry.Name()
-					if strings.HasSuffix(strings.ToLower(name), ".mia") || strings.HasSuffix(strings.ToLower(name), ".dsk") {
-						fullPath := filepath.Join(searchPath, name)
-						files = append(files, fullPath)
-					}
-				}
-			}
-		}
-	}
-
-	for _, file := range files {
-		if stat, err := os.Stat(file); err == nil {
-			fileName := filepath.Base(file)
-			fileExt := strings.ToLower(filepath.Ext(fileName))
-
-			// Determinar tipo por extensión
-			diskType := "EXT2"
-			if fileExt == ".dsk" {
-				diskType = "DSK"
-			}
-
-			// Determinar si está montado
-			status := "unmounted"
-			mountPoint := ""
-
-			fileSystems = append(fileSystems, FileSystemInfo{
-				Name:       fileName,
-				Type:       diskType,
-				Size:       stat.Size(),
-				MountPoint: mountPoint,
-				Status:     status,
-				Path:       file,
-			})
-		}
+	fileSystems := []FileSystemInfo{}
+	for _, disk := range disks {
+		fileSystems = append(fileSystems, FileSystemInfo{
+			Name:        disk.Name,
+			Type:        disk.TableType,
+			Format:      disk.Format,
+			Size:        disk.Size,
+			FileSize:    disk.FileSize,
+			MountPoint:  strings.Join(disk.MountIDs, ", "),
+			MountIDs:    disk.MountIDs,
+			Status:      disk.Status,
+			Path:        disk.Path,
+			Label:       disk.Metadata.Label,
+			Description: disk.Metadata.Description,
+			Owner:       disk.Metadata.Owner,
+			Partitions:  disk.Partitions,
+			Error:       disk.Error,
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ApiResponse{
-		Message: fmt.Sprintf("Encontrados %d sistemas de archivos", len(fileSystems)),
+		Message: fmt.Sprintf("Encontrados %d discos", len(fileSystems)),
 		Data:    fileSystems,
 		Status:  "success",
 	})
@@ -314,7 +370,7 @@ func executeCommandHandler(w http.ResponseWriter, r *http.Request) {
 
 	if requestData.Command != "" {
 		// Ejecutar comando único
-		utils.LogInfo("API", fmt.Sprintf("Ejecutando comando único: %s", requestData.Command))
+		utils.LogInfo("API", fmt.Sprintf("Ejecutando comando único: %s", command.RedactCommandLine(requestData.Command)))
 		result := commandParser.ParseAndExecute(requestData.Command)
 		results = append(results, *result)
 		
@@ -392,6 +448,63 @@ func getSupportedCommandsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// diskCheckHandler verifica la integridad completa de un disco
+// La ruta del disco va codificada en la URL: /api/disks/%2Fhome%2Fdiscos%2FDisco1.mia/check
+func diskCheckHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	diskPath, err := url.PathUnescape(mux.Vars(r)["path"])
+	if err != nil || diskPath == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ApiResponse{
+			Message: "Ruta de disco inválida",
+			Status:  "error",
+		})
+		return
+	}
+
+	// Los comandos que modifican el disco esperan a que termine la verificación
+	lock, err := estructuras.LockDisks([]string{diskPath}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ApiResponse{
+			Message: err.Error(),
+			Status:  "error",
+		})
+		return
+	}
+	defer lock.Unlock()
+
+	devices := estructuras.OpenDevices([]string{diskPath})
+	defer devices.Close()
+
+	report, err := diskCommands.CheckDisk(diskPath)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ApiResponse{
+			Message: err.Error(),
+			Status:  "error",
+		})
+		return
+	}
+
+	status := "success"
+	message := fmt.Sprintf("El disco %s no tiene errores", diskPath)
+	if !report.IsHealthy() {
+		status = "error"
+		message = fmt.Sprintf("El disco %s tiene %d errores y %d advertencias", diskPath, report.Errors, report.Warnings)
+	} else if report.Warnings > 0 {
+		status = "warning"
+		message = fmt.Sprintf("El disco %s no tiene errores pero tiene %d advertencias", diskPath, report.Warnings)
+	}
+
+	json.NewEncoder(w).Encode(ApiResponse{
+		Message: message,
+		Data:    report,
+		Status:  status,
+	})
+}
+
 // wsHandler maneja las conexiones WebSocket para logs en tiempo real
 func wsHandler(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{
@@ -493,8 +606,16 @@ func debugPathHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	// Reconstruir montajes persistidos en los discos
+	ConfigureMountPersistence()
+
+	// Montar las particiones del archivo de montaje automático
+	ConfigureAutomount()
+
 	// Crear router
-	router := mux.NewRouter()
+	// Las rutas de disco van codificadas en la URL, por lo que se enruta con la ruta
+	// codificada y sin limpiar
+	router := mux.NewRouter().UseEncodedPath().SkipClean(true)
 
 	// Rutas de API
 	api := router.PathPrefix("/api").Subrouter()
@@ -503,6 +624,7 @@ func main() {
 	api.HandleFunc("/health", healthHandler).Methods("GET")
 	api.HandleFunc("/filesystems", getFileSystemsHandler).Methods("GET")
 	api.HandleFunc("/commands", getSupportedCommandsHandler).Methods("GET")
+	api.HandleFunc("/disks/{path:.+}/check", diskCheckHandler).Methods("GET")
 
 	// Endpoints para comandos
 	api.HandleFunc("/execute", executeCommandHandler).Methods("POST")
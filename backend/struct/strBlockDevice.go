@@ -0,0 +1,459 @@
+package estructuras
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Dispositivos de bloques
+// Toda la E/S de los discos pasa por un BlockDevice: un archivo .mia, un contenedor
+// comprimido (qmia, ver strQmiaDisk.go), un disco en memoria (mem://, ver
+// strMemoryDisk.go) o un disco con snapshots (sus capas copy-on-write). Mientras un
+// comando se ejecuta sus discos quedan abiertos (OpenDevices) y todas las lecturas y
+// escrituras del comando usan el mismo dispositivo, en lugar de abrir el archivo en cada una.
+// Fuera de un comando cada acceso abre y cierra un dispositivo temporal.
+
+// BlockDevice es un dispositivo de tamaño fijo que se lee y escribe por posición
+type BlockDevice interface {
+	ReadAt(data []byte, offset int64) (int, error)  // Lee len(data) bytes desde offset
+	WriteAt(data []byte, offset int64) (int, error) // Escribe data en offset
+	Size() int64                                    // Tamaño del dispositivo en bytes
+	Sync() error                                    // Lleva las escrituras al almacenamiento
+	Close() error                                   // Libera el dispositivo
+}
+
+// FileDevice es un dispositivo respaldado por un archivo del sistema
+type FileDevice struct {
+	file *os.File
+}
+
+// OpenFileDevice abre el archivo de un disco como dispositivo
+// Si el archivo no se puede escribir se abre solo para lectura y las escrituras fallan
+func OpenFileDevice(path string) (*FileDevice, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el disco: %v", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("la ruta especificada es un directorio, no un archivo")
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if os.IsPermission(err) {
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el disco: %v", err)
+	}
+
+	return &FileDevice{file: file}, nil
+}
+
+// ReadAt lee del archivo
+func (d *FileDevice) ReadAt(data []byte, offset int64) (int, error) {
+	return d.file.ReadAt(data, offset)
+}
+
+// WriteAt escribe en el archivo
+func (d *FileDevice) WriteAt(data []byte, offset int64) (int, error) {
+	return d.file.WriteAt(data, offset)
+}
+
+// Size retorna el tamaño actual del archivo (resizedisk puede cambiarlo)
+func (d *FileDevice) Size() int64 {
+	info, err := d.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Sync lleva las escrituras del archivo al disco físico
+func (d *FileDevice) Sync() error {
+	return d.file.Sync()
+}
+
+// Close cierra el archivo
+func (d *FileDevice) Close() error {
+	return d.file.Close()
+}
+
+// MemoryDevice es un dispositivo en memoria
+// Close no libera los datos: el dispositivo se puede seguir usando mientras exista
+type MemoryDevice struct {
+	mutex sync.RWMutex
+	data  []byte
+}
+
+// NewMemoryDevice crea un dispositivo en memoria lleno de ceros
+func NewMemoryDevice(size int64) *MemoryDevice {
+	return &MemoryDevice{data: make([]byte, size)}
+}
+
+// ReadAt lee del buffer; si el rango pasa del final retorna lo leído e io.EOF
+func (d *MemoryDevice) ReadAt(data []byte, offset int64) (int, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if offset < 0 {
+		return 0, fmt.Errorf("posición negativa: %d", offset)
+	}
+	if offset >= int64(len(d.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(data, d.data[offset:])
+	if n < len(data) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt escribe en el buffer; el dispositivo no crece
+func (d *MemoryDevice) WriteAt(data []byte, offset int64) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if offset < 0 || offset+int64(len(data)) > int64(len(d.data)) {
+		return 0, fmt.Errorf("rango [%d, %d) fuera del dispositivo de %d bytes", offset, offset+int64(len(data)), len(d.data))
+	}
+
+	return copy(d.data[offset:], data), nil
+}
+
+// Size retorna el tamaño del buffer
+func (d *MemoryDevice) Size() int64 {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return int64(len(d.data))
+}
+
+// Resize cambia el tamaño del buffer; el espacio agregado queda en ceros
+func (d *MemoryDevice) Resize(size int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if size <= int64(len(d.data)) {
+		d.data = d.data[:size:size]
+		return
+	}
+
+	data := make([]byte, size)
+	copy(data, d.data)
+	d.data = data
+}
+
+// Sync no hace nada: los datos ya están en memoria
+func (d *MemoryDevice) Sync() error {
+	return nil
+}
+
+// Close no hace nada: los datos se conservan
+func (d *MemoryDevice) Close() error {
+	return nil
+}
+
+// openDiskDevice abre el dispositivo de un disco: el disco en memoria, sus capas si tiene snapshots o su archivo (raw o qmia)
+func openDiskDevice(path string) (BlockDevice, error) {
+	if IsMemoryDisk(path) {
+		return memoryDisk(path)
+	}
+
+	index, err := loadSnapshotIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		return openSnapshotDevice(path, index)
+	}
+
+	return openDiskImage(path)
+}
+
+// diskDeviceEntry es un disco abierto por uno o más comandos en curso
+type diskDeviceEntry struct {
+	device BlockDevice // Se abre en el primer acceso; nil si aún no se usa
+	users  int         // Comandos que lo tienen abierto; en 0 se cierra
+}
+
+// Discos abiertos por los comandos en curso (Key: ruta absoluta del disco)
+var diskDevices = struct {
+	mutex   sync.Mutex
+	entries map[string]*diskDeviceEntry
+}{entries: make(map[string]*diskDeviceEntry)}
+
+// DeviceSet son los discos que un comando mantiene abiertos
+type DeviceSet struct {
+	keys []string
+}
+
+// OpenDevices mantiene abiertos los discos de un comando hasta Close
+// Cada disco se abre en su primer acceso, por lo que un disco que el comando crea
+// (mkdisk) también se puede incluir. Se debe llamar con los discos ya bloqueados.
+func OpenDevices(paths []string) *DeviceSet {
+	set := &DeviceSet{}
+	seen := make(map[string]bool)
+
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	for _, path := range paths {
+		key := diskLockKey(path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		retainDevice(key)
+		set.keys = append(set.keys, key)
+	}
+
+	return set
+}
+
+// retainDevice registra un usuario más del disco y retorna su entrada
+// Se debe llamar con diskDevices.mutex bloqueado
+func retainDevice(key string) *diskDeviceEntry {
+	entry, exists := diskDevices.entries[key]
+	if !exists {
+		entry = &diskDeviceEntry{}
+		diskDevices.entries[key] = entry
+	}
+	entry.users++
+	return entry
+}
+
+// releaseDevice quita un usuario del disco y lo cierra si ya no tiene ninguno
+// Se debe llamar con diskDevices.mutex bloqueado
+func releaseDevice(key string) {
+	entry := diskDevices.entries[key]
+	entry.users--
+	if entry.users > 0 {
+		return
+	}
+
+	if entry.device != nil {
+		entry.device.Close()
+	}
+	delete(diskDevices.entries, key)
+}
+
+// Close cierra los discos que ningún otro comando tiene abiertos
+func (s *DeviceSet) Close() {
+	if s == nil {
+		return
+	}
+
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	for _, key := range s.keys {
+		releaseDevice(key)
+	}
+	s.keys = nil
+}
+
+// CloseDevice cierra el dispositivo abierto de un disco; el siguiente acceso lo vuelve a abrir
+// Se usa cuando el disco cambia por fuera del dispositivo: al crear, restaurar o eliminar
+// un snapshot, o antes de borrar el archivo
+func CloseDevice(path string) {
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	entry, exists := diskDevices.entries[diskLockKey(path)]
+	if !exists || entry.device == nil {
+		return
+	}
+
+	entry.device.Close()
+	entry.device = nil
+}
+
+// DiskDevice es el dispositivo de un disco que un comando abre con OpenDiskDevice y pasa a
+// las funciones de las estructuras (ReadMBRFromDevice, ReadEBRFromDevice, ...)
+// Usa el dispositivo compartido del disco, el mismo que usan las funciones que reciben la
+// ruta mientras el disco está abierto, así que ve sus escrituras. Si el dispositivo se
+// cierra con CloseDevice, el siguiente acceso lo vuelve a abrir.
+// Escribir directamente en él no pasa por el diario de deshacer, el cifrado ni las sumas
+// de verificación: para modificar el disco se usa WriteToDisk con su ruta.
+type DiskDevice struct {
+	path  string
+	key   string
+	entry *diskDeviceEntry // nil después de Close
+}
+
+// OpenDiskDevice abre el dispositivo de un disco hasta Close
+// Si el disco ya está abierto (OpenDevices u otro OpenDiskDevice) se comparte ese dispositivo
+func OpenDiskDevice(path string) (*DiskDevice, error) {
+	key := diskLockKey(path)
+
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	entry := retainDevice(key)
+	if entry.device == nil {
+		device, err := openDiskDevice(path)
+		if err != nil {
+			releaseDevice(key)
+			return nil, err
+		}
+		entry.device = device
+	}
+
+	return &DiskDevice{path: path, key: key, entry: entry}, nil
+}
+
+// Path retorna la ruta del disco
+func (d *DiskDevice) Path() string {
+	return d.path
+}
+
+// current retorna el dispositivo abierto del disco, abriéndolo de nuevo si se cerró con CloseDevice
+func (d *DiskDevice) current() (BlockDevice, error) {
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	if d.entry == nil {
+		return nil, fmt.Errorf("el dispositivo del disco %s ya se cerró", d.path)
+	}
+	if d.entry.device == nil {
+		device, err := openDiskDevice(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.entry.device = device
+	}
+	return d.entry.device, nil
+}
+
+// ReadAt lee del dispositivo del disco
+func (d *DiskDevice) ReadAt(p []byte, off int64) (int, error) {
+	device, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return device.ReadAt(p, off)
+}
+
+// WriteAt escribe en el dispositivo del disco
+func (d *DiskDevice) WriteAt(p []byte, off int64) (int, error) {
+	device, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	return device.WriteAt(p, off)
+}
+
+// Size retorna el tamaño del disco (0 si el dispositivo no se puede abrir)
+func (d *DiskDevice) Size() int64 {
+	device, err := d.current()
+	if err != nil {
+		return 0
+	}
+	return device.Size()
+}
+
+// Sync sincroniza el dispositivo del disco
+func (d *DiskDevice) Sync() error {
+	device, err := d.current()
+	if err != nil {
+		return err
+	}
+	return device.Sync()
+}
+
+// Close suelta el dispositivo; se cierra cuando nadie más tiene el disco abierto
+func (d *DiskDevice) Close() error {
+	diskDevices.mutex.Lock()
+	defer diskDevices.mutex.Unlock()
+
+	if d.entry != nil {
+		releaseDevice(d.key)
+		d.entry = nil
+	}
+	return nil
+}
+
+// devicePath retorna la ruta del disco de un DiskDevice ("" para otros dispositivos)
+func devicePath(device BlockDevice) string {
+	if disk, ok := device.(*DiskDevice); ok {
+		return disk.path
+	}
+	return ""
+}
+
+// diskView retorna la vista con la que se leen las estructuras de un dispositivo
+// En un DiskDevice las particiones cifradas desbloqueadas se ven descifradas, igual que con ReadFromDisk
+func diskView(device BlockDevice) BlockDevice {
+	if disk, ok := device.(*DiskDevice); ok {
+		return cryptView(disk.path, disk)
+	}
+	return device
+}
+
+// acquireDevice retorna el dispositivo de un disco y la función para soltarlo
+// Si un comando tiene el disco abierto se usa ese dispositivo; si no, se abre uno temporal
+// Lo usan las funciones que reciben la ruta del disco en lugar de un DiskDevice
+func acquireDevice(path string) (BlockDevice, func(), error) {
+	diskDevices.mutex.Lock()
+	entry, exists := diskDevices.entries[diskLockKey(path)]
+	if exists {
+		defer diskDevices.mutex.Unlock()
+
+		if entry.device == nil {
+			device, err := openDiskDevice(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			entry.device = device
+		}
+		return entry.device, func() {}, nil
+	}
+	diskDevices.mutex.Unlock()
+
+	device, err := openDiskDevice(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return device, func() { device.Close() }, nil
+}
+
+// DiskSize retorna el tamaño de un disco visto a través de su dispositivo
+func DiskSize(path string) (int64, error) {
+	device, release, err := acquireDevice(path)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return device.Size(), nil
+}
+
+// ReadFromDevice lee un rango completo de un dispositivo
+func ReadFromDevice(device BlockDevice, offset int64, size int) ([]byte, error) {
+	if offset < 0 || offset+int64(size) > device.Size() {
+		return nil, fmt.Errorf("no se leyeron suficientes bytes del disco: rango [%d, %d) fuera del disco", offset, offset+int64(size))
+	}
+
+	data := make([]byte, size)
+	n, err := device.ReadAt(data, offset)
+	if n == size {
+		return data, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error al leer del disco: %v", err)
+	}
+
+	return nil, fmt.Errorf("no se leyeron suficientes bytes del disco: %d de %d", n, size)
+}
+
+// WriteToDevice escribe datos en un dispositivo
+func WriteToDevice(device BlockDevice, data []byte, offset int64) error {
+	if _, err := device.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("error al escribir en el disco: %v", err)
+	}
+	return nil
+}
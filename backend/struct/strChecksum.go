@@ -0,0 +1,398 @@
+package estructuras
+
+import (
+	utils "backend/Utils"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// Sumas de verificación por bloques
+// Un disco puede tener opcionalmente una tabla con el CRC32C de cada región de 4KB.
+// Cada escritura con WriteToDisk (o WriteRawToDisk) actualiza las sumas de las regiones
+// que toca, así que una región cuya suma ya no coincide fue modificada por fuera del
+// programa o se dañó en el almacenamiento (ver ScrubDisk). Las sumas se calculan sobre
+// los bytes guardados: de una partición cifrada se verifica el texto cifrado.
+//
+// La tabla se guarda junto al disco, en el archivo <disco>.sums:
+/*
+| Nombre      | Tipo     | Descripción                                         |
+|-------------|----------|-----------------------------------------------------|
+| magic       | char[8]  | "MIASUMS1"                                          |
+| region_size | uint32   | Bytes de cada región (4096)                         |
+| reserved    | uint32   | Sin uso (0)                                         |
+| disk_size   | int64    | Tamaño del disco cuando se calcularon las sumas     |
+| sums        | uint32[] | CRC32C de cada región; la última puede ser más corta |
+*/
+
+// Tamaño de las regiones con suma de verificación
+const CHECKSUM_REGION_SIZE int64 = 4096
+
+// Firma del archivo de sumas
+var checksumMagic = [8]byte{'M', 'I', 'A', 'S', 'U', 'M', 'S', '1'}
+
+// Tabla del CRC32C (Castagnoli)
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Las sumas se calculan leyendo el disco en bloques de este tamaño
+const checksumReadSize = 256 * CHECKSUM_REGION_SIZE
+
+// checksumHeader es la cabecera del archivo de sumas
+type checksumHeader struct {
+	Magic      [8]byte `binary:"little"`
+	RegionSize uint32  `binary:"little"`
+	Reserved   uint32  `binary:"little"`
+	DiskSize   int64   `binary:"little"`
+}
+
+// Tamaño serializado de la cabecera
+var checksumHeaderSize = int64(binary.Size(checksumHeader{}))
+
+// ChecksumInfo describe la tabla de sumas de un disco
+type ChecksumInfo struct {
+	DiskSize   int64 `json:"disk_size"`   // Tamaño del disco cubierto por las sumas
+	RegionSize int64 `json:"region_size"` // Bytes de cada región
+	Regions    int64 `json:"regions"`     // Cantidad de regiones
+}
+
+// ScrubRange es un rango del disco cuyas sumas no coinciden
+type ScrubRange struct {
+	Offset int64 `json:"offset"` // Byte donde inicia el rango
+	Length int64 `json:"length"` // Bytes del rango (regiones consecutivas)
+}
+
+// ScrubReport es el resultado de verificar las sumas de un disco
+type ScrubReport struct {
+	Path       string       `json:"path"`        // Ruta del disco
+	DiskSize   int64        `json:"disk_size"`   // Tamaño del disco verificado
+	RegionSize int64        `json:"region_size"` // Bytes de cada región
+	Regions    int64        `json:"regions"`     // Regiones verificadas
+	BadRegions int64        `json:"bad_regions"` // Regiones cuya suma no coincide
+	BadRanges  []ScrubRange `json:"bad_ranges"`  // Rangos que no coinciden, en orden
+}
+
+// checksumPath retorna la ruta del archivo de sumas de un disco
+func checksumPath(path string) string {
+	return path + ".sums"
+}
+
+// checksumRegions retorna la cantidad de regiones de un disco del tamaño indicado
+func checksumRegions(diskSize int64) int64 {
+	return (diskSize + CHECKSUM_REGION_SIZE - 1) / CHECKSUM_REGION_SIZE
+}
+
+// HasChecksums indica si el disco tiene sumas de verificación
+func HasChecksums(path string) bool {
+	if IsMemoryDisk(path) {
+		return false
+	}
+	_, err := os.Stat(checksumPath(path))
+	return err == nil
+}
+
+// EnableChecksums calcula las sumas de todas las regiones del disco con su contenido actual
+// Si el disco ya tenía sumas se reemplazan: las diferencias pendientes se aceptan como válidas
+func EnableChecksums(path string) (*ChecksumInfo, error) {
+	if IsMemoryDisk(path) {
+		return nil, fmt.Errorf("los discos en memoria no admiten sumas de verificación")
+	}
+
+	device, release, err := acquireDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	diskSize := device.Size()
+	sums := make([]uint32, checksumRegions(diskSize))
+	if err := computeChecksums(device, diskSize, 0, sums); err != nil {
+		return nil, err
+	}
+
+	if err := saveChecksums(path, diskSize, sums); err != nil {
+		return nil, err
+	}
+
+	return &ChecksumInfo{DiskSize: diskSize, RegionSize: CHECKSUM_REGION_SIZE, Regions: int64(len(sums))}, nil
+}
+
+// RemoveChecksums elimina las sumas de verificación del disco
+func RemoveChecksums(path string) error {
+	if IsMemoryDisk(path) {
+		return nil
+	}
+	if err := os.Remove(checksumPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error al eliminar las sumas de verificación: %v", err)
+	}
+	return nil
+}
+
+// computeChecksums calcula las sumas de las regiones desde first hasta completar sums
+// sums[i] es la suma de la región first+i
+func computeChecksums(device BlockDevice, diskSize, first int64, sums []uint32) error {
+	end := (first + int64(len(sums))) * CHECKSUM_REGION_SIZE
+	if end > diskSize {
+		end = diskSize
+	}
+
+	for offset := first * CHECKSUM_REGION_SIZE; offset < end; offset += checksumReadSize {
+		chunk := checksumReadSize
+		if end-offset < chunk {
+			chunk = end - offset
+		}
+
+		data, err := ReadFromDevice(device, offset, int(chunk))
+		if err != nil {
+			return err
+		}
+
+		for start := int64(0); start < chunk; start += CHECKSUM_REGION_SIZE {
+			stop := start + CHECKSUM_REGION_SIZE
+			if stop > chunk {
+				stop = chunk
+			}
+			sums[(offset+start)/CHECKSUM_REGION_SIZE-first] = crc32.Checksum(data[start:stop], checksumTable)
+		}
+	}
+
+	return nil
+}
+
+// loadChecksums lee la tabla de sumas del disco; retorna el tamaño cubierto y las sumas
+func loadChecksums(path string) (int64, []uint32, error) {
+	data, err := os.ReadFile(checksumPath(path))
+	if os.IsNotExist(err) {
+		return 0, nil, fmt.Errorf("el disco no tiene sumas de verificación, actívelas con scrub -enable")
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("error al leer las sumas de verificación: %v", err)
+	}
+
+	header, err := parseChecksumHeader(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	regions := checksumRegions(header.DiskSize)
+	if int64(len(data)) != checksumHeaderSize+regions*4 {
+		return 0, nil, fmt.Errorf("archivo de sumas dañado: %d bytes para %d regiones", len(data), regions)
+	}
+
+	sums := make([]uint32, regions)
+	if err := binary.Read(bytes.NewReader(data[checksumHeaderSize:]), binary.LittleEndian, sums); err != nil {
+		return 0, nil, fmt.Errorf("error al leer las sumas de verificación: %v", err)
+	}
+
+	return header.DiskSize, sums, nil
+}
+
+// parseChecksumHeader valida la cabecera del archivo de sumas
+func parseChecksumHeader(data []byte) (*checksumHeader, error) {
+	header := &checksumHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("archivo de sumas dañado: %v", err)
+	}
+	if header.Magic != checksumMagic || int64(header.RegionSize) != CHECKSUM_REGION_SIZE || header.DiskSize < 0 {
+		return nil, fmt.Errorf("archivo de sumas dañado o de una versión no soportada")
+	}
+	return header, nil
+}
+
+// saveChecksums escribe la tabla de sumas completa
+func saveChecksums(path string, diskSize int64, sums []uint32) error {
+	header := checksumHeader{
+		Magic:      checksumMagic,
+		RegionSize: uint32(CHECKSUM_REGION_SIZE),
+		DiskSize:   diskSize,
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("error al serializar las sumas de verificación: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, sums); err != nil {
+		return fmt.Errorf("error al serializar las sumas de verificación: %v", err)
+	}
+
+	// Escribir en un archivo temporal y renombrar para no dejar una tabla a medias
+	sumsPath := checksumPath(path)
+	if err := os.WriteFile(sumsPath+".tmp", buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error al escribir las sumas de verificación: %v", err)
+	}
+	if err := os.Rename(sumsPath+".tmp", sumsPath); err != nil {
+		return fmt.Errorf("error al escribir las sumas de verificación: %v", err)
+	}
+
+	return nil
+}
+
+// updateChecksums recalcula las sumas de las regiones que toca una escritura
+// device debe ser el dispositivo del disco sin descifrar (las sumas son del contenido guardado)
+func updateChecksums(path string, device BlockDevice, offset int64, length int) error {
+	if IsMemoryDisk(path) || length <= 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(checksumPath(path), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error al abrir las sumas de verificación: %v", err)
+	}
+	defer file.Close()
+
+	data := make([]byte, checksumHeaderSize)
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return fmt.Errorf("error al leer las sumas de verificación: %v", err)
+	}
+	header, err := parseChecksumHeader(data)
+	if err != nil {
+		return err
+	}
+
+	// Las escrituras fuera del tamaño registrado no tienen suma (el disco se redimensiona con ResizeChecksums)
+	end := offset + int64(length)
+	if end > header.DiskSize {
+		end = header.DiskSize
+	}
+	if offset >= end {
+		return nil
+	}
+
+	first := offset / CHECKSUM_REGION_SIZE
+	sums := make([]uint32, checksumRegions(end)-first)
+	if err := computeChecksums(device, header.DiskSize, first, sums); err != nil {
+		return fmt.Errorf("error al actualizar las sumas de verificación: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, sums); err != nil {
+		return fmt.Errorf("error al serializar las sumas de verificación: %v", err)
+	}
+	if _, err := file.WriteAt(buf.Bytes(), checksumHeaderSize+first*4); err != nil {
+		return fmt.Errorf("error al actualizar las sumas de verificación: %v", err)
+	}
+
+	return nil
+}
+
+// warnStaleChecksums actualiza las sumas de una escritura ya hecha
+// Si no se pueden actualizar la escritura sigue siendo válida: sus regiones quedan con
+// la suma anterior y scrub las reporta, así que solo se advierte
+func warnStaleChecksums(path string, device BlockDevice, offset int64, length int) {
+	if err := updateChecksums(path, device, offset, length); err != nil {
+		utils.LogWarning("Checksums", fmt.Sprintf("Las sumas de [%d, %d) en %s quedan desactualizadas: %v", offset, offset+int64(length), path, err))
+	}
+}
+
+// ResizeChecksums ajusta las sumas del disco a su tamaño nuevo
+// Se recalculan desde la región donde termina el tamaño menor de los dos; las demás se conservan
+func ResizeChecksums(path string) error {
+	if !HasChecksums(path) {
+		return nil
+	}
+
+	oldSize, sums, err := loadChecksums(path)
+	if err != nil {
+		return err
+	}
+
+	device, release, err := acquireDevice(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	diskSize := device.Size()
+	if diskSize == oldSize {
+		return nil
+	}
+
+	first := oldSize / CHECKSUM_REGION_SIZE
+	if diskSize < oldSize {
+		first = diskSize / CHECKSUM_REGION_SIZE
+	}
+
+	resized := make([]uint32, checksumRegions(diskSize))
+	copy(resized, sums[:first])
+	if err := computeChecksums(device, diskSize, first, resized[first:]); err != nil {
+		return err
+	}
+
+	return saveChecksums(path, diskSize, resized)
+}
+
+// refreshChecksums recalcula todas las sumas del disco si las tiene
+// Se usa cuando el contenido cambia sin pasar por WriteToDisk (al restaurar un snapshot)
+func refreshChecksums(path string) error {
+	if !HasChecksums(path) {
+		return nil
+	}
+	_, err := EnableChecksums(path)
+	return err
+}
+
+// ScrubDisk verifica las sumas de todas las regiones del disco
+// Las regiones consecutivas que no coinciden se reportan como un solo rango
+func ScrubDisk(path string) (*ScrubReport, error) {
+	if IsMemoryDisk(path) {
+		return nil, fmt.Errorf("los discos en memoria no admiten sumas de verificación")
+	}
+
+	// Sin el disco tampoco hay sumas, pero el problema es el disco
+	if !DiskExists(path) {
+		return nil, fmt.Errorf("el disco no existe: %s", path)
+	}
+
+	diskSize, sums, err := loadChecksums(path)
+	if err != nil {
+		return nil, err
+	}
+
+	device, release, err := acquireDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if size := device.Size(); size != diskSize {
+		return nil, fmt.Errorf("las sumas de verificación son de un disco de %d bytes y el disco tiene %d, actívelas de nuevo con scrub -enable", diskSize, size)
+	}
+
+	current := make([]uint32, len(sums))
+	if err := computeChecksums(device, diskSize, 0, current); err != nil {
+		return nil, err
+	}
+
+	report := &ScrubReport{
+		Path:       path,
+		DiskSize:   diskSize,
+		RegionSize: CHECKSUM_REGION_SIZE,
+		Regions:    int64(len(sums)),
+		BadRanges:  []ScrubRange{},
+	}
+
+	for i := range sums {
+		if current[i] == sums[i] {
+			continue
+		}
+		report.BadRegions++
+
+		offset := int64(i) * CHECKSUM_REGION_SIZE
+		length := CHECKSUM_REGION_SIZE
+		if offset+length > diskSize {
+			length = diskSize - offset
+		}
+
+		if last := len(report.BadRanges) - 1; last >= 0 && report.BadRanges[last].Offset+report.BadRanges[last].Length == offset {
+			report.BadRanges[last].Length += length
+		} else {
+			report.BadRanges = append(report.BadRanges, ScrubRange{Offset: offset, Length: length})
+		}
+	}
+
+	return report, nil
+}
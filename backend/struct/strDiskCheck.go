@@ -0,0 +1,310 @@
+package estructuras
+
+import (
+	systemfileext2 "backend/struct/systemFileExt2"
+	"fmt"
+)
+
+// Verificación completa de un disco
+// A diferencia de una validación que se detiene en el primer error, el verificador
+// recorre el MBR (y su copia de respaldo o la tabla GPT), la cadena de EBRs de la
+// extendida y el superbloque EXT2 de cada partición formateada, y reporta todos los
+// hallazgos con su severidad, código y posición en el disco.
+
+// Severidades de los hallazgos
+const (
+	SeverityError   = "error"   // El disco está dañado o es inconsistente
+	SeverityWarning = "warning" // El disco funciona pero algo no está como debería
+	SeverityInfo    = "info"    // Información relevante, no es un problema
+)
+
+// DiskFinding es un hallazgo del verificador de discos
+type DiskFinding struct {
+	Severity string `json:"severity"` // SeverityError, SeverityWarning o SeverityInfo
+	Code     string `json:"code"`     // Código del hallazgo (ej: partition_overlap)
+	Offset   int64  `json:"offset"`   // Byte del disco al que se refiere el hallazgo
+	Message  string `json:"message"`  // Descripción del hallazgo
+}
+
+// DiskCheckReport es el resultado de verificar un disco
+type DiskCheckReport struct {
+	Path      string        `json:"path"`       // Ruta del disco
+	Size      int64         `json:"size"`       // Tamaño del archivo del disco
+	TableType string        `json:"table_type"` // Tipo de tabla de particiones (MBR o GPT)
+	Errors    int           `json:"errors"`     // Cantidad de hallazgos con severidad error
+	Warnings  int           `json:"warnings"`   // Cantidad de hallazgos con severidad warning
+	Findings  []DiskFinding `json:"findings"`   // Todos los hallazgos
+}
+
+// IsHealthy indica si el disco no tiene hallazgos con severidad error
+func (r *DiskCheckReport) IsHealthy() bool {
+	return r.Errors == 0
+}
+
+// FirstError retorna el primer hallazgo con severidad error, o nil si no hay
+func (r *DiskCheckReport) FirstError() *DiskFinding {
+	for i := range r.Findings {
+		if r.Findings[i].Severity == SeverityError {
+			return &r.Findings[i]
+		}
+	}
+	return nil
+}
+
+// add registra un hallazgo en el reporte
+func (r *DiskCheckReport) add(severity, code string, offset int64, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, DiskFinding{
+		Severity: severity,
+		Code:     code,
+		Offset:   offset,
+		Message:  fmt.Sprintf(format, args...),
+	})
+
+	switch severity {
+	case SeverityError:
+		r.Errors++
+	case SeverityWarning:
+		r.Warnings++
+	}
+}
+
+// Severidad de cada problema de la cadena de EBRs
+var ebrIssueSeverity = map[string]string{
+	EBRIssueCycle:      SeverityError,
+	EBRIssueOutOfRange: SeverityError,
+	EBRIssueInvalid:    SeverityError,
+	EBRIssueOverlap:    SeverityError,
+	EBRIssueUnsorted:   SeverityWarning,
+	EBRIssueOrphan:     SeverityWarning,
+}
+
+// CheckDisk verifica el disco completo y retorna todos los hallazgos (ver CheckDiskOnDevice)
+// Solo retorna error si el archivo no se puede abrir; los problemas del disco son hallazgos
+func CheckDisk(path string) (*DiskCheckReport, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	return CheckDiskOnDevice(device)
+}
+
+// CheckDiskOnDevice verifica el disco completo a través de su dispositivo
+// El contenido de las particiones cifradas solo se verifica en un DiskDevice con la partición desbloqueada
+func CheckDiskOnDevice(device BlockDevice) (*DiskCheckReport, error) {
+	size := device.Size()
+
+	report := &DiskCheckReport{
+		Path:     devicePath(device),
+		Size:     size,
+		Findings: []DiskFinding{},
+	}
+
+	mbr := report.checkMBRCopies(device)
+	if mbr == nil {
+		return report, nil
+	}
+	report.TableType = mbr.GetTipoTablaString()
+
+	if mbr.MbrTamanio != size {
+		report.add(SeverityError, "disk_size_mismatch", 0,
+			"el tamaño del disco (%d) no coincide con el tamaño en el MBR (%d)", size, mbr.MbrTamanio)
+	}
+
+	if mbr.IsGPT() {
+		report.checkGPT(device, mbr)
+	}
+
+	report.checkPartitions(device, mbr)
+
+	return report, nil
+}
+
+// checkMBRCopies verifica el MBR principal y su copia de respaldo, y retorna el MBR a usar
+func (r *DiskCheckReport) checkMBRCopies(device BlockDevice) *MBR {
+	primary, primaryErr := readPrimaryMBR(device)
+	backup, backupErr := readBackupMBR(device)
+
+	switch {
+	case primaryErr != nil && backupErr != nil:
+		r.add(SeverityError, "mbr_corrupt", 0, "el MBR está dañado (%v) y no tiene copia de respaldo válida (%v)", primaryErr, backupErr)
+		return nil
+	case primaryErr != nil:
+		// El disco sigue siendo utilizable: ReadMBR usa la copia de respaldo
+		r.add(SeverityWarning, "mbr_primary_corrupt", 0, "el MBR principal está dañado (%v), use repairmbr para restaurarlo desde la copia", primaryErr)
+	case backupErr != nil:
+		r.add(SeverityWarning, "mbr_backup_invalid", primary.GetBackupMBROffset(), "la copia de respaldo del MBR no es válida: %v", backupErr)
+	default:
+		primaryData, _ := SerializeMBR(primary)
+		backupData, _ := SerializeMBR(backup)
+		if string(primaryData) != string(backupData) {
+			r.add(SeverityWarning, "mbr_backup_stale", primary.GetBackupMBROffset(), "la copia de respaldo del MBR no coincide con el MBR principal")
+		}
+	}
+
+	// ReadMBR usa la copia de respaldo si el principal está dañado y lee la tabla GPT
+	mbr, err := ReadMBRFromDevice(device)
+	if err != nil {
+		r.add(SeverityError, "table_unreadable", 0, "no se pudo leer la tabla de particiones: %v", err)
+		return nil
+	}
+
+	return mbr
+}
+
+// checkGPT verifica las dos copias de la tabla GPT
+func (r *DiskCheckReport) checkGPT(device BlockDevice, mbr *MBR) {
+	if mbr.MbrTamanio%GPT_SECTOR_SIZE != 0 {
+		r.add(SeverityError, "gpt_size_alignment", 0, "el tamaño de un disco GPT debe ser múltiplo de %d bytes", GPT_SECTOR_SIZE)
+	}
+
+	if _, _, err := readGPTTable(device, GPT_HEADER_OFFSET); err != nil {
+		r.add(SeverityWarning, "gpt_primary_corrupt", GPT_HEADER_OFFSET, "la tabla GPT principal está dañada: %v", err)
+	}
+
+	backupOffset := mbr.MbrTamanio - GPT_SECTOR_SIZE
+	if _, _, err := readGPTTable(device, backupOffset); err != nil {
+		r.add(SeverityWarning, "gpt_backup_corrupt", backupOffset, "la tabla GPT de respaldo está dañada: %v", err)
+	}
+}
+
+// checkPartitions verifica las particiones de la tabla, la cadena de EBRs y los superbloques
+func (r *DiskCheckReport) checkPartitions(device BlockDevice, mbr *MBR) {
+	if !mbr.ValidarFit() {
+		r.add(SeverityError, "invalid_fit", 0, "tipo de ajuste inválido en el MBR: %c", mbr.MbrFit)
+	}
+
+	extendedCount := 0
+	for i := range mbr.MbrParticiones {
+		partition := &mbr.MbrParticiones[i]
+		if partition.IsEmpty() {
+			continue
+		}
+		name := partition.GetName()
+
+		if err := partition.ValidatePartition(); err != nil {
+			r.add(SeverityError, "partition_invalid", partition.PartStart, "partición %d ('%s') inválida: %v", i, name, err)
+			continue
+		}
+
+		if partition.PartStart < mbr.FirstUsableByte() || partition.GetEndPosition() > mbr.LastUsableByte() {
+			r.add(SeverityError, "partition_out_of_bounds", partition.PartStart,
+				"la partición %d ('%s') [%d, %d) está fuera del espacio utilizable del disco [%d, %d)",
+				i, name, partition.PartStart, partition.GetEndPosition(), mbr.FirstUsableByte(), mbr.LastUsableByte())
+		}
+
+		if mbr.IsGPT() && !partition.IsPrimary() {
+			r.add(SeverityError, "gpt_non_primary", partition.PartStart, "la partición %d ('%s') de un disco GPT no es primaria", i, name)
+		}
+
+		for j := i + 1; j < len(mbr.MbrParticiones); j++ {
+			if partition.Overlaps(&mbr.MbrParticiones[j]) {
+				r.add(SeverityError, "partition_overlap", mbr.MbrParticiones[j].PartStart,
+					"las particiones '%s' y '%s' se superponen", name, mbr.MbrParticiones[j].GetName())
+			}
+		}
+
+		if partition.IsExtended() {
+			extendedCount++
+			if extendedCount > 1 {
+				r.add(SeverityError, "multiple_extended", partition.PartStart, "el disco tiene más de una partición extendida ('%s')", name)
+				continue
+			}
+			r.checkLogicalPartitions(device, partition)
+			continue
+		}
+
+		r.checkSuperblock(device, name, partition.PartStart, partition.PartSize)
+	}
+}
+
+// checkLogicalPartitions verifica la cadena de EBRs y el superbloque de cada lógica
+func (r *DiskCheckReport) checkLogicalPartitions(device BlockDevice, extended *Partition) {
+	ebrReport, err := CheckEBRChainOnDevice(device, extended)
+	if err != nil {
+		r.add(SeverityError, "ebr_unreadable", extended.PartStart, "no se pudo leer la cadena de EBRs: %v", err)
+		return
+	}
+
+	for _, issue := range ebrReport.Issues {
+		r.add(ebrIssueSeverity[issue.Code], "ebr_"+issue.Code, issue.Position, "%s", issue.Message)
+	}
+
+	for _, node := range ebrReport.Logicals {
+		r.checkSuperblock(device, node.EBR.GetName(), node.EBR.PartStart, node.EBR.PartSize)
+	}
+}
+
+// checkSuperblock verifica el superbloque EXT2 al inicio de una partición, si la partición está formateada
+// En una partición cifrada el superbloque está después de la cabecera y solo se ve desbloqueada
+func (r *DiskCheckReport) checkSuperblock(device BlockDevice, name string, start, size int64) {
+	header, err := readCryptHeaderFromDevice(device, start, size)
+	if err != nil {
+		r.add(SeverityError, "partition_encryption", start, "la partición '%s': %v", name, err)
+		return
+	}
+	if header != nil {
+		if path := devicePath(device); path == "" || !IsPartitionUnlocked(path, start) {
+			r.add(SeverityInfo, "partition_encrypted", start, "la partición '%s' está cifrada y bloqueada, su contenido no se verifica", name)
+			return
+		}
+		r.add(SeverityInfo, "partition_encrypted", start, "la partición '%s' está cifrada y desbloqueada", name)
+		dataStart, dataEnd := cryptDataRange(start, size)
+		start, size = dataStart, dataEnd-dataStart
+	}
+
+	if size < int64(systemfileext2.SUPERBLOCK_SIZE) {
+		return
+	}
+
+	data, err := ReadFromDevice(diskView(device), start, systemfileext2.SUPERBLOCK_SIZE)
+	if err != nil {
+		r.add(SeverityError, "partition_unreadable", start, "no se pudo leer la partición '%s': %v", name, err)
+		return
+	}
+
+	superblock, err := systemfileext2.DeserializeSuperblock(data)
+	if err != nil || !superblock.HasMagic() {
+		return // Partición sin formatear
+	}
+
+	r.add(SeverityInfo, "ext2_found", start, "la partición '%s' tiene un sistema de archivos EXT%d", name, superblock.SFilesystemType)
+
+	if superblock.SFilesystemType != 2 && superblock.SFilesystemType != 3 {
+		r.add(SeverityWarning, "ext2_unknown_type", start, "tipo de sistema de archivos desconocido en '%s': %d", name, superblock.SFilesystemType)
+	}
+
+	if superblock.SInodesCount <= 0 || superblock.SBlocksCount <= 0 ||
+		superblock.SInodeSize <= 0 || superblock.SBlockSize <= 0 {
+		r.add(SeverityError, "ext2_invalid_sizes", start,
+			"el superbloque de '%s' tiene cantidades o tamaños inválidos (inodos %d de %d bytes, bloques %d de %d bytes)",
+			name, superblock.SInodesCount, superblock.SInodeSize, superblock.SBlocksCount, superblock.SBlockSize)
+		return
+	}
+
+	if superblock.SFreeInodesCount < 0 || superblock.SFreeInodesCount > superblock.SInodesCount ||
+		superblock.SFreeBlocksCount < 0 || superblock.SFreeBlocksCount > superblock.SBlocksCount {
+		r.add(SeverityError, "ext2_invalid_free_counts", start,
+			"el superbloque de '%s' tiene contadores libres inválidos (inodos libres %d de %d, bloques libres %d de %d)",
+			name, superblock.SFreeInodesCount, superblock.SInodesCount, superblock.SFreeBlocksCount, superblock.SBlocksCount)
+	}
+
+	// Las regiones deben estar en orden dentro de la partición
+	end := start + size
+	bmInodeStart := int64(superblock.SBmInodeStart)
+	bmBlockStart := int64(superblock.SBmBlockStart)
+	inodeStart := int64(superblock.SInodeStart)
+	blockStart := int64(superblock.SBlockStart)
+	blocksEnd := blockStart + int64(superblock.SBlocksCount)*int64(superblock.SBlockSize)
+
+	if bmInodeStart < start+int64(systemfileext2.SUPERBLOCK_SIZE) ||
+		bmBlockStart < bmInodeStart+int64(superblock.SInodesCount) ||
+		inodeStart < bmBlockStart+int64(superblock.SBlocksCount) ||
+		blockStart < inodeStart+int64(superblock.SInodesCount)*int64(superblock.SInodeSize) ||
+		blocksEnd > end {
+		r.add(SeverityError, "ext2_layout", start,
+			"las regiones del sistema de archivos de '%s' no están en orden dentro de la partición [%d, %d): bitmap de inodos %d, bitmap de bloques %d, inodos %d, bloques %d-%d",
+			name, start, end, bmInodeStart, bmBlockStart, inodeStart, blockStart, blocksEnd)
+	}
+}
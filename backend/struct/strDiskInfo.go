@@ -14,14 +14,15 @@ type DiskInfo struct {
 	CreationDate     time.Time `json:"creation_date"`
 	DiskSignature    int64     `json:"disk_signature"`
 	Fit              string    `json:"fit"`
+	TableType        string    `json:"table_type"`
 	ActivePartitions int       `json:"active_partitions"`
 	FreeSpace        int64     `json:"free_space"`
 }
 
 // GetDiskInfo obtiene información completa sobre un disco
 func GetDiskInfo(path string) (*DiskInfo, error) {
-	// Verificar que el archivo existe
-	fileInfo, err := os.Stat(path)
+	// Verificar que el disco existe
+	size, err := DiskSize(path)
 	if err != nil {
 		return nil, fmt.Errorf("error al acceder al disco: %v", err)
 	}
@@ -35,10 +36,11 @@ func GetDiskInfo(path string) (*DiskInfo, error) {
 	// Crear información del disco
 	diskInfo := &DiskInfo{
 		Path:             path,
-		Size:             fileInfo.Size(),
+		Size:             size,
 		CreationDate:     time.Unix(mbr.MbrFechaCreacion, 0),
 		DiskSignature:    mbr.MbrDiskSignature,
 		Fit:              string(mbr.MbrFit),
+		TableType:        mbr.GetTipoTablaString(),
 		ActivePartitions: mbr.CountActivePartitions(),
 		FreeSpace:        mbr.GetFreeSpace(),
 	}
@@ -46,65 +48,38 @@ func GetDiskInfo(path string) (*DiskInfo, error) {
 	return diskInfo, nil
 }
 
-// ValidateDiskIntegrity valida la integridad de un disco
+// ValidateDiskIntegrity valida la integridad de un disco (ver ValidateDiskIntegrityOnDevice)
 func ValidateDiskIntegrity(path string) error {
-	utils.LogInfo("ValidateDisk", fmt.Sprintf("Validando integridad del disco: %s", path))
-
-	// Verificar que el archivo existe
-	fileInfo, err := os.Stat(path)
+	device, err := OpenDiskDevice(path)
 	if err != nil {
-		return fmt.Errorf("el archivo del disco no existe: %v", err)
-	}
-
-	// Verificar que no es un directorio
-	if fileInfo.IsDir() {
-		return fmt.Errorf("la ruta especificada es un directorio, no un archivo")
+		return err
 	}
+	defer device.Close()
 
-	// Leer y validar el MBR
-	mbr, err := ReadMBR(path)
-	if err != nil {
-		return fmt.Errorf("error al leer MBR: %v", err)
-	}
+	return ValidateDiskIntegrityOnDevice(device)
+}
 
-	// Validar que el tamaño del archivo coincide con el MBR
-	if fileInfo.Size() != mbr.MbrTamanio {
-		return fmt.Errorf("el tamaño del archivo (%d) no coincide con el tamaño en el MBR (%d)",
-			fileInfo.Size(), mbr.MbrTamanio)
-	}
+// ValidateDiskIntegrityOnDevice valida la integridad de un disco a través de su dispositivo
+func ValidateDiskIntegrityOnDevice(device BlockDevice) error {
+	utils.LogInfo("ValidateDisk", fmt.Sprintf("Validando integridad del disco: %s", devicePath(device)))
 
-	// Validar el tipo de ajuste
-	if !mbr.ValidarFit() {
-		return fmt.Errorf("tipo de ajuste inválido en el MBR: %c", mbr.MbrFit)
+	report, err := CheckDiskOnDevice(device)
+	if err != nil {
+		return err
 	}
 
-	// Validar particiones
-	for i, partition := range mbr.MbrParticiones {
-		if !partition.IsEmpty() {
-			if err := partition.ValidatePartition(); err != nil {
-				return fmt.Errorf("partición %d inválida: %v", i, err)
-			}
-
-			// Verificar que la partición no excede el tamaño del disco
-			if partition.GetEndPosition() > mbr.MbrTamanio {
-				return fmt.Errorf("la partición %d excede el tamaño del disco", i)
-			}
+	// Las advertencias no impiden usar el disco
+	for _, finding := range report.Findings {
+		if finding.Severity == SeverityWarning {
+			utils.LogWarning("ValidateDisk", fmt.Sprintf("[%s] %s", finding.Code, finding.Message))
 		}
 	}
 
-	// Verificar solapamientos entre particiones
-	for i := 0; i < len(mbr.MbrParticiones); i++ {
-		if mbr.MbrParticiones[i].IsEmpty() {
-			continue
-		}
-		for j := i + 1; j < len(mbr.MbrParticiones); j++ {
-			if mbr.MbrParticiones[j].IsEmpty() {
-				continue
-			}
-			if mbr.MbrParticiones[i].Overlaps(&mbr.MbrParticiones[j]) {
-				return fmt.Errorf("las particiones %d y %d se solapan", i, j)
-			}
+	if finding := report.FirstError(); finding != nil {
+		if report.Errors > 1 {
+			return fmt.Errorf("%s (y %d errores más, use checkdisk para verlos)", finding.Message, report.Errors-1)
 		}
+		return fmt.Errorf("%s", finding.Message)
 	}
 
 	utils.LogSuccess("ValidateDisk", "El disco pasó todas las validaciones de integridad")
@@ -121,8 +96,13 @@ func BackupMBR(diskPath, backupPath string) error {
 		return fmt.Errorf("error al leer MBR para backup: %v", err)
 	}
 
-	// Serializar el MBR
-	mbrData, err := SerializeMBR(mbr)
+	// Serializar el MBR (en GPT se respalda el MBR protector junto con la cabecera y las entradas)
+	var mbrData []byte
+	if mbr.IsGPT() {
+		mbrData, err = ReadFromDisk(diskPath, 0, int(mbr.FirstUsableByte()))
+	} else {
+		mbrData, err = SerializeMBR(mbr)
+	}
 	if err != nil {
 		return fmt.Errorf("error al serializar MBR para backup: %v", err)
 	}
@@ -159,6 +139,21 @@ func RestoreMBR(diskPath, backupPath string) error {
 		return fmt.Errorf("error al restaurar MBR al disco: %v", err)
 	}
 
+	// Reescribir la tabla completa para regenerar las copias de respaldo al final del disco
+	restored, err := ReadPrimaryMBR(diskPath)
+	if err != nil {
+		return fmt.Errorf("la restauración del MBR falló la validación: %v", err)
+	}
+	if restored.IsGPT() {
+		restored, err = ReadMBR(diskPath)
+		if err != nil {
+			return fmt.Errorf("la restauración del MBR falló la validación: %v", err)
+		}
+	}
+	if err := WritePartitionTable(diskPath, restored); err != nil {
+		return fmt.Errorf("error al reescribir la tabla restaurada: %v", err)
+	}
+
 	// Validar que la restauración fue exitosa
 	err = ValidateDiskIntegrity(diskPath)
 	if err != nil {
@@ -184,12 +179,15 @@ func CleanDisk(path string) error {
 	originalSignature := mbr.MbrDiskSignature
 	originalFit := mbr.MbrFit
 
-	// Crear nuevo MBR limpio
+	// Crear nuevo MBR limpio, con el mismo tipo de tabla
 	cleanMBR := NewMBR(originalSize, originalFit)
+	if mbr.IsGPT() {
+		cleanMBR = NewGPT(originalSize, originalFit)
+	}
 	cleanMBR.MbrDiskSignature = originalSignature // Mantener la firma original
 
 	// Escribir el MBR limpio
-	err = WriteMBR(path, originalSize, string(originalFit))
+	err = WritePartitionTable(path, cleanMBR)
 	if err != nil {
 		return fmt.Errorf("error al escribir MBR limpio: %v", err)
 	}
@@ -205,7 +203,7 @@ func GetDiskUsage(path string) (float64, error) {
 		return 0, fmt.Errorf("error al leer MBR: %v", err)
 	}
 
-	usedSpace := int64(MBR_SIZE) // Espacio del MBR
+	usedSpace := mbr.GetTableSize() // Espacio de la tabla de particiones
 
 	for _, partition := range mbr.MbrParticiones {
 		if partition.IsActive() {
@@ -251,9 +249,20 @@ func FindPartition(path, name string) (*Partition, int, error) {
 	return nil, -1, fmt.Errorf("no se encontró una partición con el nombre: %s", name)
 }
 
-// GetExtendedPartition obtiene la partición extendida si existe
+// GetExtendedPartition obtiene la partición extendida si existe (ver GetExtendedPartitionFromDevice)
 func GetExtendedPartition(path string) (*Partition, int, error) {
-	mbr, err := ReadMBR(path)
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, -1, fmt.Errorf("error al leer MBR: %v", err)
+	}
+	defer device.Close()
+
+	return GetExtendedPartitionFromDevice(device)
+}
+
+// GetExtendedPartitionFromDevice obtiene la partición extendida del dispositivo de un disco si existe
+func GetExtendedPartitionFromDevice(device BlockDevice) (*Partition, int, error) {
+	mbr, err := ReadMBRFromDevice(device)
 	if err != nil {
 		return nil, -1, fmt.Errorf("error al leer MBR: %v", err)
 	}
@@ -267,7 +276,7 @@ func GetExtendedPartition(path string) (*Partition, int, error) {
 	return nil, -1, fmt.Errorf("no se encontró una partición extendida")
 }
 
-// ZeroDisk llena el disco con ceros (excepto el MBR)
+// ZeroDisk llena el disco con ceros (excepto el MBR y los EBRs)
 func ZeroDisk(path string) error {
 	utils.LogInfo("ZeroDisk", fmt.Sprintf("Limpiando contenido del disco con ceros: %s", path))
 
@@ -277,42 +286,102 @@ func ZeroDisk(path string) error {
 		return fmt.Errorf("error al leer MBR: %v", err)
 	}
 
-	// Abrir el archivo para escritura
-	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("error al abrir archivo para limpiar: %v", err)
+	// Los EBRs son parte de la tabla de particiones: se leen antes para reescribirlos
+	var chain []EBRNode
+	if extended := mbr.GetParticionExtendida(); extended != nil {
+		chain, err = ReadEBRChain(path, extended.PartStart)
+		if err != nil {
+			return fmt.Errorf("error al leer la cadena de EBRs: %v", err)
+		}
 	}
-	defer file.Close()
 
-	// Posicionarse después del MBR
-	_, err = file.Seek(int64(MBR_SIZE), 0)
-	if err != nil {
-		return fmt.Errorf("error al posicionarse en el archivo: %v", err)
+	// Limpiar desde después del MBR (en GPT, después de la tabla principal) sin tocar
+	// la copia de respaldo; se escribe con WriteToDisk para respetar los snapshots
+	if err := ZeroRange(path, mbr.FirstUsableByte(), mbr.LastUsableByte()-mbr.FirstUsableByte()); err != nil {
+		return fmt.Errorf("error al escribir ceros: %v", err)
 	}
 
-	// Crear buffer de ceros
-	bufferSize := 1024 * 1024 // 1MB buffer
-	zeroBuffer := make([]byte, bufferSize)
+	for _, node := range chain {
+		if err := WriteEBR(path, node.EBR, node.Position); err != nil {
+			return fmt.Errorf("error al reescribir EBR en posición %d: %v", node.Position, err)
+		}
+	}
 
-	// Calcular cuántos bytes limpiar
-	remainingBytes := mbr.MbrTamanio - int64(MBR_SIZE)
+	utils.LogSuccess("ZeroDisk", "Contenido del disco limpiado con ceros exitosamente")
+	return nil
+}
+
+// ZeroRange llena con ceros un rango de bytes del disco
+func ZeroRange(path string, start, size int64) error {
+	if start < 0 || size < 0 {
+		return fmt.Errorf("rango inválido: inicio %d, tamaño %d", start, size)
+	}
 
-	// Escribir ceros en chunks
-	for remainingBytes > 0 {
+	// Escribir ceros en chunks de 1MB
+	bufferSize := int64(1024 * 1024)
+	zeroBuffer := make([]byte, bufferSize)
+
+	for written := int64(0); written < size; {
 		writeSize := bufferSize
-		if remainingBytes < int64(bufferSize) {
-			writeSize = int(remainingBytes)
+		if size-written < bufferSize {
+			writeSize = size - written
+		}
+
+		if err := WriteToDisk(path, zeroBuffer[:writeSize], start+written); err != nil {
+			return fmt.Errorf("error al escribir ceros en la posición %d: %v", start+written, err)
 		}
 
-		_, err = file.Write(zeroBuffer[:writeSize])
+		written += writeSize
+	}
+
+	return nil
+}
+
+// CopyRange copia un rango de bytes dentro del mismo disco, soportando rangos solapados
+func CopyRange(path string, src, dst, size int64) error {
+	return CopyBetweenDisks(path, src, path, dst, size)
+}
+
+// CopyBetweenDisks copia un rango de bytes de un disco a otro (o al mismo disco, soportando rangos solapados)
+// Se copia en crudo: el contenido de una partición cifrada sigue cifrado en el destino
+func CopyBetweenDisks(srcPath string, src int64, dstPath string, dst, size int64) error {
+	if src < 0 || dst < 0 || size < 0 {
+		return fmt.Errorf("rango inválido: origen %d, destino %d, tamaño %d", src, dst, size)
+	}
+
+	sameDisk := SameDisk(srcPath, dstPath)
+	if (sameDisk && src == dst) || size == 0 {
+		return nil
+	}
+
+	bufferSize := int64(1024 * 1024)
+
+	// Si el destino está después del origen se copia desde el final para no pisar datos
+	backwards := sameDisk && dst > src && dst < src+size
+
+	for copied := int64(0); copied < size; {
+		chunk := bufferSize
+		if size-copied < bufferSize {
+			chunk = size - copied
+		}
+
+		offset := copied
+		if backwards {
+			offset = size - copied - chunk
+		}
+
+		data, err := ReadRawFromDisk(srcPath, src+offset, int(chunk))
 		if err != nil {
-			return fmt.Errorf("error al escribir ceros: %v", err)
+			return fmt.Errorf("error al leer en la posición %d: %v", src+offset, err)
+		}
+
+		if err := WriteRawToDisk(dstPath, data, dst+offset); err != nil {
+			return fmt.Errorf("error al escribir en la posición %d: %v", dst+offset, err)
 		}
 
-		remainingBytes -= int64(writeSize)
+		copied += chunk
 	}
 
-	utils.LogSuccess("ZeroDisk", "Contenido del disco limpiado con ceros exitosamente")
 	return nil
 }
 
@@ -342,10 +411,23 @@ func CompareMBR(path1, path2 string) ([]string, error) {
 		differences = append(differences, fmt.Sprintf("Ajuste diferente: %c vs %c", mbr1.MbrFit, mbr2.MbrFit))
 	}
 
+	if mbr1.MbrTipoTabla != mbr2.MbrTipoTabla {
+		differences = append(differences, fmt.Sprintf("Tipo de tabla diferente: %s vs %s", mbr1.GetTipoTablaString(), mbr2.GetTipoTablaString()))
+	}
+
 	// Comparar particiones
-	for i := 0; i < 4; i++ {
-		p1 := &mbr1.MbrParticiones[i]
-		p2 := &mbr2.MbrParticiones[i]
+	count := len(mbr1.MbrParticiones)
+	if len(mbr2.MbrParticiones) > count {
+		count = len(mbr2.MbrParticiones)
+	}
+	for i := 0; i < count; i++ {
+		p1, p2 := NewEmptyPartition(), NewEmptyPartition()
+		if i < len(mbr1.MbrParticiones) {
+			p1 = &mbr1.MbrParticiones[i]
+		}
+		if i < len(mbr2.MbrParticiones) {
+			p2 = &mbr2.MbrParticiones[i]
+		}
 
 		if p1.IsEmpty() && p2.IsEmpty() {
 			continue
@@ -369,6 +451,65 @@ func CompareMBR(path1, path2 string) ([]string, error) {
 		}
 	}
 
+	// Comparar las lógicas de las extendidas
+	extended1, extended2 := mbr1.GetParticionExtendida(), mbr2.GetParticionExtendida()
+	if extended1 != nil && extended2 != nil {
+		logicalDifferences, err := compareEBRChains(path1, extended1, path2, extended2)
+		if err != nil {
+			return nil, err
+		}
+		differences = append(differences, logicalDifferences...)
+	}
+
+	return differences, nil
+}
+
+// compareEBRChains compara las lógicas de dos cadenas de EBRs en el orden de la cadena
+func compareEBRChains(path1 string, extended1 *Partition, path2 string, extended2 *Partition) ([]string, error) {
+	ebrs1, err := ReadAllEBRs(path1, extended1.PartStart)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la cadena de EBRs de %s: %v", path1, err)
+	}
+
+	ebrs2, err := ReadAllEBRs(path2, extended2.PartStart)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la cadena de EBRs de %s: %v", path2, err)
+	}
+
+	var differences []string
+
+	if len(ebrs1) != len(ebrs2) {
+		differences = append(differences, fmt.Sprintf("Cantidad de lógicas diferente: %d vs %d", len(ebrs1), len(ebrs2)))
+	}
+
+	count := len(ebrs1)
+	if len(ebrs2) > count {
+		count = len(ebrs2)
+	}
+	for i := 0; i < count; i++ {
+		if i >= len(ebrs1) || i >= len(ebrs2) {
+			differences = append(differences, fmt.Sprintf("Lógica %d: una existe y la otra no", i))
+			continue
+		}
+		e1, e2 := ebrs1[i], ebrs2[i]
+
+		if e1.GetName() != e2.GetName() {
+			differences = append(differences, fmt.Sprintf("Lógica %d nombre: %s vs %s", i, e1.GetName(), e2.GetName()))
+		}
+
+		if e1.PartSize != e2.PartSize {
+			differences = append(differences, fmt.Sprintf("Lógica %d tamaño: %d vs %d", i, e1.PartSize, e2.PartSize))
+		}
+
+		if e1.PartStart != e2.PartStart {
+			differences = append(differences, fmt.Sprintf("Lógica %d inicio: %d vs %d", i, e1.PartStart, e2.PartStart))
+		}
+
+		if e1.PartFit != e2.PartFit {
+			differences = append(differences, fmt.Sprintf("Lógica %d ajuste: %c vs %c", i, e1.PartFit, e2.PartFit))
+		}
+	}
+
 	return differences, nil
 }
 
@@ -386,11 +527,12 @@ func GetDiskStatistics(path string) (map[string]interface{}, error) {
 	stats["creation_date"] = time.Unix(mbr.MbrFechaCreacion, 0).Format(time.RFC3339)
 	stats["disk_signature"] = mbr.MbrDiskSignature
 	stats["fit_type"] = string(mbr.MbrFit)
+	stats["table_type"] = mbr.GetTipoTablaString()
 
 	// Estadísticas de particiones
-	stats["total_partitions"] = 4
+	stats["total_partitions"] = len(mbr.MbrParticiones)
 	stats["active_partitions"] = mbr.CountActivePartitions()
-	stats["free_partitions"] = 4 - mbr.CountActivePartitions()
+	stats["free_partitions"] = len(mbr.MbrParticiones) - mbr.CountActivePartitions()
 
 	// Estadísticas de espacio
 	stats["free_space"] = mbr.GetFreeSpace()
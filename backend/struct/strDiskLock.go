@@ -0,0 +1,200 @@
+package estructuras
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bloqueo por disco
+// Cada comando bloquea los discos que usa mientras se ejecuta: varios comandos pueden
+// leer un disco a la vez, pero uno que lo modifica lo usa solo. Así dos peticiones a
+// /api/execute no intercalan la lectura y escritura del MBR de un mismo disco.
+//
+// Además del bloqueo en memoria se toma un flock consultivo sobre el archivo del disco
+// (compartido para leer, exclusivo para escribir), para que otro proceso del backend
+// que use el mismo disco espere en lugar de corromperlo. Un disco que aún no existe
+// (mkdisk) o un disco en memoria (mem://) solo se bloquea en memoria.
+
+// DiskLockMode indica si un disco se bloquea para leer o para escribir
+type DiskLockMode int
+
+const (
+	DiskLockRead  DiskLockMode = iota // Varios lectores a la vez
+	DiskLockWrite                     // Un solo escritor, sin lectores
+)
+
+// DISK_LOCK_TIMEOUT es el tiempo máximo de espera por el flock de otro proceso
+const DISK_LOCK_TIMEOUT = 30 * time.Second
+
+// Intervalo entre intentos de tomar el flock
+const diskLockRetryInterval = 50 * time.Millisecond
+
+// diskLockEntry es el bloqueo en memoria de un disco
+type diskLockEntry struct {
+	mutex sync.RWMutex
+	users int // Comandos que tienen o esperan el bloqueo; en 0 la entrada se elimina
+}
+
+// Bloqueos en memoria por disco (Key: ruta absoluta del disco)
+var diskLocks = struct {
+	mutex   sync.Mutex
+	entries map[string]*diskLockEntry
+}{entries: make(map[string]*diskLockEntry)}
+
+// heldDiskLock es un disco bloqueado
+type heldDiskLock struct {
+	key   string
+	mode  DiskLockMode
+	entry *diskLockEntry
+	file  *os.File // Archivo con el flock, nil si el disco no existe
+}
+
+// SameDisk indica si dos rutas, escritas de cualquier forma, son el mismo disco
+func SameDisk(a, b string) bool {
+	return diskLockKey(a) == diskLockKey(b)
+}
+
+// DiskLock son los discos bloqueados por un comando
+type DiskLock struct {
+	held []heldDiskLock
+}
+
+// diskLockKey normaliza la ruta de un disco para que dos formas de escribirla usen el mismo bloqueo
+func diskLockKey(path string) string {
+	if IsMemoryDisk(path) {
+		return path
+	}
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+	return filepath.Clean(path)
+}
+
+// LockDisks bloquea los discos de reads para lectura y los de writes para escritura
+// Un disco en ambas listas se bloquea para escritura. Los discos se bloquean en orden
+// para que dos comandos que usan los mismos discos no se esperen mutuamente.
+func LockDisks(reads, writes []string) (*DiskLock, error) {
+	modes := make(map[string]DiskLockMode)
+	for _, path := range reads {
+		if path != "" {
+			modes[diskLockKey(path)] = DiskLockRead
+		}
+	}
+	for _, path := range writes {
+		if path != "" {
+			modes[diskLockKey(path)] = DiskLockWrite
+		}
+	}
+
+	keys := make([]string, 0, len(modes))
+	for key := range modes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lock := &DiskLock{}
+	for _, key := range keys {
+		held, err := lockDisk(key, modes[key])
+		if err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+		lock.held = append(lock.held, held)
+	}
+
+	return lock, nil
+}
+
+// Unlock libera los discos en orden inverso
+func (l *DiskLock) Unlock() {
+	if l == nil {
+		return
+	}
+
+	for i := len(l.held) - 1; i >= 0; i-- {
+		unlockDisk(l.held[i])
+	}
+	l.held = nil
+}
+
+// lockDisk toma el bloqueo en memoria y luego el flock del archivo de un disco
+func lockDisk(key string, mode DiskLockMode) (heldDiskLock, error) {
+	diskLocks.mutex.Lock()
+	entry, exists := diskLocks.entries[key]
+	if !exists {
+		entry = &diskLockEntry{}
+		diskLocks.entries[key] = entry
+	}
+	entry.users++
+	diskLocks.mutex.Unlock()
+
+	if mode == DiskLockWrite {
+		entry.mutex.Lock()
+	} else {
+		entry.mutex.RLock()
+	}
+
+	held := heldDiskLock{key: key, mode: mode, entry: entry}
+
+	// Otro proceso no puede usar un disco en memoria
+	if IsMemoryDisk(key) {
+		return held, nil
+	}
+
+	file, err := os.Open(key)
+	if os.IsNotExist(err) {
+		return held, nil
+	}
+	if err != nil {
+		unlockDisk(held)
+		return heldDiskLock{}, fmt.Errorf("no se pudo abrir el disco %s para bloquearlo: %v", key, err)
+	}
+
+	// Otro proceso puede tener el disco; se reintenta hasta el límite de espera
+	deadline := time.Now().Add(DISK_LOCK_TIMEOUT)
+	for {
+		locked, err := tryLockFile(file, mode)
+		if err != nil {
+			file.Close()
+			unlockDisk(held)
+			return heldDiskLock{}, fmt.Errorf("no se pudo bloquear el disco %s: %v", key, err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			unlockDisk(held)
+			return heldDiskLock{}, fmt.Errorf("el disco %s está bloqueado por otro proceso", key)
+		}
+		time.Sleep(diskLockRetryInterval)
+	}
+
+	held.file = file
+	return held, nil
+}
+
+// unlockDisk libera el flock y el bloqueo en memoria de un disco
+func unlockDisk(held heldDiskLock) {
+	if held.file != nil {
+		unlockFile(held.file)
+		held.file.Close()
+	}
+
+	if held.mode == DiskLockWrite {
+		held.entry.mutex.Unlock()
+	} else {
+		held.entry.mutex.RUnlock()
+	}
+
+	diskLocks.mutex.Lock()
+	held.entry.users--
+	if held.entry.users == 0 {
+		delete(diskLocks.entries, held.key)
+	}
+	diskLocks.mutex.Unlock()
+}
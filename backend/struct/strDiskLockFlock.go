@@ -0,0 +1,29 @@
+//go:build !windows
+
+package estructuras
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile intenta tomar el flock del archivo sin esperar
+// Retorna false si otro proceso tiene un bloqueo incompatible
+func tryLockFile(file *os.File, mode DiskLockMode) (bool, error) {
+	how := syscall.LOCK_SH
+	if mode == DiskLockWrite {
+		how = syscall.LOCK_EX
+	}
+
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile libera el flock del archivo
+func unlockFile(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
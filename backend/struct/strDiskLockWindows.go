@@ -0,0 +1,14 @@
+//go:build windows
+
+package estructuras
+
+import "os"
+
+// tryLockFile no toma bloqueo entre procesos en Windows (flock no existe); solo
+// queda el bloqueo en memoria de LockDisks
+func tryLockFile(file *os.File, mode DiskLockMode) (bool, error) {
+	return true, nil
+}
+
+// unlockFile no hace nada en Windows
+func unlockFile(file *os.File) {}
@@ -0,0 +1,97 @@
+package estructuras
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Metadatos de los discos
+// La etiqueta, la descripción y el dueño de un disco no forman parte de su contenido:
+// se guardan junto al disco, en el archivo <disco>.meta (JSON), para que no cambien
+// su MBR ni sus sumas de verificación. Los discos en memoria no tienen metadatos.
+
+// DiskMetadata son los datos descriptivos de un disco
+type DiskMetadata struct {
+	Label       string `json:"label"`                // Nombre corto para mostrar el disco
+	Description string `json:"description"`          // Descripción libre
+	Owner       string `json:"owner"`                // Responsable del disco
+	UpdatedAt   string `json:"updated_at,omitempty"` // Fecha del último cambio (RFC3339)
+}
+
+// IsEmpty indica si el disco no tiene ningún metadato
+func (m *DiskMetadata) IsEmpty() bool {
+	return m.Label == "" && m.Description == "" && m.Owner == ""
+}
+
+// diskMetadataPath retorna la ruta del archivo de metadatos de un disco
+func diskMetadataPath(path string) string {
+	return path + ".meta"
+}
+
+// LoadDiskMetadata lee los metadatos del disco (vacíos si no tiene)
+func LoadDiskMetadata(path string) (*DiskMetadata, error) {
+	metadata := &DiskMetadata{}
+	if IsMemoryDisk(path) {
+		return metadata, nil
+	}
+
+	data, err := os.ReadFile(diskMetadataPath(path))
+	if os.IsNotExist(err) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al leer los metadatos del disco: %v", err)
+	}
+
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("metadatos del disco dañados: %v", err)
+	}
+
+	return metadata, nil
+}
+
+// SaveDiskMetadata guarda los metadatos del disco (o elimina el archivo si quedan vacíos)
+func SaveDiskMetadata(path string, metadata *DiskMetadata) error {
+	if IsMemoryDisk(path) {
+		return fmt.Errorf("los discos en memoria no guardan metadatos")
+	}
+
+	metadata.Label = strings.TrimSpace(metadata.Label)
+	metadata.Description = strings.TrimSpace(metadata.Description)
+	metadata.Owner = strings.TrimSpace(metadata.Owner)
+	if metadata.IsEmpty() {
+		metadata.UpdatedAt = ""
+		return RemoveDiskMetadata(path)
+	}
+	metadata.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error al serializar los metadatos del disco: %v", err)
+	}
+
+	// Escribir en un archivo temporal y renombrar para no dejar un archivo a medias
+	metadataPath := diskMetadataPath(path)
+	if err := os.WriteFile(metadataPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("error al escribir los metadatos del disco: %v", err)
+	}
+	if err := os.Rename(metadataPath+".tmp", metadataPath); err != nil {
+		return fmt.Errorf("error al escribir los metadatos del disco: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveDiskMetadata elimina los metadatos del disco
+func RemoveDiskMetadata(path string) error {
+	if IsMemoryDisk(path) {
+		return nil
+	}
+	if err := os.Remove(diskMetadataPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error al eliminar los metadatos del disco: %v", err)
+	}
+	return nil
+}
@@ -219,10 +219,21 @@ func WriteEBR(path string, ebr *EBR, position int64) error {
 	return nil
 }
 
-// ReadEBR lee un EBR desde el disco en la posición especificada
+// ReadEBR lee un EBR desde el disco en la posición especificada (ver ReadEBRFromDevice)
 func ReadEBR(path string, position int64) (*EBR, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer EBR: %v", err)
+	}
+	defer device.Close()
+
+	return ReadEBRFromDevice(device, position)
+}
+
+// ReadEBRFromDevice lee un EBR desde el dispositivo de un disco en la posición especificada
+func ReadEBRFromDevice(device BlockDevice, position int64) (*EBR, error) {
 	// Leer los datos del EBR
-	data, err := ReadFromDisk(path, position, EBR_SIZE)
+	data, err := ReadFromDevice(diskView(device), position, EBR_SIZE)
 	if err != nil {
 		return nil, fmt.Errorf("error al leer EBR: %v", err)
 	}
@@ -236,53 +247,123 @@ func ReadEBR(path string, position int64) (*EBR, error) {
 	return ebr, nil
 }
 
-// ReadAllEBRs lee todos los EBRs en una cadena desde una posición inicial
+// ReadAllEBRs lee todos los EBRs no vacíos de una cadena (ver ReadAllEBRsFromDevice)
 func ReadAllEBRs(path string, startPosition int64) ([]*EBR, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	return ReadAllEBRsFromDevice(device, startPosition)
+}
+
+// ReadAllEBRsFromDevice lee todos los EBRs no vacíos de una cadena desde una posición inicial
+func ReadAllEBRsFromDevice(device BlockDevice, startPosition int64) ([]*EBR, error) {
+	// ReadEBRChainFromDevice detecta los ciclos, por lo que no se necesita un límite de EBRs
+	chain, err := ReadEBRChainFromDevice(device, startPosition)
+	if err != nil {
+		return nil, err
+	}
+
 	var ebrs []*EBR
+	for _, node := range chain {
+		if !node.EBR.IsEmpty() {
+			ebrs = append(ebrs, node.EBR)
+		}
+	}
+
+	return ebrs, nil
+}
+
+// EBRNode asocia un EBR con la posición del disco en la que está escrito
+type EBRNode struct {
+	Position int64 // Byte del disco donde inicia el EBR
+	EBR      *EBR  // EBR leído en esa posición
+}
+
+// ReadEBRChain lee la cadena completa de EBRs (ver ReadEBRChainFromDevice)
+func ReadEBRChain(path string, startPosition int64) ([]EBRNode, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer EBR en posición %d: %v", startPosition, err)
+	}
+	defer device.Close()
+
+	return ReadEBRChainFromDevice(device, startPosition)
+}
+
+// ReadEBRChainFromDevice lee la cadena completa de EBRs (incluyendo los vacíos) junto con su posición
+func ReadEBRChainFromDevice(device BlockDevice, startPosition int64) ([]EBRNode, error) {
+	var nodes []EBRNode
+	visited := make(map[int64]bool)
 	currentPosition := startPosition
 
 	for currentPosition != -1 {
-		// Leer el EBR actual
-		ebr, err := ReadEBR(path, currentPosition)
-		if err != nil {
-			return nil, fmt.Errorf("error al leer EBR en posición %d: %v", currentPosition, err)
+		// Prevenir bucles infinitos
+		if visited[currentPosition] {
+			return nil, fmt.Errorf("ciclo detectado en la cadena de EBRs en la posición %d", currentPosition)
 		}
+		visited[currentPosition] = true
 
-		// Agregar el EBR a la lista si no está vacío
-		if !ebr.IsEmpty() {
-			ebrs = append(ebrs, ebr)
+		ebr, err := ReadEBRFromDevice(device, currentPosition)
+		if err != nil {
+			return nil, fmt.Errorf("error al leer EBR en posición %d: %v", currentPosition, err)
 		}
 
-		// Mover a la siguiente posición
+		nodes = append(nodes, EBRNode{Position: currentPosition, EBR: ebr})
 		currentPosition = ebr.PartNext
+	}
 
-		// Prevenir bucles infinitos
-		if len(ebrs) > 100 {
-			return nil, fmt.Errorf("demasiados EBRs en la cadena, posible corrupción")
+	return nodes, nil
+}
+
+// ClearEBRChainMounts desmonta en el disco todos los EBRs de la cadena marcados como montados
+func ClearEBRChainMounts(path string, startPosition int64) (int, error) {
+	chain, err := ReadEBRChain(path, startPosition)
+	if err != nil {
+		return 0, err
+	}
+
+	cleared := 0
+	for _, node := range chain {
+		if !node.EBR.IsMounted() {
+			continue
+		}
+
+		node.EBR.Unmount()
+		if err := WriteEBR(path, node.EBR, node.Position); err != nil {
+			return cleared, fmt.Errorf("error al escribir EBR en posición %d: %v", node.Position, err)
 		}
+		cleared++
 	}
 
-	return ebrs, nil
+	return cleared, nil
 }
 
-// FindEBRByName busca un EBR por nombre en una cadena de EBRs
+// FindEBRByName busca un EBR por nombre en una cadena de EBRs (ver FindEBRByNameFromDevice)
 func FindEBRByName(path string, startPosition int64, name string) (*EBR, int64, error) {
-	currentPosition := startPosition
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer device.Close()
 
-	for currentPosition != -1 {
-		// Leer el EBR actual
-		ebr, err := ReadEBR(path, currentPosition)
-		if err != nil {
-			return nil, -1, fmt.Errorf("error al leer EBR en posición %d: %v", currentPosition, err)
-		}
+	return FindEBRByNameFromDevice(device, startPosition, name)
+}
+
+// FindEBRByNameFromDevice busca un EBR por nombre en la cadena de EBRs del dispositivo de un disco
+func FindEBRByNameFromDevice(device BlockDevice, startPosition int64, name string) (*EBR, int64, error) {
+	chain, err := ReadEBRChainFromDevice(device, startPosition)
+	if err != nil {
+		return nil, -1, err
+	}
 
+	for _, node := range chain {
 		// Verificar si es el EBR que buscamos
-		if !ebr.IsEmpty() && ebr.GetName() == name {
-			return ebr, currentPosition, nil
+		if !node.EBR.IsEmpty() && node.EBR.GetName() == name {
+			return node.EBR, node.Position, nil
 		}
-
-		// Mover a la siguiente posición
-		currentPosition = ebr.PartNext
 	}
 
 	return nil, -1, fmt.Errorf("no se encontró una partición lógica con el nombre: %s", name)
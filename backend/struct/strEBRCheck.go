@@ -0,0 +1,265 @@
+package estructuras
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Verificación de la cadena de EBRs de una partición extendida
+// Recorre la cadena desde el primer EBR y detecta ciclos, enlaces o datos fuera de la
+// extendida, lógicas traslapadas, enlaces desordenados y EBRs huérfanos (EBRs válidos
+// en el espacio libre de la extendida que no son alcanzables desde la cadena).
+
+// Códigos de los problemas encontrados en la cadena de EBRs
+const (
+	EBRIssueCycle      = "cycle"        // Un EBR apunta a otro que ya está en la cadena
+	EBRIssueOutOfRange = "out_of_range" // Un enlace o los datos de una lógica salen de la extendida
+	EBRIssueInvalid    = "invalid"      // El EBR tiene valores inválidos (ajuste, tamaño o nombre)
+	EBRIssueOverlap    = "overlap"      // Dos lógicas de la cadena se superponen
+	EBRIssueUnsorted   = "unsorted"     // Un EBR apunta a una posición anterior a la suya
+	EBRIssueOrphan     = "orphan"       // EBR válido que no es alcanzable desde la cadena
+)
+
+// Tamaño de los bloques leídos al buscar EBRs huérfanos
+const ebrScanChunkSize = 1024 * 1024
+
+// EBRIssue describe un problema encontrado en la cadena de EBRs
+type EBRIssue struct {
+	Code     string `json:"code"`     // Código del problema (EBRIssue*)
+	Position int64  `json:"position"` // Posición del EBR afectado
+	Message  string `json:"message"`  // Descripción del problema
+}
+
+// EBRCheckReport es el resultado de verificar la cadena de EBRs
+type EBRCheckReport struct {
+	ExtendedStart int64      `json:"extended_start"` // Inicio de la partición extendida
+	ExtendedEnd   int64      `json:"extended_end"`   // Fin (exclusivo) de la partición extendida
+	Chain         []EBRNode  `json:"-"`              // EBRs alcanzables desde el primero, en orden de la cadena
+	Logicals      []EBRNode  `json:"-"`              // Lógicas válidas de la cadena
+	Orphans       []EBRNode  `json:"-"`              // Lógicas válidas fuera de la cadena
+	Issues        []EBRIssue `json:"issues"`         // Problemas encontrados
+}
+
+// IsConsistent indica si la cadena no tiene problemas
+func (r *EBRCheckReport) IsConsistent() bool {
+	return len(r.Issues) == 0
+}
+
+// addIssue registra un problema en el reporte
+func (r *EBRCheckReport) addIssue(code string, position int64, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, EBRIssue{
+		Code:     code,
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckEBRChain verifica la cadena de EBRs de la partición extendida (ver CheckEBRChainOnDevice)
+func CheckEBRChain(path string, extended *Partition) (*EBRCheckReport, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	return CheckEBRChainOnDevice(device, extended)
+}
+
+// CheckEBRChainOnDevice verifica la cadena de EBRs de la partición extendida en el dispositivo de un disco
+func CheckEBRChainOnDevice(device BlockDevice, extended *Partition) (*EBRCheckReport, error) {
+	report := &EBRCheckReport{
+		ExtendedStart: extended.PartStart,
+		ExtendedEnd:   extended.GetEndPosition(),
+		Issues:        []EBRIssue{},
+	}
+
+	if err := report.walkChain(device); err != nil {
+		return nil, err
+	}
+	report.checkOverlaps()
+	if err := report.findOrphans(device); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// walkChain recorre la cadena desde el primer EBR hasta el final, un ciclo o un enlace inválido
+func (r *EBRCheckReport) walkChain(device BlockDevice) error {
+	visited := make(map[int64]bool)
+	previous := int64(-1)
+	position := r.ExtendedStart
+
+	for position != -1 {
+		if visited[position] {
+			r.addIssue(EBRIssueCycle, previous, "el EBR en %d apunta a %d, que ya está en la cadena", previous, position)
+			return nil
+		}
+
+		if position < r.ExtendedStart || position+int64(EBR_SIZE) > r.ExtendedEnd {
+			r.addIssue(EBRIssueOutOfRange, previous, "el EBR en %d apunta a %d, fuera de la partición extendida [%d, %d)",
+				previous, position, r.ExtendedStart, r.ExtendedEnd)
+			return nil
+		}
+
+		if previous != -1 && position < previous {
+			r.addIssue(EBRIssueUnsorted, previous, "el EBR en %d apunta a %d, una posición anterior", previous, position)
+		}
+		visited[position] = true
+
+		ebr, err := ReadEBRFromDevice(device, position)
+		if err != nil {
+			return fmt.Errorf("error al leer EBR en posición %d: %v", position, err)
+		}
+
+		node := EBRNode{Position: position, EBR: ebr}
+		r.Chain = append(r.Chain, node)
+
+		if !ebr.IsEmpty() {
+			if err := ebr.ValidateEBR(); err != nil {
+				r.addIssue(EBRIssueInvalid, position, "EBR '%s' inválido: %v", ebr.GetName(), err)
+			} else if ebr.PartStart < position+int64(EBR_SIZE) || ebr.GetEndPosition() > r.ExtendedEnd {
+				r.addIssue(EBRIssueOutOfRange, position, "los datos de '%s' [%d, %d) no están entre su EBR y el fin de la extendida",
+					ebr.GetName(), ebr.PartStart, ebr.GetEndPosition())
+			} else {
+				r.Logicals = append(r.Logicals, node)
+			}
+		}
+
+		previous = position
+		position = ebr.PartNext
+	}
+
+	return nil
+}
+
+// checkOverlaps detecta lógicas de la cadena que se superponen (EBR incluido)
+func (r *EBRCheckReport) checkOverlaps() {
+	sorted := append([]EBRNode(nil), r.Logicals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Position < sorted[j].Position
+	})
+
+	for i := 0; i < len(sorted); i++ {
+		for j := i + 1; j < len(sorted) && sorted[j].Position < sorted[i].EBR.GetEndPosition(); j++ {
+			r.addIssue(EBRIssueOverlap, sorted[j].Position, "la lógica '%s' se superpone con '%s' (termina en %d)",
+				sorted[j].EBR.GetName(), sorted[i].EBR.GetName(), sorted[i].EBR.GetEndPosition())
+		}
+	}
+}
+
+// findOrphans busca EBRs válidos en el espacio de la extendida que no ocupa la cadena
+// Un EBR huérfano debe tener sus datos justo después de él (como los escribe fdisk),
+// dentro de la extendida, sin superponerse con las lógicas de la cadena y con un
+// nombre que no esté en la cadena (las copias viejas de un EBR movido se ignoran).
+func (r *EBRCheckReport) findOrphans(device BlockDevice) error {
+	var used []FreeSpace
+	names := make(map[string]bool)
+	for _, node := range r.Chain {
+		end := node.Position + int64(EBR_SIZE)
+		if !node.EBR.IsEmpty() {
+			names[node.EBR.GetName()] = true
+		}
+		used = append(used, FreeSpace{Start: node.Position, Size: end - node.Position})
+	}
+	for _, node := range r.Logicals {
+		used = append(used, FreeSpace{Start: node.Position, Size: node.EBR.GetEndPosition() - node.Position})
+	}
+
+	for _, gap := range r.freeGaps(used) {
+		position := gap.Start
+		for position+int64(EBR_SIZE) <= gap.GetEndPosition() {
+			chunkSize := gap.GetEndPosition() - position
+			if chunkSize > ebrScanChunkSize {
+				chunkSize = ebrScanChunkSize
+			}
+
+			data, err := ReadFromDevice(diskView(device), position, int(chunkSize))
+			if err != nil {
+				return fmt.Errorf("error al leer la partición extendida en %d: %v", position, err)
+			}
+
+			next := position + chunkSize - int64(EBR_SIZE) + 1
+			for offset := 0; offset+EBR_SIZE <= len(data); offset++ {
+				candidate := position + int64(offset)
+
+				// PartStart (después de PartMount y PartFit) debe apuntar justo después del EBR
+				if int64(binary.LittleEndian.Uint64(data[offset+2:])) != candidate+int64(EBR_SIZE) {
+					continue
+				}
+
+				ebr, err := DeserializeEBR(data[offset:])
+				if err != nil || !r.isOrphanCandidate(ebr, names, used) {
+					continue
+				}
+
+				r.Orphans = append(r.Orphans, EBRNode{Position: candidate, EBR: ebr})
+				r.addIssue(EBRIssueOrphan, candidate, "EBR huérfano '%s' (datos [%d, %d)) no es alcanzable desde la cadena",
+					ebr.GetName(), ebr.PartStart, ebr.GetEndPosition())
+
+				names[ebr.GetName()] = true
+				used = append(used, FreeSpace{Start: candidate, Size: ebr.GetEndPosition() - candidate})
+				next = ebr.GetEndPosition()
+				break
+			}
+
+			if next <= position {
+				next = position + 1
+			}
+			position = next
+		}
+	}
+
+	return nil
+}
+
+// isOrphanCandidate indica si un EBR encontrado fuera de la cadena parece una lógica válida
+func (r *EBRCheckReport) isOrphanCandidate(ebr *EBR, names map[string]bool, used []FreeSpace) bool {
+	if ebr.IsEmpty() || ebr.ValidateEBR() != nil || names[ebr.GetName()] {
+		return false
+	}
+
+	for _, b := range []byte(ebr.GetName()) {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+
+	if ebr.GetEndPosition() > r.ExtendedEnd {
+		return false
+	}
+
+	start := ebr.PartStart - int64(EBR_SIZE)
+	for _, space := range used {
+		if start < space.GetEndPosition() && space.Start < ebr.GetEndPosition() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// freeGaps calcula los espacios de la extendida que no están en la lista de usados
+func (r *EBRCheckReport) freeGaps(used []FreeSpace) []FreeSpace {
+	sorted := append([]FreeSpace(nil), used...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var gaps []FreeSpace
+	current := r.ExtendedStart
+	for _, space := range sorted {
+		if space.Start > current {
+			gaps = append(gaps, FreeSpace{Start: current, Size: space.Start - current})
+		}
+		if space.GetEndPosition() > current {
+			current = space.GetEndPosition()
+		}
+	}
+	if current < r.ExtendedEnd {
+		gaps = append(gaps, FreeSpace{Start: current, Size: r.ExtendedEnd - current})
+	}
+
+	return gaps
+}
@@ -0,0 +1,408 @@
+package estructuras
+
+import (
+	utils "backend/Utils"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Tabla de particiones tipo GPT (GUID Partition Table)
+// Es una alternativa al MBR de 4 particiones: permite 128 particiones primarias, por lo
+// que no se necesitan particiones extendidas ni lógicas. El disco conserva un MBR
+// protector en el byte 0 (con el tamaño, la firma y el ajuste del disco) y una copia
+// de respaldo de la cabecera y de las entradas al final del disco.
+/*
+| Región                   | Posición                          | Tamaño            |
+|--------------------------|-----------------------------------|-------------------|
+| MBR protector            | Byte 0 (LBA 0)                    | 512 bytes         |
+| Cabecera GPT principal   | Byte 512 (LBA 1)                  | 512 bytes         |
+| Entradas principales     | Byte 1024 (LBA 2)                 | 128 * 128 bytes   |
+| Espacio para particiones | Byte 17408 (LBA 34)               | Resto del disco   |
+| Copia de respaldo del MBR| Tamaño - 17408 (último LBA - 33)  | 512 bytes         |
+| Entradas de respaldo     | Tamaño - 16896 (último LBA - 32)  | 128 * 128 bytes   |
+| Cabecera de respaldo     | Tamaño - 512 (último LBA)         | 512 bytes         |
+*/
+
+// Constantes de la tabla GPT
+const (
+	GPT_SECTOR_SIZE    int64 = 512                                                                    // Tamaño de un sector (LBA)
+	GPT_ENTRY_COUNT          = 128                                                                    // Cantidad de entradas de partición
+	GPT_ENTRY_SIZE           = 128                                                                    // Tamaño de cada entrada en bytes
+	GPT_HEADER_OFFSET  int64 = GPT_SECTOR_SIZE                                                        // Posición de la cabecera principal
+	GPT_ENTRIES_OFFSET int64 = 2 * GPT_SECTOR_SIZE                                                    // Posición de las entradas principales
+	GPT_ENTRIES_BYTES  int64 = GPT_ENTRY_COUNT * GPT_ENTRY_SIZE                                       // Tamaño del arreglo de entradas
+	GPT_FIRST_USABLE   int64 = GPT_ENTRIES_OFFSET + GPT_ENTRIES_BYTES                                 // Primer byte disponible para particiones
+	GPT_BACKUP_SIZE    int64 = GPT_ENTRIES_BYTES + GPT_SECTOR_SIZE                                    // Bytes reservados al final del disco
+	GPT_MIN_DISK_SIZE  int64 = GPT_FIRST_USABLE + GPT_BACKUP_SIZE + MBR_BACKUP_SIZE + GPT_SECTOR_SIZE // Ambas copias de la tabla, la copia del MBR y un sector libre
+	gptHeaderSize            = 92                                                                     // Tamaño de la cabecera sin relleno
+	gptRevision              = 0x00010000                                                             // Revisión 1.0
+)
+
+// Firma de la cabecera GPT
+var gptSignature = [8]byte{'E', 'F', 'I', ' ', 'P', 'A', 'R', 'T'}
+
+// GUID del tipo de partición de datos (0FC63DAF-8483-4772-8E79-3D69D8477DE4)
+var gptTipoDatos = [16]byte{0xAF, 0x3D, 0xC6, 0x0F, 0x83, 0x84, 0x72, 0x47, 0x8E, 0x79, 0x3D, 0x69, 0xD8, 0x47, 0x7D, 0xE4}
+
+// GPTHeader representa la cabecera de la tabla GPT
+/*
+| Nombre              | Tipo     | Descripción                                          |
+|---------------------|----------|------------------------------------------------------|
+| signature           | char[8]  | "EFI PART"                                           |
+| revision            | uint32   | Versión de la tabla                                  |
+| header_size         | uint32   | Tamaño de la cabecera (92 bytes)                     |
+| header_crc32        | uint32   | CRC32 de la cabecera (calculado con este campo en 0) |
+| current_lba         | int64    | Sector donde está esta cabecera                      |
+| backup_lba          | int64    | Sector de la otra copia de la cabecera               |
+| first_usable_lba    | int64    | Primer sector disponible para particiones            |
+| last_usable_lba     | int64    | Último sector disponible para particiones            |
+| disk_guid           | byte[16] | Identificador único del disco                        |
+| entries_lba         | int64    | Sector donde inicia el arreglo de entradas           |
+| entries_count       | uint32   | Cantidad de entradas (128)                           |
+| entry_size          | uint32   | Tamaño de cada entrada (128 bytes)                   |
+| entries_crc32       | uint32   | CRC32 del arreglo de entradas                        |
+*/
+type GPTHeader struct {
+	Signature         [8]byte  `binary:"little"`
+	Revision          uint32   `binary:"little"`
+	HeaderSize        uint32   `binary:"little"`
+	HeaderCRC32       uint32   `binary:"little"`
+	Reserved          uint32   `binary:"little"`
+	CurrentLBA        int64    `binary:"little"`
+	BackupLBA         int64    `binary:"little"`
+	FirstUsableLBA    int64    `binary:"little"`
+	LastUsableLBA     int64    `binary:"little"`
+	DiskGUID          [16]byte `binary:"little"`
+	PartitionEntryLBA int64    `binary:"little"`
+	NumberOfEntries   uint32   `binary:"little"`
+	SizeOfEntry       uint32   `binary:"little"`
+	EntriesCRC32      uint32   `binary:"little"`
+}
+
+// GPTEntry representa una entrada del arreglo de particiones GPT
+// Además de los campos estándar guarda el ajuste y el estado de montaje de la partición
+type GPTEntry struct {
+	PartTypeGUID    [16]byte `binary:"little"` // Tipo de partición (todo en cero = entrada libre)
+	PartGUID        [16]byte `binary:"little"` // Identificador único de la partición
+	PartStart       int64    `binary:"little"` // Byte donde inicia la partición
+	PartSize        int64    `binary:"little"` // Tamaño total de la partición en bytes
+	PartAttributes  uint64   `binary:"little"` // Atributos (reservado)
+	PartName        [16]byte `binary:"little"` // Nombre de la partición
+	PartStatus      byte     `binary:"little"` // Estado de la partición (activa o montada)
+	PartFit         byte     `binary:"little"` // Tipo de ajuste: B (Best), F (First), W (Worst)
+	PartCorrelativo int64    `binary:"little"` // Correlativo de la partición (-1 hasta que sea montada)
+	PartID          [4]byte  `binary:"little"` // ID de la partición generada al montar
+	Reserved        [42]byte `binary:"little"` // Relleno hasta 128 bytes
+}
+
+// gptInfo guarda los identificadores de una tabla GPT leída del disco
+type gptInfo struct {
+	DiskGUID  [16]byte
+	PartGUIDs [][16]byte
+}
+
+// newGPTInfo crea identificadores nuevos para una tabla GPT
+func newGPTInfo(entries int) *gptInfo {
+	return &gptInfo{
+		DiskGUID:  newGUID(),
+		PartGUIDs: make([][16]byte, entries),
+	}
+}
+
+// clone crea una copia independiente de los identificadores
+func (g *gptInfo) clone() *gptInfo {
+	clone := &gptInfo{DiskGUID: g.DiskGUID, PartGUIDs: make([][16]byte, len(g.PartGUIDs))}
+	copy(clone.PartGUIDs, g.PartGUIDs)
+	return clone
+}
+
+// newGUID genera un GUID aleatorio (versión 4)
+func newGUID() [16]byte {
+	var guid [16]byte
+	if _, err := rand.Read(guid[:]); err != nil {
+		binary.LittleEndian.PutUint64(guid[:8], uint64(generateRandomSignature()))
+	}
+	guid[7] = (guid[7] & 0x0F) | 0x40
+	guid[8] = (guid[8] & 0x3F) | 0x80
+	return guid
+}
+
+// NewGPT crea un nuevo disco con tabla GPT vacía
+func NewGPT(tamanio int64, fit byte) *MBR {
+	mbr := NewMBR(tamanio, fit)
+	mbr.MbrTipoTabla = TablaGPT
+	mbr.MbrParticiones = make([]Partition, GPT_ENTRY_COUNT)
+	for i := range mbr.MbrParticiones {
+		mbr.MbrParticiones[i] = *NewEmptyPartition()
+	}
+	mbr.gpt = newGPTInfo(GPT_ENTRY_COUNT)
+	return mbr
+}
+
+// WriteGPT escribe una tabla GPT vacía en el disco especificado
+func WriteGPT(path string, sizeInBytes int64, fit string) error {
+	// Validar y convertir el fit
+	fitByte := ValidateFit(fit)
+
+	if fitByte == 0 {
+		utils.LogError("WriteGPT", "Ajuste no válido, use B, F o W")
+		return fmt.Errorf("tipo de ajuste no válido: %s", fit)
+	}
+
+	// El disco debe tener espacio para ambas copias de la tabla y al menos un sector libre
+	if sizeInBytes < GPT_MIN_DISK_SIZE {
+		utils.LogError("WriteGPT", "El disco es demasiado pequeño para una tabla GPT")
+		return fmt.Errorf("el disco es demasiado pequeño para una tabla GPT (mínimo %d bytes)", GPT_MIN_DISK_SIZE)
+	}
+
+	// Crear y escribir la tabla
+	if err := writeGPT(path, NewGPT(sizeInBytes, fitByte)); err != nil {
+		utils.LogError("WriteGPT", fmt.Sprintf("Error al escribir la tabla GPT en el disco: %v", err))
+		return err
+	}
+
+	utils.LogSuccess("WriteGPT", fmt.Sprintf("Tabla GPT escrita exitosamente en %s", path))
+	return nil
+}
+
+// newProtectivePartition crea la partición del MBR protector que cubre la tabla GPT y el resto del disco
+func newProtectivePartition(diskSize int64) Partition {
+	partition := NewPartition(PartitionTypeProtectiva, PartitionFitFirst, GPT_HEADER_OFFSET, diskSize-GPT_HEADER_OFFSET, "GPT")
+	partition.PartStatus = StatusActiva
+	return *partition
+}
+
+// readGPT lee la tabla GPT del disco, usando la copia de respaldo si la principal está dañada
+func readGPT(device BlockDevice, protective *MBR) (*MBR, error) {
+	header, entries, err := readGPTTable(device, GPT_HEADER_OFFSET)
+	if err != nil {
+		utils.LogWarning("ReadMBR", fmt.Sprintf("La cabecera GPT principal no es válida (%v), se usará la copia de respaldo", err))
+
+		var backupErr error
+		header, entries, backupErr = readGPTTable(device, protective.MbrTamanio-GPT_SECTOR_SIZE)
+		if backupErr != nil {
+			utils.LogError("ReadMBR", fmt.Sprintf("La cabecera GPT de respaldo tampoco es válida: %v", backupErr))
+			return nil, fmt.Errorf("tabla GPT dañada: principal (%v), respaldo (%v)", err, backupErr)
+		}
+	}
+
+	mbr := &MBR{
+		MbrTamanio:       protective.MbrTamanio,
+		MbrFechaCreacion: protective.MbrFechaCreacion,
+		MbrDiskSignature: protective.MbrDiskSignature,
+		MbrFit:           protective.MbrFit,
+		MbrParticiones:   make([]Partition, len(entries)),
+		MbrTipoTabla:     TablaGPT,
+		gpt: &gptInfo{
+			DiskGUID:  header.DiskGUID,
+			PartGUIDs: make([][16]byte, len(entries)),
+		},
+	}
+
+	for i := range entries {
+		mbr.MbrParticiones[i] = entries[i].toPartition()
+		mbr.gpt.PartGUIDs[i] = entries[i].PartGUID
+	}
+
+	return mbr, nil
+}
+
+// CheckGPTTables valida (firma y CRC32) las dos copias de la tabla GPT de un disco de diskSize bytes
+// Retorna el error de cada copia, o nil si es válida
+func CheckGPTTables(path string, diskSize int64) (primaryErr, backupErr error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return err, err
+	}
+	defer device.Close()
+
+	_, _, primaryErr = readGPTTable(device, GPT_HEADER_OFFSET)
+	_, _, backupErr = readGPTTable(device, diskSize-GPT_SECTOR_SIZE)
+	return primaryErr, backupErr
+}
+
+// readGPTTable lee y valida (firma y CRC32) una cabecera GPT y su arreglo de entradas
+func readGPTTable(device BlockDevice, headerOffset int64) (*GPTHeader, []GPTEntry, error) {
+	data, err := ReadFromDevice(diskView(device), headerOffset, gptHeaderSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error al leer la cabecera: %v", err)
+	}
+
+	header := &GPTHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+		return nil, nil, fmt.Errorf("error al deserializar la cabecera: %v", err)
+	}
+
+	if header.Signature != gptSignature {
+		return nil, nil, fmt.Errorf("firma inválida en el byte %d", headerOffset)
+	}
+
+	if crc := header.computeCRC32(); crc != header.HeaderCRC32 {
+		return nil, nil, fmt.Errorf("CRC32 de la cabecera inválido (esperado %08x, calculado %08x)", header.HeaderCRC32, crc)
+	}
+
+	if header.NumberOfEntries != GPT_ENTRY_COUNT || header.SizeOfEntry != GPT_ENTRY_SIZE {
+		return nil, nil, fmt.Errorf("formato de entradas no soportado: %d entradas de %d bytes",
+			header.NumberOfEntries, header.SizeOfEntry)
+	}
+
+	entriesData, err := ReadFromDevice(diskView(device), header.PartitionEntryLBA*GPT_SECTOR_SIZE, int(GPT_ENTRIES_BYTES))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error al leer las entradas: %v", err)
+	}
+
+	if crc := crc32.ChecksumIEEE(entriesData); crc != header.EntriesCRC32 {
+		return nil, nil, fmt.Errorf("CRC32 de las entradas inválido (esperado %08x, calculado %08x)", header.EntriesCRC32, crc)
+	}
+
+	entries := make([]GPTEntry, GPT_ENTRY_COUNT)
+	if err := binary.Read(bytes.NewReader(entriesData), binary.LittleEndian, entries); err != nil {
+		return nil, nil, fmt.Errorf("error al deserializar las entradas: %v", err)
+	}
+
+	return header, entries, nil
+}
+
+// writeGPT escribe el MBR protector, la tabla GPT principal y su copia de respaldo
+func writeGPT(path string, mbr *MBR) error {
+	if len(mbr.MbrParticiones) > GPT_ENTRY_COUNT {
+		return fmt.Errorf("la tabla GPT admite como máximo %d particiones", GPT_ENTRY_COUNT)
+	}
+
+	if mbr.MbrTamanio%GPT_SECTOR_SIZE != 0 {
+		return fmt.Errorf("el tamaño de un disco GPT debe ser múltiplo de %d bytes", GPT_SECTOR_SIZE)
+	}
+
+	if mbr.gpt == nil {
+		mbr.gpt = newGPTInfo(len(mbr.MbrParticiones))
+	}
+	for len(mbr.gpt.PartGUIDs) < len(mbr.MbrParticiones) {
+		mbr.gpt.PartGUIDs = append(mbr.gpt.PartGUIDs, [16]byte{})
+	}
+
+	// Construir el arreglo de entradas
+	entries := make([]GPTEntry, GPT_ENTRY_COUNT)
+	for i := range mbr.MbrParticiones {
+		partition := &mbr.MbrParticiones[i]
+		if !partition.IsActive() {
+			mbr.gpt.PartGUIDs[i] = [16]byte{}
+			continue
+		}
+
+		if mbr.gpt.PartGUIDs[i] == ([16]byte{}) {
+			mbr.gpt.PartGUIDs[i] = newGUID()
+		}
+		entries[i] = newGPTEntry(partition, mbr.gpt.PartGUIDs[i])
+	}
+
+	entriesBuf := new(bytes.Buffer)
+	if err := binary.Write(entriesBuf, binary.LittleEndian, entries); err != nil {
+		return fmt.Errorf("error al serializar las entradas GPT: %v", err)
+	}
+	entriesData := entriesBuf.Bytes()
+	entriesCRC := crc32.ChecksumIEEE(entriesData)
+
+	// Cabeceras principal y de respaldo
+	lastLBA := mbr.MbrTamanio/GPT_SECTOR_SIZE - 1
+	primary := GPTHeader{
+		Signature:         gptSignature,
+		Revision:          gptRevision,
+		HeaderSize:        gptHeaderSize,
+		CurrentLBA:        1,
+		BackupLBA:         lastLBA,
+		FirstUsableLBA:    GPT_FIRST_USABLE / GPT_SECTOR_SIZE,
+		LastUsableLBA:     mbr.LastUsableByte()/GPT_SECTOR_SIZE - 1,
+		DiskGUID:          mbr.gpt.DiskGUID,
+		PartitionEntryLBA: GPT_ENTRIES_OFFSET / GPT_SECTOR_SIZE,
+		NumberOfEntries:   GPT_ENTRY_COUNT,
+		SizeOfEntry:       GPT_ENTRY_SIZE,
+		EntriesCRC32:      entriesCRC,
+	}
+	primary.HeaderCRC32 = primary.computeCRC32()
+
+	backup := primary
+	backup.CurrentLBA = lastLBA
+	backup.BackupLBA = 1
+	backup.PartitionEntryLBA = (mbr.MbrTamanio - GPT_BACKUP_SIZE) / GPT_SECTOR_SIZE
+	backup.HeaderCRC32 = backup.computeCRC32()
+
+	// Escribir primero la tabla principal, luego la copia de respaldo y por último el MBR protector (y su copia)
+	if err := WriteToDisk(path, entriesData, GPT_ENTRIES_OFFSET); err != nil {
+		return fmt.Errorf("error al escribir las entradas GPT: %v", err)
+	}
+	if err := writeGPTHeader(path, &primary); err != nil {
+		return err
+	}
+	if err := WriteToDisk(path, entriesData, backup.PartitionEntryLBA*GPT_SECTOR_SIZE); err != nil {
+		return fmt.Errorf("error al escribir las entradas GPT de respaldo: %v", err)
+	}
+	if err := writeGPTHeader(path, &backup); err != nil {
+		return err
+	}
+
+	if err := writeMBRSector(path, mbr); err != nil {
+		return fmt.Errorf("error al escribir el MBR protector: %v", err)
+	}
+
+	return nil
+}
+
+// writeGPTHeader escribe una cabecera GPT en el sector indicado por CurrentLBA
+func writeGPTHeader(path string, header *GPTHeader) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("error al serializar la cabecera GPT: %v", err)
+	}
+
+	if err := WriteToDisk(path, buf.Bytes(), header.CurrentLBA*GPT_SECTOR_SIZE); err != nil {
+		return fmt.Errorf("error al escribir la cabecera GPT en el sector %d: %v", header.CurrentLBA, err)
+	}
+
+	return nil
+}
+
+// computeCRC32 calcula el CRC32 de la cabecera con el campo HeaderCRC32 en cero
+func (h *GPTHeader) computeCRC32() uint32 {
+	copyHeader := *h
+	copyHeader.HeaderCRC32 = 0
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &copyHeader)
+	return crc32.ChecksumIEEE(buf.Bytes())
+}
+
+// newGPTEntry crea la entrada GPT de una partición
+func newGPTEntry(partition *Partition, guid [16]byte) GPTEntry {
+	return GPTEntry{
+		PartTypeGUID:    gptTipoDatos,
+		PartGUID:        guid,
+		PartStart:       partition.PartStart,
+		PartSize:        partition.PartSize,
+		PartName:        partition.PartName,
+		PartStatus:      partition.PartStatus,
+		PartFit:         partition.PartFit,
+		PartCorrelativo: partition.PartCorrelativo,
+		PartID:          partition.PartID,
+	}
+}
+
+// toPartition convierte la entrada GPT en una partición primaria
+func (e *GPTEntry) toPartition() Partition {
+	if e.PartTypeGUID == ([16]byte{}) {
+		return *NewEmptyPartition()
+	}
+
+	return Partition{
+		PartStatus:      e.PartStatus,
+		PartType:        PartitionTypePrimaria,
+		PartFit:         e.PartFit,
+		PartStart:       e.PartStart,
+		PartSize:        e.PartSize,
+		PartName:        e.PartName,
+		PartCorrelativo: e.PartCorrelativo,
+		PartID:          e.PartID,
+	}
+}
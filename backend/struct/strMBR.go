@@ -6,8 +6,8 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"math/big"
-	"os"
 	"time"
 	"unsafe"
 )
@@ -23,7 +23,21 @@ import (
 | dsk_fit            | char   | Tipo de ajuste de la partición. B (Best), F (First), W (Worst) |
 | mbr_partitions     | partition[4] | Estructura con información de las 4 particiones           |
 */
+// En memoria, MbrParticiones contiene las 4 particiones del MBR o las 128 entradas
+// de un disco GPT (ver strGPT.go), de modo que los comandos funcionan igual con
+// ambos tipos de tabla.
 type MBR struct {
+	MbrTamanio       int64
+	MbrFechaCreacion int64
+	MbrDiskSignature int64
+	MbrFit           byte
+	MbrParticiones   []Partition
+	MbrTipoTabla     byte     // TablaMBR o TablaGPT (no se guarda en el MBR)
+	gpt              *gptInfo // Identificadores de la tabla GPT (nil en discos MBR)
+}
+
+// mbrDisco es la representación binaria del MBR que se escribe en el primer sector del disco
+type mbrDisco struct {
 	MbrTamanio       int64 `binary:"little"`
 	MbrFechaCreacion int64 `binary:"little"`
 	MbrDiskSignature int64 `binary:"little"`
@@ -31,6 +45,12 @@ type MBR struct {
 	MbrParticiones   [4]Partition
 }
 
+// Constantes para el tipo de tabla de particiones
+const (
+	TablaMBR byte = 'M' // Tabla MBR de 4 particiones
+	TablaGPT byte = 'G' // Tabla GPT de 128 entradas
+)
+
 // Constantes para el tipo de ajuste de partición
 const (
 	PartitionFitBest  byte = 'B' // Mejor ajuste
@@ -42,17 +62,19 @@ const (
 const (
 	StatusInactiva byte = 0
 	StatusActiva   byte = 1
+	StatusMontada  byte = 'M' // Partición activa con el montaje persistido en el disco
 )
 
 // Constantes para tipo de partición
 const (
-	PartitionTypePrimaria  byte = 'P'
-	PartitionTypeExtendida byte = 'E'
-	PartitionTypeLogica    byte = 'L'
+	PartitionTypePrimaria   byte = 'P'
+	PartitionTypeExtendida  byte = 'E'
+	PartitionTypeLogica     byte = 'L'
+	PartitionTypeProtectiva byte = 'G' // Partición del MBR protector de un disco GPT
 )
 
 // Tamaño del MBR en bytes
-const MBR_SIZE = int(unsafe.Sizeof(MBR{}))
+const MBR_SIZE = int(unsafe.Sizeof(mbrDisco{}))
 
 // NewMBR crea un nuevo MBR con valores iniciales
 func NewMBR(tamanio int64, fit byte) *MBR {
@@ -61,7 +83,8 @@ func NewMBR(tamanio int64, fit byte) *MBR {
 		MbrFechaCreacion: time.Now().Unix(),
 		MbrDiskSignature: generateRandomSignature(),
 		MbrFit:           fit,
-		MbrParticiones:   [4]Partition{},
+		MbrParticiones:   make([]Partition, 4),
+		MbrTipoTabla:     TablaMBR,
 	}
 
 	// Inicializar particiones como inactivas
@@ -87,16 +110,8 @@ func WriteMBR(path string, sizeInBytes int64, fit string) error {
 	// Crear un nuevo MBR
 	mbr := NewMBR(sizeInBytes, fitByte)
 
-	// Serializar el MBR
-	mbrData, err := SerializeMBR(mbr)
-	if err != nil {
-		utils.LogError("WriteMBR", fmt.Sprintf("Error al serializar el MBR: %v", err))
-		return fmt.Errorf("error al serializar el MBR: %v", err)
-	}
-
-	// Escribir el MBR en el disco
-	err = WriteToDisk(path, mbrData, 0)
-	if err != nil {
+	// Escribir el MBR y su copia de respaldo en el disco
+	if err := writeMBRSector(path, mbr); err != nil {
 		utils.LogError("WriteMBR", fmt.Sprintf("Error al escribir el MBR en el disco: %v", err))
 		return fmt.Errorf("error al escribir el MBR en el disco: %v", err)
 	}
@@ -105,25 +120,60 @@ func WriteMBR(path string, sizeInBytes int64, fit string) error {
 	return nil
 }
 
-// ReadMBR lee el MBR desde el disco especificado
+// ReadMBR lee el MBR desde el disco especificado (ver ReadMBRFromDevice)
 func ReadMBR(path string) (*MBR, error) {
-	// Leer los primeros bytes del archivo (tamaño del MBR)
-	data, err := ReadFromDisk(path, 0, MBR_SIZE)
+	device, err := OpenDiskDevice(path)
 	if err != nil {
 		utils.LogError("ReadMBR", fmt.Sprintf("Error al leer el MBR desde el disco: %v", err))
 		return nil, fmt.Errorf("error al leer el MBR desde el disco: %v", err)
 	}
+	defer device.Close()
+
+	return ReadMBRFromDevice(device)
+}
 
-	// Deserializar los datos leídos en un MBR
-	mbr, err := DeserializeMBR(data)
+// ReadMBRFromDevice lee el MBR desde el dispositivo de un disco
+// Si el disco usa una tabla GPT, retorna la vista con sus 128 entradas
+// Si el MBR principal está dañado, usa la copia de respaldo del final del disco
+func ReadMBRFromDevice(device BlockDevice) (*MBR, error) {
+	// Leer los primeros bytes del disco (tamaño del MBR)
+	data, err := ReadFromDevice(diskView(device), 0, MBR_SIZE)
 	if err != nil {
-		utils.LogError("ReadMBR", fmt.Sprintf("Error al deserializar el MBR: %v", err))
-		return nil, fmt.Errorf("error al deserializar el MBR: %v", err)
+		utils.LogError("ReadMBR", fmt.Sprintf("Error al leer el MBR desde el disco: %v", err))
+		return nil, fmt.Errorf("error al leer el MBR desde el disco: %v", err)
+	}
+
+	// Deserializar y validar los datos leídos en un MBR
+	mbr, err := parsePrimaryMBR(data)
+	if err != nil {
+		backup, backupErr := readBackupMBR(device)
+		if backupErr != nil {
+			utils.LogError("ReadMBR", fmt.Sprintf("El MBR está dañado (%v) y la copia de respaldo no es válida (%v)", err, backupErr))
+			return nil, fmt.Errorf("MBR dañado: %v (copia de respaldo: %v)", err, backupErr)
+		}
+
+		utils.LogWarning("ReadMBR", fmt.Sprintf("El MBR principal está dañado (%v), se usará la copia de respaldo (use repairmbr para reescribirlo)", err))
+		mbr = backup
+	}
+
+	// Un MBR protector indica que las particiones están en la tabla GPT
+	if mbr.MbrTipoTabla == TablaGPT {
+		return readGPT(device, mbr)
 	}
 
 	return mbr, nil
 }
 
+// WritePartitionTable escribe la tabla de particiones del disco (MBR, o MBR protector y tablas GPT)
+// junto con la copia de respaldo del MBR
+func WritePartitionTable(path string, mbr *MBR) error {
+	if mbr.MbrTipoTabla == TablaGPT {
+		return writeGPT(path, mbr)
+	}
+
+	return writeMBRSector(path, mbr)
+}
+
 // generateRandomSignature genera una firma única para el disco
 func generateRandomSignature() int64 {
 	max := big.NewInt(1<<63 - 1)
@@ -148,14 +198,36 @@ func ValidateFit(fit string) byte {
 	}
 }
 
-// SerializeMBR convierte MBR a bytes
+// SerializeMBR convierte MBR a bytes (MBR_SIZE bytes, con el checksum después de los datos)
+// En un disco GPT se serializa el MBR protector; las entradas se escriben con WritePartitionTable
 func SerializeMBR(mbr *MBR) ([]byte, error) {
+	disco := mbrDisco{
+		MbrTamanio:       mbr.MbrTamanio,
+		MbrFechaCreacion: mbr.MbrFechaCreacion,
+		MbrDiskSignature: mbr.MbrDiskSignature,
+		MbrFit:           mbr.MbrFit,
+	}
+
+	if mbr.MbrTipoTabla == TablaGPT {
+		disco.MbrParticiones[0] = newProtectivePartition(mbr.MbrTamanio)
+	} else {
+		if len(mbr.MbrParticiones) > len(disco.MbrParticiones) {
+			return nil, fmt.Errorf("error al serializar MBR: tiene %d particiones, el máximo es %d",
+				len(mbr.MbrParticiones), len(disco.MbrParticiones))
+		}
+		copy(disco.MbrParticiones[:], mbr.MbrParticiones)
+	}
+
 	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, mbr)
+	err := binary.Write(buf, binary.LittleEndian, &disco)
 	if err != nil {
 		return nil, fmt.Errorf("error al serializar MBR: %v", err)
 	}
-	return buf.Bytes(), nil
+
+	data := make([]byte, MBR_SIZE)
+	copy(data, buf.Bytes())
+	binary.LittleEndian.PutUint32(data[mbrDataSize:], crc32.ChecksumIEEE(data[:mbrDataSize]))
+	return data, nil
 }
 
 // DeserializeMBR convierte bytes a MBR
@@ -163,20 +235,98 @@ func DeserializeMBR(data []byte) (*MBR, error) {
 	if len(data) < MBR_SIZE {
 		return nil, fmt.Errorf("datos insuficientes para MBR")
 	}
-	mbr := &MBR{}
+	disco := &mbrDisco{}
 	buf := bytes.NewReader(data)
-	err := binary.Read(buf, binary.LittleEndian, mbr)
+	err := binary.Read(buf, binary.LittleEndian, disco)
 	if err != nil {
 		return nil, fmt.Errorf("error al deserializar MBR: %v", err)
 	}
+
+	mbr := &MBR{
+		MbrTamanio:       disco.MbrTamanio,
+		MbrFechaCreacion: disco.MbrFechaCreacion,
+		MbrDiskSignature: disco.MbrDiskSignature,
+		MbrFit:           disco.MbrFit,
+		MbrParticiones:   disco.MbrParticiones[:],
+		MbrTipoTabla:     TablaMBR,
+	}
+
+	if disco.MbrParticiones[0].PartType == PartitionTypeProtectiva {
+		mbr.MbrTipoTabla = TablaGPT
+	}
+
 	return mbr, nil
 }
 
+// Clone crea una copia independiente del MBR (incluyendo sus particiones)
+func (m *MBR) Clone() *MBR {
+	clone := *m
+	clone.MbrParticiones = make([]Partition, len(m.MbrParticiones))
+	copy(clone.MbrParticiones, m.MbrParticiones)
+	if m.gpt != nil {
+		clone.gpt = m.gpt.clone()
+	}
+	return &clone
+}
+
+// IsGPT indica si el disco usa una tabla de particiones GPT
+func (m *MBR) IsGPT() bool {
+	return m.MbrTipoTabla == TablaGPT
+}
+
+// GetTipoTablaString obtiene el tipo de tabla de particiones como string
+func (m *MBR) GetTipoTablaString() string {
+	if m.IsGPT() {
+		return "GPT"
+	}
+	return "MBR"
+}
+
+// FirstUsableByte retorna el primer byte que pueden usar las particiones
+func (m *MBR) FirstUsableByte() int64 {
+	if m.IsGPT() {
+		return GPT_FIRST_USABLE
+	}
+	return int64(MBR_SIZE)
+}
+
+// LastUsableByte retorna el byte (exclusivo) donde termina el espacio para particiones,
+// antes de la copia de respaldo del MBR
+func (m *MBR) LastUsableByte() int64 {
+	return m.GetBackupMBROffset()
+}
+
+// GetTableSize retorna los bytes del disco reservados para la tabla de particiones
+func (m *MBR) GetTableSize() int64 {
+	return m.FirstUsableByte() + (m.MbrTamanio - m.LastUsableByte())
+}
+
 // ValidarFit verifica si el tipo de ajuste del MBR es valido
 func (m *MBR) ValidarFit() bool {
 	return m.MbrFit == PartitionFitBest || m.MbrFit == PartitionFitFirst || m.MbrFit == PartitionFitWorst
 }
 
+// ResetSignature asigna una nueva firma aleatoria al disco (usado al clonar discos)
+func (m *MBR) ResetSignature() {
+	m.MbrDiskSignature = generateRandomSignature()
+	if m.gpt != nil {
+		m.gpt = newGPTInfo(len(m.MbrParticiones))
+	}
+}
+
+// ClearMountState limpia el estado de montaje (status, correlativo e ID) de todas las particiones
+func (m *MBR) ClearMountState() {
+	for i := range m.MbrParticiones {
+		partition := &m.MbrParticiones[i]
+		if partition.PartStatus == StatusMontada {
+			partition.PartStatus = StatusActiva
+		}
+		if partition.IsActive() {
+			partition.Unmount()
+		}
+	}
+}
+
 // GetParticionLibre encuentra la primera partición disponible en el MBR
 func (m *MBR) GetParticionLibre() *Partition {
 	for i := range m.MbrParticiones {
@@ -190,7 +340,18 @@ func (m *MBR) GetParticionLibre() *Partition {
 // GetParticionByName busca una partición por nombre
 func (m *MBR) GetParticionByName(name string) *Partition {
 	for i := range m.MbrParticiones {
-		if m.MbrParticiones[i].GetName() == name && m.MbrParticiones[i].PartStatus == StatusActiva {
+		if m.MbrParticiones[i].GetName() == name && m.MbrParticiones[i].IsActive() {
+			return &m.MbrParticiones[i]
+		}
+	}
+	return nil
+}
+
+// GetParticionExtendida retorna la partición extendida activa o nil si no existe
+func (m *MBR) GetParticionExtendida() *Partition {
+	for i := range m.MbrParticiones {
+		if m.MbrParticiones[i].IsActive() &&
+			m.MbrParticiones[i].PartType == PartitionTypeExtendida {
 			return &m.MbrParticiones[i]
 		}
 	}
@@ -200,7 +361,7 @@ func (m *MBR) GetParticionByName(name string) *Partition {
 // HasExtendedPartition verifica si ya existe una partición extendida
 func (m *MBR) HasExtendedPartition() bool {
 	for i := range m.MbrParticiones {
-		if m.MbrParticiones[i].PartStatus == StatusActiva &&
+		if m.MbrParticiones[i].IsActive() &&
 			m.MbrParticiones[i].PartType == PartitionTypeExtendida {
 			return true
 		}
@@ -212,7 +373,7 @@ func (m *MBR) HasExtendedPartition() bool {
 func (m *MBR) CountActivePartitions() int {
 	count := 0
 	for i := range m.MbrParticiones {
-		if m.MbrParticiones[i].PartStatus == StatusActiva &&
+		if m.MbrParticiones[i].IsActive() &&
 			(m.MbrParticiones[i].PartType == PartitionTypePrimaria ||
 				m.MbrParticiones[i].PartType == PartitionTypeExtendida) {
 			count++
@@ -223,10 +384,10 @@ func (m *MBR) CountActivePartitions() int {
 
 // GetFreeSpace calcula el espacio libre disponible en el disco
 func (m *MBR) GetFreeSpace() int64 {
-	usedSpace := int64(MBR_SIZE) // Espacio usado por el MBR
+	usedSpace := m.GetTableSize() // Espacio usado por la tabla de particiones
 
 	for i := range m.MbrParticiones {
-		if m.MbrParticiones[i].PartStatus == StatusActiva {
+		if m.MbrParticiones[i].IsActive() {
 			usedSpace += m.MbrParticiones[i].PartSize
 		}
 	}
@@ -236,6 +397,12 @@ func (m *MBR) GetFreeSpace() int64 {
 
 // FindBestFitPosition encuentra la mejor posición para una nueva partición según el algoritmo especificado
 func (m *MBR) FindBestFitPosition(size int64) (int64, error) {
+	return m.FindAlignedFitPosition(size, 1)
+}
+
+// FindAlignedFitPosition encuentra la mejor posición para una nueva partición con su inicio
+// redondeado hacia arriba a un múltiplo de align (el espacio que se salta no cuenta para el ajuste)
+func (m *MBR) FindAlignedFitPosition(size, align int64) (int64, error) {
 	// Crear lista de espacios libres
 	freeSpaces := m.getFreeSpaces()
 
@@ -243,24 +410,38 @@ func (m *MBR) FindBestFitPosition(size int64) (int64, error) {
 		return -1, fmt.Errorf("no hay espacios libres disponibles")
 	}
 
-	switch m.MbrFit {
+	alignedSpaces := make([]FreeSpace, 0, len(freeSpaces))
+	for _, space := range freeSpaces {
+		alignedSpaces = append(alignedSpaces, space.Aligned(align))
+	}
+
+	if space, found := SelectFreeSpace(alignedSpaces, size, m.MbrFit); found {
+		return space.Start, nil
+	}
+
+	return -1, fmt.Errorf("no se encontró espacio suficiente para la partición")
+}
+
+// SelectFreeSpace elige el espacio libre para una partición de tamaño size según el ajuste
+func SelectFreeSpace(freeSpaces []FreeSpace, size int64, fit byte) (FreeSpace, bool) {
+	switch fit {
 	case PartitionFitFirst: // First Fit
 		for _, space := range freeSpaces {
 			if space.Size >= size {
-				return space.Start, nil
+				return space, true
 			}
 		}
 	case PartitionFitBest: // Best Fit
-		bestSpace := FreeSpace{Size: m.MbrTamanio + 1} // Inicializar con tamaño imposible
+		var bestSpace FreeSpace
 		found := false
 		for _, space := range freeSpaces {
-			if space.Size >= size && space.Size < bestSpace.Size {
+			if space.Size >= size && (!found || space.Size < bestSpace.Size) {
 				bestSpace = space
 				found = true
 			}
 		}
 		if found {
-			return bestSpace.Start, nil
+			return bestSpace, true
 		}
 	case PartitionFitWorst: // Worst Fit
 		worstSpace := FreeSpace{Size: -1} // Inicializar con tamaño inválido
@@ -270,11 +451,19 @@ func (m *MBR) FindBestFitPosition(size int64) (int64, error) {
 			}
 		}
 		if worstSpace.Size >= size {
-			return worstSpace.Start, nil
+			return worstSpace, true
 		}
 	}
 
-	return -1, fmt.Errorf("no se encontró espacio suficiente para la partición")
+	return FreeSpace{}, false
+}
+
+// AlignUp redondea value hacia arriba al siguiente múltiplo de align (align <= 1 no alinea)
+func AlignUp(value, align int64) int64 {
+	if align <= 1 || value%align == 0 {
+		return value
+	}
+	return value + align - value%align
 }
 
 // FreeSpace representa un espacio libre en el disco
@@ -305,17 +494,31 @@ func (fs FreeSpace) GetEndPosition() int64 {
 	return fs.Start + fs.Size
 }
 
+// Aligned retorna la parte del espacio libre que inicia en un múltiplo de align
+func (fs FreeSpace) Aligned(align int64) FreeSpace {
+	start := AlignUp(fs.Start, align)
+	if start >= fs.GetEndPosition() {
+		return FreeSpace{Start: start, Size: 0}
+	}
+	return FreeSpace{Start: start, Size: fs.GetEndPosition() - start}
+}
+
+// GetFreeSpaces retorna los espacios libres del disco ordenados por posición
+func (m *MBR) GetFreeSpaces() []FreeSpace {
+	return m.getFreeSpaces()
+}
+
 // getFreeSpaces obtiene todos los espacios libres en el disco
 func (m *MBR) getFreeSpaces() []FreeSpace {
 	var spaces []FreeSpace
 	var occupiedSpaces []FreeSpace
 
-	// Agregar el espacio ocupado por el MBR
-	occupiedSpaces = append(occupiedSpaces, FreeSpace{Start: 0, Size: int64(MBR_SIZE)})
+	// Agregar el espacio ocupado por la tabla de particiones (MBR, o MBR protector y GPT)
+	occupiedSpaces = append(occupiedSpaces, FreeSpace{Start: 0, Size: m.FirstUsableByte()})
 
 	// Agregar espacios ocupados por particiones activas
 	for i := range m.MbrParticiones {
-		if m.MbrParticiones[i].PartStatus == StatusActiva {
+		if m.MbrParticiones[i].IsActive() {
 			occupiedSpaces = append(occupiedSpaces, FreeSpace{
 				Start: m.MbrParticiones[i].PartStart,
 				Size:  m.MbrParticiones[i].PartSize,
@@ -344,11 +547,11 @@ func (m *MBR) getFreeSpaces() []FreeSpace {
 		currentPos = occupied.Start + occupied.Size
 	}
 
-	// Verificar si hay espacio libre al final del disco
-	if currentPos < m.MbrTamanio {
+	// Verificar si hay espacio libre al final del disco (antes de la copia de la GPT)
+	if currentPos < m.LastUsableByte() {
 		spaces = append(spaces, FreeSpace{
 			Start: currentPos,
-			Size:  m.MbrTamanio - currentPos,
+			Size:  m.LastUsableByte() - currentPos,
 		})
 	}
 
@@ -356,54 +559,65 @@ func (m *MBR) getFreeSpaces() []FreeSpace {
 }
 
 // WriteToDisk escribe datos en el disco en la posición especificada
+// Si el disco tiene snapshots la escritura va a la capa del snapshot más reciente
+// Si hay un comando registrándose para deshacer, antes se guarda el contenido sobrescrito
+// Dentro de una partición cifrada desbloqueada los datos se cifran antes de escribirse
+// Si el disco tiene sumas de verificación se actualizan las de las regiones escritas
+// (si fallan solo se advierte: la escritura ya se hizo y scrub reporta esas regiones)
 func WriteToDisk(path string, data []byte, offset int64) error {
-	// Abrir el archivo del disco
-	file, err := os.OpenFile(path, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("error al abrir el disco: %v", err)
-	}
-	defer file.Close()
+	captureUndo(path, offset, len(data))
 
-	// Posicionarse en el offset especificado
-	_, err = file.Seek(offset, 0)
+	device, release, err := acquireDevice(path)
 	if err != nil {
-		return fmt.Errorf("error al posicionar el disco: %v", err)
+		return err
 	}
+	defer release()
 
-	// Escribir los datos en el disco
-	_, err = file.Write(data)
-	if err != nil {
-		return fmt.Errorf("error al escribir en el disco: %v", err)
+	if err := WriteToDevice(cryptView(path, device), data, offset); err != nil {
+		return err
 	}
-
+	warnStaleChecksums(path, device, offset, len(data))
 	return nil
 }
 
 // ReadFromDisk lee datos del disco desde la posición especificada
+// Si el disco tiene snapshots cada bloque se lee de la capa más reciente que lo tenga
+// Dentro de una partición cifrada desbloqueada los datos se leen descifrados
 func ReadFromDisk(path string, offset int64, size int) ([]byte, error) {
-	// Abrir el archivo del disco
-	file, err := os.Open(path)
+	device, release, err := acquireDevice(path)
 	if err != nil {
-		return nil, fmt.Errorf("error al abrir el disco: %v", err)
+		return nil, err
 	}
-	defer file.Close()
+	defer release()
+
+	return ReadFromDevice(cryptView(path, device), offset, size)
+}
 
-	// Posicionarse en el offset especificado
-	_, err = file.Seek(offset, 0)
+// WriteRawToDisk escribe como WriteToDisk pero sin cifrar: los bytes quedan tal cual en el disco
+// Se usa para copiar o mover particiones y para restaurar contenido guardado en crudo
+func WriteRawToDisk(path string, data []byte, offset int64) error {
+	captureUndo(path, offset, len(data))
+
+	device, release, err := acquireDevice(path)
 	if err != nil {
-		return nil, fmt.Errorf("error al posicionar el disco: %v", err)
+		return err
 	}
+	defer release()
 
-	// Leer los datos del disco
-	data := make([]byte, size)
-	n, err := file.Read(data)
-	if err != nil {
-		return nil, fmt.Errorf("error al leer del disco: %v", err)
+	if err := WriteToDevice(device, data, offset); err != nil {
+		return err
 	}
+	warnStaleChecksums(path, device, offset, len(data))
+	return nil
+}
 
-	if n != size {
-		return nil, fmt.Errorf("no se leyeron suficientes bytes del disco: %d de %d", n, size)
+// ReadRawFromDisk lee como ReadFromDisk pero sin descifrar las particiones desbloqueadas
+func ReadRawFromDisk(path string, offset int64, size int) ([]byte, error) {
+	device, release, err := acquireDevice(path)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	return data, nil
+	return ReadFromDevice(device, offset, size)
 }
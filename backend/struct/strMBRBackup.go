@@ -0,0 +1,269 @@
+package estructuras
+
+import (
+	utils "backend/Utils"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Copia de respaldo del MBR
+// Cada vez que se escribe el MBR también se actualiza una copia con checksum en un
+// sector fijo cerca del final del disco. Si el MBR principal está dañado (checksum
+// inválido, ajuste inválido o particiones traslapadas) ReadMBR usa la copia.
+/*
+| Región                    | Posición en disco MBR | Posición en disco GPT               |
+|---------------------------|-----------------------|-------------------------------------|
+| Copia de respaldo del MBR | Tamaño - 512          | Tamaño - 16896 - 512 (antes de la   |
+|                           |                       | copia de respaldo de la GPT)        |
+
+| Nombre   | Tipo     | Descripción                                      |
+|----------|----------|--------------------------------------------------|
+| magic    | char[8]  | "MBRBACKP"                                       |
+| checksum | uint32   | CRC32 de los bytes del MBR guardados en la copia |
+| length   | uint32   | Cantidad de bytes del MBR (MBR_SIZE)             |
+| mbr      | byte[]   | MBR serializado, igual al del sector 0           |
+*/
+
+// Tamaño reservado para la copia de respaldo del MBR
+const MBR_BACKUP_SIZE int64 = 512
+
+// Firma de la copia de respaldo del MBR
+var mbrBackupMagic = [8]byte{'M', 'B', 'R', 'B', 'A', 'C', 'K', 'P'}
+
+// Error retornado cuando no hay copia de respaldo en la posición leída
+var errNoMBRBackup = errors.New("no hay copia de respaldo del MBR")
+
+// Bytes del MBR cubiertos por el checksum; el checksum se guarda a continuación,
+// en el relleno que queda hasta MBR_SIZE
+var mbrDataSize = binary.Size(mbrDisco{})
+
+// mbrBackupHeader es la cabecera de la copia de respaldo del MBR
+type mbrBackupHeader struct {
+	Magic    [8]byte `binary:"little"`
+	Checksum uint32  `binary:"little"`
+	Length   uint32  `binary:"little"`
+}
+
+// GetBackupMBROffset retorna la posición de la copia de respaldo del MBR
+func (m *MBR) GetBackupMBROffset() int64 {
+	return backupMBROffset(m.MbrTamanio, m.IsGPT())
+}
+
+// backupMBROffset calcula la posición de la copia de respaldo para un disco del tamaño indicado
+func backupMBROffset(diskSize int64, gpt bool) int64 {
+	if gpt {
+		return diskSize - GPT_BACKUP_SIZE - MBR_BACKUP_SIZE
+	}
+	return diskSize - MBR_BACKUP_SIZE
+}
+
+// writeMBRSector escribe el MBR en el sector 0 y actualiza su copia de respaldo
+func writeMBRSector(path string, mbr *MBR) error {
+	mbrData, err := SerializeMBR(mbr)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteToDisk(path, mbrData, 0); err != nil {
+		return fmt.Errorf("error al escribir MBR: %v", err)
+	}
+
+	return writeBackupMBR(path, mbr, mbrData)
+}
+
+// writeBackupMBR escribe la copia de respaldo del MBR ya serializado
+func writeBackupMBR(path string, mbr *MBR, mbrData []byte) error {
+	offset := mbr.GetBackupMBROffset()
+	if offset < mbr.FirstUsableByte() {
+		utils.LogWarning("BackupMBR", "El disco es demasiado pequeño para la copia de respaldo del MBR")
+		return nil
+	}
+
+	// Discos creados antes de la copia de respaldo pueden tener datos en ese sector
+	for i := range mbr.MbrParticiones {
+		partition := &mbr.MbrParticiones[i]
+		if partition.IsActive() && partition.GetEndPosition() > offset {
+			utils.LogWarning("BackupMBR", fmt.Sprintf("La partición '%s' ocupa el sector de la copia de respaldo del MBR, no se actualiza la copia", partition.GetName()))
+			return nil
+		}
+	}
+
+	header := mbrBackupHeader{
+		Magic:    mbrBackupMagic,
+		Checksum: crc32.ChecksumIEEE(mbrData),
+		Length:   uint32(len(mbrData)),
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("error al serializar la copia de respaldo del MBR: %v", err)
+	}
+	buf.Write(mbrData)
+
+	if err := WriteToDisk(path, buf.Bytes(), offset); err != nil {
+		return fmt.Errorf("error al escribir la copia de respaldo del MBR: %v", err)
+	}
+
+	return nil
+}
+
+// ReadPrimaryMBR lee y valida el MBR del sector 0, sin usar la copia de respaldo
+func ReadPrimaryMBR(path string) (*MBR, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el MBR desde el disco: %v", err)
+	}
+	defer device.Close()
+
+	return readPrimaryMBR(device)
+}
+
+// readPrimaryMBR lee y valida el MBR del sector 0 de un dispositivo
+func readPrimaryMBR(device BlockDevice) (*MBR, error) {
+	data, err := ReadFromDevice(diskView(device), 0, MBR_SIZE)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el MBR desde el disco: %v", err)
+	}
+
+	return parsePrimaryMBR(data)
+}
+
+// parsePrimaryMBR deserializa el MBR del sector 0 verificando su checksum y su contenido
+func parsePrimaryMBR(data []byte) (*MBR, error) {
+	if len(data) < MBR_SIZE {
+		return nil, fmt.Errorf("datos insuficientes para MBR")
+	}
+
+	// Los discos creados antes del checksum lo tienen en cero
+	stored := binary.LittleEndian.Uint32(data[mbrDataSize:])
+	if stored != 0 {
+		if computed := crc32.ChecksumIEEE(data[:mbrDataSize]); computed != stored {
+			return nil, fmt.Errorf("checksum del MBR inválido (esperado %08x, calculado %08x)", stored, computed)
+		}
+	}
+
+	mbr, err := DeserializeMBR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateMBRTable(mbr); err != nil {
+		return nil, err
+	}
+
+	return mbr, nil
+}
+
+// ReadBackupMBR lee y valida la copia de respaldo del MBR
+func ReadBackupMBR(path string) (*MBR, error) {
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	return readBackupMBR(device)
+}
+
+// readBackupMBR lee y valida la copia de respaldo del MBR de un dispositivo
+// La posición se calcula con el tamaño del disco, ya que el MBR principal puede estar dañado
+func readBackupMBR(device BlockDevice) (*MBR, error) {
+	size := device.Size()
+
+	var lastErr error
+	for _, gpt := range []bool{false, true} {
+		offset := backupMBROffset(size, gpt)
+		if offset < int64(MBR_SIZE) {
+			continue
+		}
+
+		mbr, err := readBackupMBRAt(device, offset)
+		if err != nil {
+			// Una copia dañada es más relevante que una posición sin copia
+			if lastErr == nil || !errors.Is(err, errNoMBRBackup) {
+				lastErr = err
+			}
+			continue
+		}
+
+		if mbr.MbrTamanio != size || mbr.IsGPT() != gpt {
+			lastErr = fmt.Errorf("la copia de respaldo en el byte %d no corresponde a este disco", offset)
+			continue
+		}
+
+		return mbr, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("el disco es demasiado pequeño para tener copia de respaldo")
+	}
+	return nil, lastErr
+}
+
+// readBackupMBRAt lee la copia de respaldo del MBR en la posición indicada
+func readBackupMBRAt(device BlockDevice, offset int64) (*MBR, error) {
+	headerSize := binary.Size(mbrBackupHeader{})
+	data, err := ReadFromDevice(diskView(device), offset, headerSize+MBR_SIZE)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la copia de respaldo: %v", err)
+	}
+
+	header := &mbrBackupHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("error al deserializar la copia de respaldo: %v", err)
+	}
+
+	if header.Magic != mbrBackupMagic {
+		return nil, fmt.Errorf("%w en el byte %d", errNoMBRBackup, offset)
+	}
+
+	if header.Length != uint32(MBR_SIZE) {
+		return nil, fmt.Errorf("tamaño inválido en la copia de respaldo del MBR: %d bytes", header.Length)
+	}
+
+	mbrData := data[headerSize:]
+	if crc := crc32.ChecksumIEEE(mbrData); crc != header.Checksum {
+		return nil, fmt.Errorf("checksum de la copia de respaldo inválido (esperado %08x, calculado %08x)", header.Checksum, crc)
+	}
+
+	mbr, err := DeserializeMBR(mbrData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateMBRTable(mbr); err != nil {
+		return nil, fmt.Errorf("copia de respaldo inválida: %v", err)
+	}
+
+	return mbr, nil
+}
+
+// validateMBRTable detecta un MBR dañado: ajuste inválido o particiones traslapadas
+func validateMBRTable(mbr *MBR) error {
+	if mbr.MbrTamanio <= 0 {
+		return fmt.Errorf("tamaño de disco inválido en el MBR: %d", mbr.MbrTamanio)
+	}
+
+	if !mbr.ValidarFit() {
+		return fmt.Errorf("tipo de ajuste inválido en el MBR: %c", mbr.MbrFit)
+	}
+
+	for i := range mbr.MbrParticiones {
+		if mbr.MbrParticiones[i].IsEmpty() {
+			continue
+		}
+		for j := i + 1; j < len(mbr.MbrParticiones); j++ {
+			if mbr.MbrParticiones[j].IsEmpty() {
+				continue
+			}
+			if mbr.MbrParticiones[i].Overlaps(&mbr.MbrParticiones[j]) {
+				return fmt.Errorf("las particiones %d y %d se superponen en el MBR", i, j)
+			}
+		}
+	}
+
+	return nil
+}
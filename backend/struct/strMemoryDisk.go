@@ -0,0 +1,119 @@
+package estructuras
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Discos en memoria
+// Una ruta mem://<nombre> es un disco que vive en RAM mientras el backend está en
+// ejecución: mkdisk lo crea, rmdisk lo elimina y el resto de comandos lo usan igual
+// que a un archivo .mia, sin tocar el sistema de archivos. Sirven para pruebas y
+// sesiones temporales. No tienen snapshots ni diario de deshacer (ambos se guardan
+// en archivos junto al disco) y se pierden al reiniciar el backend.
+
+// MEMORY_DISK_SCHEME es el prefijo de las rutas de los discos en memoria
+const MEMORY_DISK_SCHEME = "mem://"
+
+// MemoryDiskInfo describe un disco en memoria
+type MemoryDiskInfo struct {
+	Path string `json:"path"` // Ruta mem://<nombre>
+	Size int64  `json:"size"` // Tamaño en bytes
+}
+
+// Discos en memoria creados (Key: ruta mem://<nombre>)
+var memoryDisks = struct {
+	mutex sync.Mutex
+	disks map[string]*MemoryDevice
+}{disks: make(map[string]*MemoryDevice)}
+
+// IsMemoryDisk indica si la ruta es de un disco en memoria
+func IsMemoryDisk(path string) bool {
+	return strings.HasPrefix(path, MEMORY_DISK_SCHEME)
+}
+
+// CreateMemoryDisk crea un disco en memoria lleno de ceros
+func CreateMemoryDisk(path string, size int64) error {
+	name := strings.TrimPrefix(path, MEMORY_DISK_SCHEME)
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("la ruta %s no tiene nombre de disco", path)
+	}
+	if size <= 0 {
+		return fmt.Errorf("el tamaño del disco debe ser mayor que cero")
+	}
+
+	memoryDisks.mutex.Lock()
+	defer memoryDisks.mutex.Unlock()
+
+	if _, exists := memoryDisks.disks[path]; exists {
+		return fmt.Errorf("el disco en memoria ya existe: %s", path)
+	}
+
+	memoryDisks.disks[path] = NewMemoryDevice(size)
+	return nil
+}
+
+// RemoveMemoryDisk elimina un disco en memoria y libera sus datos
+func RemoveMemoryDisk(path string) error {
+	memoryDisks.mutex.Lock()
+	defer memoryDisks.mutex.Unlock()
+
+	if _, exists := memoryDisks.disks[path]; !exists {
+		return fmt.Errorf("el disco en memoria no existe: %s", path)
+	}
+
+	delete(memoryDisks.disks, path)
+	return nil
+}
+
+// ResizeMemoryDisk cambia el tamaño de un disco en memoria
+// Al crecer, el espacio agregado queda en ceros; al reducir, se descarta el final
+func ResizeMemoryDisk(path string, size int64) error {
+	device, err := memoryDisk(path)
+	if err != nil {
+		return err
+	}
+
+	device.Resize(size)
+	return nil
+}
+
+// MemoryDisks retorna los discos en memoria ordenados por ruta
+func MemoryDisks() []MemoryDiskInfo {
+	memoryDisks.mutex.Lock()
+	defer memoryDisks.mutex.Unlock()
+
+	disks := make([]MemoryDiskInfo, 0, len(memoryDisks.disks))
+	for path, device := range memoryDisks.disks {
+		disks = append(disks, MemoryDiskInfo{Path: path, Size: device.Size()})
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Path < disks[j].Path })
+
+	return disks
+}
+
+// memoryDisk retorna el dispositivo de un disco en memoria
+func memoryDisk(path string) (*MemoryDevice, error) {
+	memoryDisks.mutex.Lock()
+	defer memoryDisks.mutex.Unlock()
+
+	device, exists := memoryDisks.disks[path]
+	if !exists {
+		return nil, fmt.Errorf("el disco en memoria no existe: %s", path)
+	}
+	return device, nil
+}
+
+// DiskExists indica si existe el disco, en memoria o como archivo
+func DiskExists(path string) bool {
+	if IsMemoryDisk(path) {
+		_, err := memoryDisk(path)
+		return err == nil
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -145,9 +145,9 @@ func (p *Partition) IsEmpty() bool {
 	return p.PartStatus == StatusInactiva
 }
 
-// IsActive verifica si la partición está activa
+// IsActive verifica si la partición está activa (montada o no)
 func (p *Partition) IsActive() bool {
-	return p.PartStatus == StatusActiva
+	return p.PartStatus == StatusActiva || p.PartStatus == StatusMontada
 }
 
 // IsMounted verifica si la partición está montada (correlativo >= 1)
@@ -205,6 +205,8 @@ func (p *Partition) GetStatusString() string {
 		return "Activa"
 	case StatusInactiva:
 		return "Inactiva"
+	case StatusMontada:
+		return "Montada"
 	default:
 		return "Desconocido"
 	}
@@ -0,0 +1,489 @@
+package estructuras
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// Cifrado de particiones
+// Una partición cifrada guarda en su primer sector una cabecera con la sal de la
+// derivación de la clave y un verificador de la frase de contraseña. Los sectores
+// siguientes se cifran con AES-256 en modo XTS, usando como tweak el número de
+// sector contado desde el inicio de los datos de la partición, así que la partición
+// se puede mover o clonar sin volver a cifrarla.
+//
+// Mientras la partición está desbloqueada (montada con -passphrase) las lecturas y
+// escrituras con ReadFromDisk y WriteToDisk dentro de sus datos se descifran y cifran
+// de forma transparente; bloqueada, el disco solo ve el texto cifrado. Los bytes que
+// sobran al final (menos de un sector) no se usan.
+/*
+| Nombre     | Tipo     | Descripción                                                        |
+|------------|----------|--------------------------------------------------------------------|
+| magic      | char[8]  | "MIACRYPT"                                                         |
+| version    | uint32   | Versión de la cabecera (1)                                         |
+| iterations | uint32   | Iteraciones de PBKDF2-HMAC-SHA256                                  |
+| salt       | byte[32] | Sal aleatoria de la derivación de la clave                         |
+| verifier   | byte[32] | SHA-256 de la parte de la clave derivada que verifica la frase     |
+| (relleno)  | byte[]   | Ceros hasta completar el sector                                    |
+*/
+
+// Sector de cifrado y tamaño de la cabecera (un sector al inicio de la partición)
+const (
+	CRYPT_SECTOR_SIZE int64 = 512
+	CRYPT_HEADER_SIZE int64 = CRYPT_SECTOR_SIZE
+)
+
+// CRYPT_KDF_ITERATIONS son las iteraciones de PBKDF2 de las particiones nuevas
+const CRYPT_KDF_ITERATIONS = 100000
+
+// Firma y versión de la cabecera de cifrado
+var cryptHeaderMagic = [8]byte{'M', 'I', 'A', 'C', 'R', 'Y', 'P', 'T'}
+
+const cryptHeaderVersion = 1
+
+// Bytes de la clave derivada: dos claves AES-256 para XTS y 32 bytes para el verificador
+const (
+	cryptXTSKeySize      = 64
+	cryptVerifierKeySize = 32
+)
+
+// cryptHeader es la cabecera de una partición cifrada
+type cryptHeader struct {
+	Magic      [8]byte  `binary:"little"`
+	Version    uint32   `binary:"little"`
+	Iterations uint32   `binary:"little"`
+	Salt       [32]byte `binary:"little"`
+	Verifier   [32]byte `binary:"little"`
+}
+
+// unlockedPartition es una partición cifrada desbloqueada
+type unlockedPartition struct {
+	start     int64 // Inicio de la partición (su cabecera)
+	dataStart int64 // Primer byte cifrado
+	dataEnd   int64 // Fin de los sectores cifrados (exclusivo)
+	tailEnd   int64 // Fin de la partición; [dataEnd, tailEnd) no se usa
+	xts       *xtsCipher
+}
+
+// Particiones desbloqueadas (Key: ruta normalizada del disco, ver diskLockKey)
+var unlockedPartitions = struct {
+	mutex sync.RWMutex
+	disks map[string][]*unlockedPartition
+}{disks: make(map[string][]*unlockedPartition)}
+
+// cryptDataRange retorna el rango de sectores cifrados de una partición
+func cryptDataRange(start, size int64) (int64, int64) {
+	dataStart := start + CRYPT_HEADER_SIZE
+	sectors := (size - CRYPT_HEADER_SIZE) / CRYPT_SECTOR_SIZE
+	return dataStart, dataStart + sectors*CRYPT_SECTOR_SIZE
+}
+
+// EncryptPartition convierte una partición en cifrada escribiendo su cabecera
+// Los datos que tuviera la partición quedan ilegibles
+func EncryptPartition(path string, start, size int64, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("la frase de contraseña no puede estar vacía")
+	}
+	if size < CRYPT_HEADER_SIZE+CRYPT_SECTOR_SIZE {
+		return fmt.Errorf("la partición es demasiado pequeña para cifrarla (mínimo %d bytes)", CRYPT_HEADER_SIZE+CRYPT_SECTOR_SIZE)
+	}
+
+	header := cryptHeader{
+		Magic:      cryptHeaderMagic,
+		Version:    cryptHeaderVersion,
+		Iterations: CRYPT_KDF_ITERATIONS,
+	}
+	if _, err := rand.Read(header.Salt[:]); err != nil {
+		return fmt.Errorf("error al generar la sal: %v", err)
+	}
+
+	_, verifierKey := deriveCryptKeys(passphrase, header.Salt[:], int(header.Iterations))
+	header.Verifier = sha256.Sum256(verifierKey)
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("error al serializar la cabecera de cifrado: %v", err)
+	}
+	data := make([]byte, CRYPT_HEADER_SIZE)
+	copy(data, buf.Bytes())
+
+	return WriteToDisk(path, data, start)
+}
+
+// IsEncryptedPartition indica si la partición tiene cabecera de cifrado
+func IsEncryptedPartition(path string, start, size int64) (bool, error) {
+	header, err := readCryptHeader(path, start, size)
+	if err != nil {
+		return false, err
+	}
+	return header != nil, nil
+}
+
+// readCryptHeader lee la cabecera de cifrado de una partición, o nil si no está cifrada
+func readCryptHeader(path string, start, size int64) (*cryptHeader, error) {
+	if size < CRYPT_HEADER_SIZE+CRYPT_SECTOR_SIZE {
+		return nil, nil
+	}
+
+	device, err := OpenDiskDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la cabecera de cifrado: %v", err)
+	}
+	defer device.Close()
+
+	return readCryptHeaderFromDevice(device, start, size)
+}
+
+// readCryptHeaderFromDevice lee la cabecera de cifrado de una partición del dispositivo de un disco
+func readCryptHeaderFromDevice(device BlockDevice, start, size int64) (*cryptHeader, error) {
+	if size < CRYPT_HEADER_SIZE+CRYPT_SECTOR_SIZE {
+		return nil, nil
+	}
+
+	// La cabecera está fuera de los datos cifrados, se lee igual bloqueada o no
+	data, err := ReadFromDevice(device, start, binary.Size(cryptHeader{}))
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la cabecera de cifrado: %v", err)
+	}
+
+	header := &cryptHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+		return nil, fmt.Errorf("error al leer la cabecera de cifrado: %v", err)
+	}
+	if header.Magic != cryptHeaderMagic {
+		return nil, nil
+	}
+	if header.Version != cryptHeaderVersion || header.Iterations == 0 {
+		return nil, fmt.Errorf("cabecera de cifrado no soportada (versión %d)", header.Version)
+	}
+
+	return header, nil
+}
+
+// UnlockPartition verifica la frase de contraseña y desbloquea la partición
+func UnlockPartition(path string, start, size int64, passphrase string) error {
+	header, err := readCryptHeader(path, start, size)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return fmt.Errorf("la partición no está cifrada")
+	}
+
+	xtsKey, verifierKey := deriveCryptKeys(passphrase, header.Salt[:], int(header.Iterations))
+	verifier := sha256.Sum256(verifierKey)
+	if subtle.ConstantTimeCompare(verifier[:], header.Verifier[:]) != 1 {
+		return fmt.Errorf("frase de contraseña incorrecta")
+	}
+
+	xts, err := newXTSCipher(xtsKey)
+	if err != nil {
+		return err
+	}
+
+	dataStart, dataEnd := cryptDataRange(start, size)
+	partition := &unlockedPartition{
+		start:     start,
+		dataStart: dataStart,
+		dataEnd:   dataEnd,
+		tailEnd:   start + size,
+		xts:       xts,
+	}
+
+	unlockedPartitions.mutex.Lock()
+	defer unlockedPartitions.mutex.Unlock()
+
+	key := diskLockKey(path)
+	partitions := []*unlockedPartition{partition}
+	for _, other := range unlockedPartitions.disks[key] {
+		if other.start != start {
+			partitions = append(partitions, other)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].start < partitions[j].start })
+	unlockedPartitions.disks[key] = partitions
+
+	return nil
+}
+
+// LockPartition bloquea una partición desbloqueada; sus datos vuelven a verse cifrados
+func LockPartition(path string, start int64) {
+	unlockedPartitions.mutex.Lock()
+	defer unlockedPartitions.mutex.Unlock()
+
+	key := diskLockKey(path)
+	var partitions []*unlockedPartition
+	for _, partition := range unlockedPartitions.disks[key] {
+		if partition.start != start {
+			partitions = append(partitions, partition)
+		}
+	}
+
+	if len(partitions) == 0 {
+		delete(unlockedPartitions.disks, key)
+		return
+	}
+	unlockedPartitions.disks[key] = partitions
+}
+
+// LockDiskPartitions bloquea las particiones desbloqueadas de un disco (al eliminarlo)
+func LockDiskPartitions(path string) {
+	unlockedPartitions.mutex.Lock()
+	defer unlockedPartitions.mutex.Unlock()
+
+	delete(unlockedPartitions.disks, diskLockKey(path))
+}
+
+// LockAllPartitions bloquea todas las particiones desbloqueadas
+func LockAllPartitions() {
+	unlockedPartitions.mutex.Lock()
+	defer unlockedPartitions.mutex.Unlock()
+
+	unlockedPartitions.disks = make(map[string][]*unlockedPartition)
+}
+
+// IsPartitionUnlocked indica si la partición cifrada que inicia en start está desbloqueada
+func IsPartitionUnlocked(path string, start int64) bool {
+	for _, partition := range unlockedPartitionsOf(path) {
+		if partition.start == start {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockedPartitionsOf retorna las particiones desbloqueadas del disco, ordenadas por inicio
+func unlockedPartitionsOf(path string) []*unlockedPartition {
+	unlockedPartitions.mutex.RLock()
+	defer unlockedPartitions.mutex.RUnlock()
+
+	return unlockedPartitions.disks[diskLockKey(path)]
+}
+
+// deriveCryptKeys deriva de la frase de contraseña la clave XTS y la clave del verificador
+func deriveCryptKeys(passphrase string, salt []byte, iterations int) ([]byte, []byte) {
+	key := pbkdf2SHA256([]byte(passphrase), salt, iterations, cryptXTSKeySize+cryptVerifierKeySize)
+	return key[:cryptXTSKeySize], key[cryptXTSKeySize:]
+}
+
+// pbkdf2SHA256 implementa PBKDF2 (RFC 8018) con HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLength + sha256.Size - 1) / sha256.Size
+
+	key := make([]byte, 0, blocks*sha256.Size)
+	counter := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		u := pbkdf2Round(prf, salt, counter)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			u = pbkdf2Round(prf, u)
+			subtle.XORBytes(t, t, u)
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
+
+// pbkdf2Round calcula un HMAC de las partes concatenadas
+func pbkdf2Round(prf hash.Hash, parts ...[]byte) []byte {
+	prf.Reset()
+	for _, part := range parts {
+		prf.Write(part)
+	}
+	return prf.Sum(nil)
+}
+
+// xtsCipher cifra sectores con AES-XTS (IEEE 1619)
+type xtsCipher struct {
+	data  cipher.Block // Clave de los datos
+	tweak cipher.Block // Clave del tweak
+}
+
+// newXTSCipher crea el cifrador a partir de las dos claves concatenadas
+func newXTSCipher(key []byte) (*xtsCipher, error) {
+	half := len(key) / 2
+	data, err := aes.NewCipher(key[:half])
+	if err != nil {
+		return nil, fmt.Errorf("error al crear el cifrador: %v", err)
+	}
+	tweak, err := aes.NewCipher(key[half:])
+	if err != nil {
+		return nil, fmt.Errorf("error al crear el cifrador: %v", err)
+	}
+	return &xtsCipher{data: data, tweak: tweak}, nil
+}
+
+// encryptSector cifra un sector completo
+func (x *xtsCipher) encryptSector(dst, src []byte, sector uint64) {
+	x.cryptSector(dst, src, sector, x.data.Encrypt)
+}
+
+// decryptSector descifra un sector completo
+func (x *xtsCipher) decryptSector(dst, src []byte, sector uint64) {
+	x.cryptSector(dst, src, sector, x.data.Decrypt)
+}
+
+// cryptSector aplica el modo XTS a cada bloque de 16 bytes del sector
+func (x *xtsCipher) cryptSector(dst, src []byte, sector uint64, crypt func(dst, src []byte)) {
+	var tweak [aes.BlockSize]byte
+	binary.LittleEndian.PutUint64(tweak[:8], sector)
+	x.tweak.Encrypt(tweak[:], tweak[:])
+
+	var block [aes.BlockSize]byte
+	for i := 0; i < len(src); i += aes.BlockSize {
+		subtle.XORBytes(block[:], src[i:i+aes.BlockSize], tweak[:])
+		crypt(block[:], block[:])
+		subtle.XORBytes(dst[i:i+aes.BlockSize], block[:], tweak[:])
+
+		// Siguiente tweak: multiplicar por x en GF(2^128)
+		carry := tweak[aes.BlockSize-1] >> 7
+		for j := aes.BlockSize - 1; j > 0; j-- {
+			tweak[j] = tweak[j]<<1 | tweak[j-1]>>7
+		}
+		tweak[0] <<= 1
+		if carry != 0 {
+			tweak[0] ^= 0x87
+		}
+	}
+}
+
+// cryptDevice es la vista de un disco con particiones cifradas desbloqueadas
+// Fuera de los datos de esas particiones lee y escribe el dispositivo tal cual
+type cryptDevice struct {
+	BlockDevice
+	partitions []*unlockedPartition
+}
+
+// cryptView retorna el dispositivo del disco visto a través de sus particiones desbloqueadas
+func cryptView(path string, device BlockDevice) BlockDevice {
+	partitions := unlockedPartitionsOf(path)
+	if len(partitions) == 0 {
+		return device
+	}
+	return &cryptDevice{BlockDevice: device, partitions: partitions}
+}
+
+// sectorRange amplía un rango de los datos de la partición a sectores completos
+func (p *unlockedPartition) sectorRange(start, end int64) (int64, int64) {
+	first := p.dataStart + (start-p.dataStart)/CRYPT_SECTOR_SIZE*CRYPT_SECTOR_SIZE
+	last := p.dataStart + (end-p.dataStart+CRYPT_SECTOR_SIZE-1)/CRYPT_SECTOR_SIZE*CRYPT_SECTOR_SIZE
+	return first, last
+}
+
+// sectorNumber retorna el número de sector (el tweak) de un byte de los datos de la partición
+func (p *unlockedPartition) sectorNumber(offset int64) uint64 {
+	return uint64((offset - p.dataStart) / CRYPT_SECTOR_SIZE)
+}
+
+// overlap retorna la intersección de [start, end) con [from, to)
+func overlap(start, end, from, to int64) (int64, int64, bool) {
+	if start < from {
+		start = from
+	}
+	if end > to {
+		end = to
+	}
+	return start, end, start < end
+}
+
+// ReadAt lee del disco y descifra lo que cae en los datos de una partición desbloqueada
+func (d *cryptDevice) ReadAt(data []byte, offset int64) (int, error) {
+	n, err := d.BlockDevice.ReadAt(data, offset)
+	if n < len(data) {
+		return n, err
+	}
+
+	end := offset + int64(len(data))
+	for _, partition := range d.partitions {
+		start, stop, ok := overlap(offset, end, partition.dataStart, partition.dataEnd)
+		if !ok {
+			continue
+		}
+
+		first, last := partition.sectorRange(start, stop)
+		sectors := make([]byte, last-first)
+		if _, err := d.BlockDevice.ReadAt(sectors, first); err != nil {
+			return 0, err
+		}
+		for i := int64(0); i < int64(len(sectors)); i += CRYPT_SECTOR_SIZE {
+			sector := sectors[i : i+CRYPT_SECTOR_SIZE]
+			partition.xts.decryptSector(sector, sector, partition.sectorNumber(first+i))
+		}
+		copy(data[start-offset:stop-offset], sectors[start-first:stop-first])
+	}
+
+	return n, err
+}
+
+// WriteAt cifra lo que cae en los datos de una partición desbloqueada y escribe el resto tal cual
+func (d *cryptDevice) WriteAt(data []byte, offset int64) (int, error) {
+	end := offset + int64(len(data))
+	position := offset
+
+	for _, partition := range d.partitions {
+		// El final sin usar no se cifra: escribir ahí dejaría datos en claro
+		if _, _, ok := overlap(offset, end, partition.dataEnd, partition.tailEnd); ok {
+			return 0, fmt.Errorf("rango [%d, %d) fuera de los sectores cifrados de la partición", offset, end)
+		}
+
+		start, stop, ok := overlap(offset, end, partition.dataStart, partition.dataEnd)
+		if !ok {
+			continue
+		}
+
+		if position < start {
+			if _, err := d.BlockDevice.WriteAt(data[position-offset:start-offset], position); err != nil {
+				return 0, err
+			}
+		}
+
+		first, last := partition.sectorRange(start, stop)
+		sectors := make([]byte, last-first)
+
+		// Los sectores que se escriben en parte se descifran antes de modificarlos
+		for _, boundary := range []int64{first, last - CRYPT_SECTOR_SIZE} {
+			if boundary >= start && boundary+CRYPT_SECTOR_SIZE <= stop {
+				continue
+			}
+			sector := sectors[boundary-first : boundary-first+CRYPT_SECTOR_SIZE]
+			if _, err := d.BlockDevice.ReadAt(sector, boundary); err != nil {
+				return 0, err
+			}
+			partition.xts.decryptSector(sector, sector, partition.sectorNumber(boundary))
+		}
+
+		copy(sectors[start-first:stop-first], data[start-offset:stop-offset])
+		for i := int64(0); i < int64(len(sectors)); i += CRYPT_SECTOR_SIZE {
+			sector := sectors[i : i+CRYPT_SECTOR_SIZE]
+			partition.xts.encryptSector(sector, sector, partition.sectorNumber(first+i))
+		}
+		if _, err := d.BlockDevice.WriteAt(sectors, first); err != nil {
+			return 0, err
+		}
+
+		position = stop
+	}
+
+	if position < end {
+		if _, err := d.BlockDevice.WriteAt(data[position-offset:], position); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
@@ -0,0 +1,666 @@
+package estructuras
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Discos en formato qmia
+// Un disco qmia es un contenedor comprimido: en lugar de guardar todos los bytes del
+// disco, el archivo tiene una cabecera, una tabla con una entrada por cluster de 64KB
+// y los clusters comprimidos con deflate. Un cluster ocupa espacio en el archivo
+// recién cuando se escribe algo distinto de ceros, así que un disco casi vacío ocupa
+// unos pocos KB. El resto del código ve el disco a través de QmiaDevice y no
+// necesita saber en qué formato está (ver openDiskImage).
+//
+// Cuando un cluster reescrito ya no cabe en su lugar se agrega al final del archivo;
+// el espacio anterior queda sin usar hasta convertir el disco de nuevo (convertdisk).
+/*
+| Nombre         | Tipo    | Descripción                                                    |
+|----------------|---------|----------------------------------------------------------------|
+| magic          | char[8] | "QMIADISK"                                                     |
+| version        | uint32  | Versión del formato (1)                                        |
+| cluster_size   | uint32  | Bytes de cada cluster (65536)                                  |
+| disk_size      | int64   | Tamaño del disco en bytes                                      |
+| table_offset   | int64   | Posición de la tabla de clusters en el archivo                 |
+| table_capacity | int64   | Entradas que caben en la tabla (crece al agrandar el disco)    |
+| (relleno)      | byte[]  | Ceros hasta completar QMIA_HEADER_SIZE                         |
+
+Cada entrada de la tabla:
+| offset         | int64   | Posición del cluster en el archivo                             |
+| length         | uint32  | Bytes guardados: 0 son ceros, cluster_size sin comprimir       |
+| capacity       | uint32  | Bytes reservados en offset; 0 si el cluster nunca se escribió  |
+*/
+
+// Formatos de los discos
+const (
+	DISK_FORMAT_RAW  = "raw"  // Archivo con todos los bytes del disco
+	DISK_FORMAT_QMIA = "qmia" // Contenedor con clusters comprimidos
+)
+
+// Tamaños del formato qmia
+const (
+	QMIA_CLUSTER_SIZE int64 = 64 * 1024
+	QMIA_HEADER_SIZE  int64 = 512
+)
+
+// Firma y versión del formato
+var qmiaMagic = [8]byte{'Q', 'M', 'I', 'A', 'D', 'I', 'S', 'K'}
+
+const qmiaVersion = 1
+
+// Los clusters que se agregan al final reservan un múltiplo de este tamaño para poder crecer en su lugar
+const qmiaAllocationUnit = 4096
+
+// qmiaHeader es la cabecera del contenedor
+type qmiaHeader struct {
+	Magic         [8]byte `binary:"little"`
+	Version       uint32  `binary:"little"`
+	ClusterSize   uint32  `binary:"little"`
+	DiskSize      int64   `binary:"little"`
+	TableOffset   int64   `binary:"little"`
+	TableCapacity int64   `binary:"little"`
+}
+
+// qmiaEntry es la entrada de un cluster en la tabla
+type qmiaEntry struct {
+	Offset   int64  `binary:"little"`
+	Length   uint32 `binary:"little"`
+	Capacity uint32 `binary:"little"`
+}
+
+// Tamaño serializado de una entrada
+var qmiaEntrySize = int64(binary.Size(qmiaEntry{}))
+
+// qmiaClusters retorna la cantidad de clusters de un disco del tamaño indicado
+func qmiaClusters(diskSize int64) int64 {
+	return (diskSize + QMIA_CLUSTER_SIZE - 1) / QMIA_CLUSTER_SIZE
+}
+
+// CreateQmiaDisk crea un disco qmia vacío (todo ceros) en un archivo nuevo
+func CreateQmiaDisk(path string, size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("el tamaño del disco debe ser mayor que cero")
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("error al crear el archivo: %v", err)
+	}
+	defer file.Close()
+
+	header := qmiaHeader{
+		Magic:         qmiaMagic,
+		Version:       qmiaVersion,
+		ClusterSize:   uint32(QMIA_CLUSTER_SIZE),
+		DiskSize:      size,
+		TableOffset:   QMIA_HEADER_SIZE,
+		TableCapacity: qmiaClusters(size),
+	}
+	if err := writeQmiaHeader(file, &header); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	// La tabla vacía son ceros: basta con fijar el tamaño del archivo
+	if err := file.Truncate(header.TableOffset + header.TableCapacity*qmiaEntrySize); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("error al crear la tabla de clusters: %v", err)
+	}
+
+	return nil
+}
+
+// writeQmiaHeader escribe la cabecera al inicio del archivo
+func writeQmiaHeader(file *os.File, header *qmiaHeader) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("error al serializar la cabecera qmia: %v", err)
+	}
+	data := make([]byte, QMIA_HEADER_SIZE)
+	copy(data, buf.Bytes())
+
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("error al escribir la cabecera qmia: %v", err)
+	}
+	return nil
+}
+
+// QmiaDevice es un dispositivo respaldado por un archivo qmia
+// Guarda la tabla en memoria y el último cluster usado ya descomprimido
+type QmiaDevice struct {
+	mutex   sync.Mutex
+	file    *os.File
+	header  qmiaHeader
+	table   []qmiaEntry
+	fileEnd int64 // Donde se agrega el siguiente cluster
+
+	cacheIndex int64 // Cluster en cache, -1 si no hay
+	cache      []byte
+}
+
+// openQmiaDevice lee la cabecera y la tabla de un archivo qmia ya abierto
+func openQmiaDevice(file *os.File) (*QmiaDevice, error) {
+	data := make([]byte, binary.Size(qmiaHeader{}))
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return nil, fmt.Errorf("error al leer la cabecera qmia: %v", err)
+	}
+
+	device := &QmiaDevice{file: file, cacheIndex: -1}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &device.header); err != nil {
+		return nil, fmt.Errorf("error al leer la cabecera qmia: %v", err)
+	}
+
+	header := &device.header
+	if header.Version != qmiaVersion || int64(header.ClusterSize) != QMIA_CLUSTER_SIZE {
+		return nil, fmt.Errorf("formato qmia no soportado (versión %d, cluster de %d bytes)", header.Version, header.ClusterSize)
+	}
+	clusters := qmiaClusters(header.DiskSize)
+	if header.DiskSize <= 0 || header.TableOffset < QMIA_HEADER_SIZE || header.TableCapacity < clusters {
+		return nil, fmt.Errorf("cabecera qmia inválida (disco de %d bytes, tabla en %d con %d entradas)",
+			header.DiskSize, header.TableOffset, header.TableCapacity)
+	}
+
+	raw := make([]byte, clusters*qmiaEntrySize)
+	if _, err := file.ReadAt(raw, header.TableOffset); err != nil {
+		return nil, fmt.Errorf("error al leer la tabla de clusters: %v", err)
+	}
+	device.table = make([]qmiaEntry, clusters)
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, device.table); err != nil {
+		return nil, fmt.Errorf("error al leer la tabla de clusters: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el archivo qmia: %v", err)
+	}
+	device.fileEnd = info.Size()
+	if tableEnd := header.TableOffset + header.TableCapacity*qmiaEntrySize; device.fileEnd < tableEnd {
+		device.fileEnd = tableEnd
+	}
+
+	return device, nil
+}
+
+// ReadAt lee del disco descomprimiendo los clusters; si el rango pasa del final retorna lo leído e io.EOF
+func (d *QmiaDevice) ReadAt(data []byte, offset int64) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if offset < 0 {
+		return 0, fmt.Errorf("posición negativa: %d", offset)
+	}
+	if offset >= d.header.DiskSize {
+		return 0, io.EOF
+	}
+
+	end := offset + int64(len(data))
+	if end > d.header.DiskSize {
+		end = d.header.DiskSize
+	}
+
+	for position := offset; position < end; {
+		index := position / QMIA_CLUSTER_SIZE
+		cluster, err := d.loadCluster(index)
+		if err != nil {
+			return int(position - offset), err
+		}
+
+		within := position - index*QMIA_CLUSTER_SIZE
+		n := copy(data[position-offset:end-offset], cluster[within:])
+		position += int64(n)
+	}
+
+	if n := int(end - offset); n < len(data) {
+		return n, io.EOF
+	}
+	return len(data), nil
+}
+
+// WriteAt escribe en el disco comprimiendo los clusters modificados; el disco no crece
+func (d *QmiaDevice) WriteAt(data []byte, offset int64) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	end := offset + int64(len(data))
+	if offset < 0 || end > d.header.DiskSize {
+		return 0, fmt.Errorf("rango [%d, %d) fuera del dispositivo de %d bytes", offset, end, d.header.DiskSize)
+	}
+
+	for position := offset; position < end; {
+		index := position / QMIA_CLUSTER_SIZE
+		within := position - index*QMIA_CLUSTER_SIZE
+		chunk := QMIA_CLUSTER_SIZE - within
+		if chunk > end-position {
+			chunk = end - position
+		}
+
+		// Un cluster que se escribe en parte conserva el resto de su contenido
+		cluster := make([]byte, QMIA_CLUSTER_SIZE)
+		if chunk < QMIA_CLUSTER_SIZE {
+			current, err := d.loadCluster(index)
+			if err != nil {
+				return int(position - offset), err
+			}
+			copy(cluster, current)
+		}
+		copy(cluster[within:], data[position-offset:position-offset+chunk])
+
+		if err := d.storeCluster(index, cluster); err != nil {
+			return int(position - offset), err
+		}
+		position += chunk
+	}
+
+	return len(data), nil
+}
+
+// loadCluster retorna el contenido de un cluster (no se debe modificar)
+func (d *QmiaDevice) loadCluster(index int64) ([]byte, error) {
+	if d.cacheIndex == index {
+		return d.cache, nil
+	}
+
+	entry := d.table[index]
+	cluster := make([]byte, QMIA_CLUSTER_SIZE)
+
+	switch {
+	case entry.Length == 0:
+		// Cluster sin datos: ceros
+	case int64(entry.Length) == QMIA_CLUSTER_SIZE:
+		if _, err := d.file.ReadAt(cluster, entry.Offset); err != nil {
+			return nil, fmt.Errorf("error al leer el cluster %d: %v", index, err)
+		}
+	default:
+		stored := make([]byte, entry.Length)
+		if _, err := d.file.ReadAt(stored, entry.Offset); err != nil {
+			return nil, fmt.Errorf("error al leer el cluster %d: %v", index, err)
+		}
+		reader := flate.NewReader(bytes.NewReader(stored))
+		_, err := io.ReadFull(reader, cluster)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("el cluster %d está dañado: %v", index, err)
+		}
+	}
+
+	d.cacheIndex, d.cache = index, cluster
+	return cluster, nil
+}
+
+// storeCluster comprime y guarda un cluster completo, reutilizando su lugar si cabe
+func (d *QmiaDevice) storeCluster(index int64, cluster []byte) error {
+	entry := d.table[index]
+
+	// Un cluster de ceros no guarda datos, pero conserva su lugar para reescrituras
+	if isZeroBlock(cluster) {
+		d.cacheIndex, d.cache = index, cluster
+		if entry.Length == 0 {
+			return nil
+		}
+		entry.Length = 0
+		return d.writeEntry(index, entry)
+	}
+
+	stored, err := compressCluster(cluster)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(stored)) > int64(entry.Capacity) {
+		capacity := (int64(len(stored)) + qmiaAllocationUnit - 1) / qmiaAllocationUnit * qmiaAllocationUnit
+		if capacity > QMIA_CLUSTER_SIZE {
+			capacity = QMIA_CLUSTER_SIZE
+		}
+		entry.Offset, entry.Capacity = d.fileEnd, uint32(capacity)
+		d.fileEnd += capacity
+
+		// El archivo cubre todo el espacio reservado: al reabrirlo, fileEnd se toma de su tamaño
+		if err := d.file.Truncate(d.fileEnd); err != nil {
+			return fmt.Errorf("error al reservar espacio para el cluster %d: %v", index, err)
+		}
+	}
+	entry.Length = uint32(len(stored))
+
+	// Primero los datos y después la entrada que los apunta
+	if _, err := d.file.WriteAt(stored, entry.Offset); err != nil {
+		return fmt.Errorf("error al escribir el cluster %d: %v", index, err)
+	}
+	d.cacheIndex, d.cache = index, cluster
+	return d.writeEntry(index, entry)
+}
+
+// compressCluster comprime un cluster; si no se reduce se guarda sin comprimir
+func compressCluster(cluster []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer, err := flate.NewWriter(buf, flate.BestSpeed)
+	if err != nil {
+		return nil, fmt.Errorf("error al comprimir el cluster: %v", err)
+	}
+	if _, err := writer.Write(cluster); err != nil {
+		return nil, fmt.Errorf("error al comprimir el cluster: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error al comprimir el cluster: %v", err)
+	}
+
+	if int64(buf.Len()) >= QMIA_CLUSTER_SIZE {
+		return cluster, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// writeEntry actualiza la entrada de un cluster en memoria y en el archivo
+func (d *QmiaDevice) writeEntry(index int64, entry qmiaEntry) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, &entry); err != nil {
+		return fmt.Errorf("error al serializar la entrada del cluster %d: %v", index, err)
+	}
+	if _, err := d.file.WriteAt(buf.Bytes(), d.header.TableOffset+index*qmiaEntrySize); err != nil {
+		return fmt.Errorf("error al escribir la entrada del cluster %d: %v", index, err)
+	}
+
+	d.table[index] = entry
+	return nil
+}
+
+// Size retorna el tamaño del disco (no el del archivo)
+func (d *QmiaDevice) Size() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.header.DiskSize
+}
+
+// Resize cambia el tamaño del disco; el espacio agregado queda en ceros
+// Si la tabla no alcanza se copia al final del archivo con más capacidad
+func (d *QmiaDevice) Resize(size int64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if size <= 0 {
+		return fmt.Errorf("el tamaño del disco debe ser mayor que cero")
+	}
+
+	oldClusters := int64(len(d.table))
+	clusters := qmiaClusters(size)
+
+	// Al reducir, el final del último cluster queda en ceros para que vuelva a leerse así al crecer
+	if size < d.header.DiskSize {
+		if within := size % QMIA_CLUSTER_SIZE; within != 0 {
+			current, err := d.loadCluster(clusters - 1)
+			if err != nil {
+				return err
+			}
+			cluster := make([]byte, QMIA_CLUSTER_SIZE)
+			copy(cluster, current[:within])
+			if err := d.storeCluster(clusters-1, cluster); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Las entradas que se descartan o se agregan quedan vacías
+	resized := make([]qmiaEntry, clusters)
+	copy(resized, d.table)
+
+	header := d.header
+	header.DiskSize = size
+	if clusters > header.TableCapacity {
+		header.TableOffset, header.TableCapacity = d.fileEnd, clusters
+	}
+
+	buf := new(bytes.Buffer)
+	from := oldClusters
+	if header.TableOffset != d.header.TableOffset {
+		from = 0 // Tabla nueva: se escribe completa
+	}
+	if clusters < oldClusters {
+		from = clusters // Se limpian las entradas descartadas
+		resized = append(resized, make([]qmiaEntry, oldClusters-clusters)...)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, resized[from:]); err != nil {
+		return fmt.Errorf("error al serializar la tabla de clusters: %v", err)
+	}
+	if _, err := d.file.WriteAt(buf.Bytes(), header.TableOffset+from*qmiaEntrySize); err != nil {
+		return fmt.Errorf("error al escribir la tabla de clusters: %v", err)
+	}
+	if header.TableOffset == d.fileEnd {
+		d.fileEnd += header.TableCapacity * qmiaEntrySize
+	}
+
+	if err := writeQmiaHeader(d.file, &header); err != nil {
+		return err
+	}
+
+	d.header = header
+	d.table = resized[:clusters]
+	d.cacheIndex, d.cache = -1, nil
+	return nil
+}
+
+// Sync lleva las escrituras del archivo al disco físico
+func (d *QmiaDevice) Sync() error {
+	return d.file.Sync()
+}
+
+// Close cierra el archivo
+func (d *QmiaDevice) Close() error {
+	return d.file.Close()
+}
+
+// isZeroBlock indica si un bloque son solo ceros
+func isZeroBlock(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// openDiskImage abre el archivo de un disco en su formato: qmia si tiene la firma, raw si no
+func openDiskImage(path string) (BlockDevice, error) {
+	device, err := OpenFileDevice(path)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, len(qmiaMagic))
+	if n, _ := device.ReadAt(magic, 0); n < len(magic) || !bytes.Equal(magic, qmiaMagic[:]) {
+		return device, nil
+	}
+
+	qmia, err := openQmiaDevice(device.file)
+	if err != nil {
+		device.Close()
+		return nil, err
+	}
+	return qmia, nil
+}
+
+// DiskFormat retorna el formato del archivo de un disco (los discos en memoria son raw)
+func DiskFormat(path string) (string, error) {
+	if IsMemoryDisk(path) {
+		return DISK_FORMAT_RAW, nil
+	}
+
+	device, err := openDiskImage(path)
+	if err != nil {
+		return "", err
+	}
+	defer device.Close()
+
+	if _, isQmia := device.(*QmiaDevice); isQmia {
+		return DISK_FORMAT_QMIA, nil
+	}
+	return DISK_FORMAT_RAW, nil
+}
+
+// ResizeQmiaDisk cambia el tamaño de un disco qmia
+func ResizeQmiaDisk(path string, size int64) error {
+	// El dispositivo abierto tiene la tabla anterior en memoria
+	CloseDevice(path)
+
+	device, err := openDiskImage(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	qmia, isQmia := device.(*QmiaDevice)
+	if !isQmia {
+		return fmt.Errorf("el disco %s no está en formato qmia", path)
+	}
+	return qmia.Resize(size)
+}
+
+// DiskConversion es el resultado de convertir un disco de formato
+type DiskConversion struct {
+	Path        string `json:"path"`          // Ruta del disco
+	From        string `json:"from"`          // Formato anterior
+	To          string `json:"to"`            // Formato nuevo
+	DiskSize    int64  `json:"disk_size"`     // Tamaño del disco en bytes
+	OldFileSize int64  `json:"old_file_size"` // Bytes del archivo antes de convertirlo
+	NewFileSize int64  `json:"new_file_size"` // Bytes del archivo convertido
+	DataBytes   int64  `json:"data_bytes"`    // Bytes con datos copiados (los bloques de ceros se omiten)
+}
+
+// ConvertDisk cambia el formato del archivo de un disco
+// Se escribe el disco en un archivo temporal que luego reemplaza al original. El
+// contenido se copia en crudo, incluidas las particiones cifradas, y convertir un
+// disco qmia a qmia lo compacta (descarta el espacio de los clusters reubicados).
+func ConvertDisk(path, format string) (*DiskConversion, error) {
+	if format != DISK_FORMAT_RAW && format != DISK_FORMAT_QMIA {
+		return nil, fmt.Errorf("formato no válido '%s', use %s o %s", format, DISK_FORMAT_RAW, DISK_FORMAT_QMIA)
+	}
+	if IsMemoryDisk(path) {
+		return nil, fmt.Errorf("los discos en memoria no se pueden convertir de formato")
+	}
+
+	// Las capas de los snapshots se guardan sobre el disco y no se convierten
+	if HasSnapshots(path) {
+		return nil, fmt.Errorf("el disco tiene snapshots, elimínelos antes de convertirlo")
+	}
+
+	oldInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el disco: %v", err)
+	}
+
+	from, err := DiskFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	if from == DISK_FORMAT_RAW && format == DISK_FORMAT_RAW {
+		return nil, fmt.Errorf("el disco ya está en formato %s", format)
+	}
+
+	temporary := path + ".convert"
+	if _, err := os.Stat(temporary); err == nil {
+		return nil, fmt.Errorf("el archivo temporal ya existe: %s", temporary)
+	}
+
+	source, release, err := acquireDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	diskSize := source.Size()
+
+	dest, err := createDiskImage(temporary, diskSize, format)
+	if err != nil {
+		return nil, err
+	}
+
+	dataBytes, err := copyDeviceData(source, dest, diskSize)
+	if err == nil {
+		err = dest.Sync()
+	}
+	dest.Close()
+	if err != nil {
+		os.Remove(temporary)
+		return nil, err
+	}
+
+	// El dispositivo abierto apunta al archivo anterior
+	CloseDevice(path)
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return nil, fmt.Errorf("error al reemplazar el disco: %v", err)
+	}
+
+	newInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el disco convertido: %v", err)
+	}
+
+	return &DiskConversion{
+		Path:        path,
+		From:        from,
+		To:          format,
+		DiskSize:    diskSize,
+		OldFileSize: oldInfo.Size(),
+		NewFileSize: newInfo.Size(),
+		DataBytes:   dataBytes,
+	}, nil
+}
+
+// createDiskImage crea el archivo de un disco vacío en el formato indicado y lo abre
+func createDiskImage(path string, size int64, format string) (BlockDevice, error) {
+	if format == DISK_FORMAT_QMIA {
+		if err := CreateQmiaDisk(path, size); err != nil {
+			return nil, err
+		}
+	} else {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error al crear el archivo: %v", err)
+		}
+		err = file.Truncate(size)
+		file.Close()
+		if err != nil {
+			os.Remove(path)
+			return nil, fmt.Errorf("error al establecer el tamaño del disco: %v", err)
+		}
+	}
+
+	device, err := openDiskImage(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return device, nil
+}
+
+// copyDeviceData copia un dispositivo a otro por clusters, sin escribir los que son solo ceros
+// El destino debe estar en ceros; retorna los bytes con datos que se copiaron
+func copyDeviceData(source, dest BlockDevice, size int64) (int64, error) {
+	var copied int64
+	for offset := int64(0); offset < size; offset += QMIA_CLUSTER_SIZE {
+		chunk := QMIA_CLUSTER_SIZE
+		if size-offset < chunk {
+			chunk = size - offset
+		}
+
+		data, err := ReadFromDevice(source, offset, int(chunk))
+		if err != nil {
+			return copied, err
+		}
+		if isZeroBlock(data) {
+			continue
+		}
+
+		if err := WriteToDevice(dest, data, offset); err != nil {
+			return copied, err
+		}
+		copied += chunk
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,674 @@
+package estructuras
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Snapshots copy-on-write de un disco
+// Un snapshot congela el estado del disco sin copiarlo: a partir de su creación las
+// escrituras van a una capa (overlay) nueva y el archivo del disco y las capas
+// anteriores ya no se modifican. Las lecturas toman cada bloque de la capa más
+// reciente que lo tenga, o del archivo del disco si ninguna lo tiene.
+//
+// Las capas se guardan junto al disco, en el directorio <disco>.snap:
+//   snapshots.json  Índice con los snapshots en orden de creación
+//   <nombre>.cow    Capa con los bloques escritos después del snapshot <nombre>
+//
+// Restaurar un snapshot descarta su capa (y los snapshots posteriores); eliminarlo
+// combina su capa con la anterior (o con el archivo del disco), sin perder datos.
+/*
+| Nombre      | Tipo     | Descripción                                                  |
+|-------------|----------|--------------------------------------------------------------|
+| magic       | char[8]  | "MIASNAP1"                                                   |
+| block_size  | uint32   | Tamaño de bloque de la capa (SNAPSHOT_BLOCK_SIZE)            |
+| reserved    | uint32   | Sin uso                                                      |
+| disk_size   | int64    | Tamaño del disco al crear la capa                            |
+| bitmap_size | int64    | Bytes del bitmap de bloques (un bit por bloque del disco)    |
+| bitmap      | byte[]   | Bloques presentes en la capa                                 |
+| datos       | byte[]   | Bloque N en data_offset + N*block_size (archivo disperso)    |
+*/
+
+// Tamaño de bloque de las capas de los snapshots
+const SNAPSHOT_BLOCK_SIZE int64 = 4096
+
+// Firma de las capas de los snapshots
+var snapshotLayerMagic = [8]byte{'M', 'I', 'A', 'S', 'N', 'A', 'P', '1'}
+
+// Nombres válidos para un snapshot
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Nombre del índice dentro del directorio de snapshots
+const snapshotIndexFile = "snapshots.json"
+
+// snapshotLayerHeader es la cabecera de una capa
+type snapshotLayerHeader struct {
+	Magic      [8]byte `binary:"little"`
+	BlockSize  uint32  `binary:"little"`
+	Reserved   uint32  `binary:"little"`
+	DiskSize   int64   `binary:"little"`
+	BitmapSize int64   `binary:"little"`
+}
+
+// SnapshotInfo describe un snapshot del disco
+type SnapshotInfo struct {
+	Name      string `json:"name"`       // Nombre del snapshot
+	CreatedAt string `json:"created_at"` // Fecha de creación (RFC3339)
+	Layer     string `json:"layer"`      // Archivo de la capa dentro del directorio de snapshots
+	Bytes     int64  `json:"bytes"`      // Bytes escritos después del snapshot (guardados en su capa)
+	Active    bool   `json:"active"`     // Es el snapshot más reciente (su capa recibe las escrituras)
+}
+
+// snapshotIndex es el contenido de snapshots.json
+type snapshotIndex struct {
+	DiskSize  int64          `json:"disk_size"`
+	Snapshots []SnapshotInfo `json:"snapshots"`
+}
+
+// snapshotDir retorna el directorio de snapshots de un disco
+func snapshotDir(path string) string {
+	return path + ".snap"
+}
+
+// HasSnapshots indica si el disco tiene snapshots
+func HasSnapshots(path string) bool {
+	if IsMemoryDisk(path) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(snapshotDir(path), snapshotIndexFile))
+	return err == nil
+}
+
+// RemoveSnapshots elimina todos los snapshots del disco sin combinarlos (al eliminar el disco)
+func RemoveSnapshots(path string) error {
+	if IsMemoryDisk(path) {
+		return nil
+	}
+
+	// El dispositivo abierto tiene las capas abiertas
+	CloseDevice(path)
+
+	if err := os.RemoveAll(snapshotDir(path)); err != nil {
+		return fmt.Errorf("error al eliminar los snapshots del disco: %v", err)
+	}
+	return nil
+}
+
+// loadSnapshotIndex lee el índice de snapshots del disco, o nil si no tiene
+func loadSnapshotIndex(path string) (*snapshotIndex, error) {
+	if IsMemoryDisk(path) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(snapshotDir(path), snapshotIndexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al leer los snapshots del disco: %v", err)
+	}
+
+	index := &snapshotIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("índice de snapshots dañado: %v", err)
+	}
+
+	// Si no hay snapshots el disco se lee directamente
+	if len(index.Snapshots) == 0 {
+		return nil, nil
+	}
+
+	return index, nil
+}
+
+// saveSnapshotIndex escribe el índice de snapshots (o elimina el directorio si ya no hay)
+func saveSnapshotIndex(path string, index *snapshotIndex) error {
+	if len(index.Snapshots) == 0 {
+		return RemoveSnapshots(path)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error al serializar los snapshots: %v", err)
+	}
+
+	// Escribir en un archivo temporal y renombrar para no dejar un índice a medias
+	indexPath := filepath.Join(snapshotDir(path), snapshotIndexFile)
+	if err := os.WriteFile(indexPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("error al escribir los snapshots: %v", err)
+	}
+	if err := os.Rename(indexPath+".tmp", indexPath); err != nil {
+		return fmt.Errorf("error al escribir los snapshots: %v", err)
+	}
+
+	return nil
+}
+
+// find retorna la posición de un snapshot en el índice, o -1
+func (idx *snapshotIndex) find(name string) int {
+	for i, snapshot := range idx.Snapshots {
+		if snapshot.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// layerPath retorna la ruta de la capa del snapshot i
+func (idx *snapshotIndex) layerPath(path string, i int) string {
+	return filepath.Join(snapshotDir(path), idx.Snapshots[i].Layer)
+}
+
+// snapshotLayer es una capa abierta de un snapshot
+type snapshotLayer struct {
+	file       *os.File
+	bitmap     []byte
+	dataOffset int64
+}
+
+// layerDataOffset calcula la posición de los datos: después de la cabecera y el bitmap, alineada a un bloque
+func layerDataOffset(bitmapSize int64) int64 {
+	headerEnd := int64(binary.Size(snapshotLayerHeader{})) + bitmapSize
+	return (headerEnd + SNAPSHOT_BLOCK_SIZE - 1) / SNAPSHOT_BLOCK_SIZE * SNAPSHOT_BLOCK_SIZE
+}
+
+// createSnapshotLayer crea (o vacía) la capa de un snapshot
+func createSnapshotLayer(layerPath string, diskSize int64) error {
+	blocks := (diskSize + SNAPSHOT_BLOCK_SIZE - 1) / SNAPSHOT_BLOCK_SIZE
+	header := snapshotLayerHeader{
+		Magic:      snapshotLayerMagic,
+		BlockSize:  uint32(SNAPSHOT_BLOCK_SIZE),
+		DiskSize:   diskSize,
+		BitmapSize: (blocks + 7) / 8,
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("error al serializar la capa del snapshot: %v", err)
+	}
+	buf.Write(make([]byte, header.BitmapSize))
+
+	if err := os.WriteFile(layerPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error al crear la capa del snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// openSnapshotLayer abre una capa y carga su bitmap
+func openSnapshotLayer(layerPath string, writable bool, diskSize int64) (*snapshotLayer, error) {
+	flag := os.O_RDONLY
+	if writable {
+		flag = os.O_RDWR
+	}
+
+	file, err := os.OpenFile(layerPath, flag, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir la capa del snapshot: %v", err)
+	}
+
+	header := &snapshotLayerHeader{}
+	if err := binary.Read(file, binary.LittleEndian, header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error al leer la capa del snapshot %s: %v", filepath.Base(layerPath), err)
+	}
+
+	if header.Magic != snapshotLayerMagic || int64(header.BlockSize) != SNAPSHOT_BLOCK_SIZE || header.DiskSize != diskSize {
+		file.Close()
+		return nil, fmt.Errorf("la capa del snapshot %s no corresponde a este disco", filepath.Base(layerPath))
+	}
+
+	layer := &snapshotLayer{
+		file:       file,
+		bitmap:     make([]byte, header.BitmapSize),
+		dataOffset: layerDataOffset(header.BitmapSize),
+	}
+	if _, err := file.ReadAt(layer.bitmap, int64(binary.Size(snapshotLayerHeader{}))); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error al leer el bitmap de la capa %s: %v", filepath.Base(layerPath), err)
+	}
+
+	return layer, nil
+}
+
+// has indica si la capa tiene el bloque
+func (l *snapshotLayer) has(block int64) bool {
+	return l.bitmap[block/8]&(1<<uint(block%8)) != 0
+}
+
+// set marca el bloque como presente en la capa
+func (l *snapshotLayer) set(block int64) {
+	l.bitmap[block/8] |= 1 << uint(block%8)
+}
+
+// blockCount retorna la cantidad de bloques presentes en la capa
+func (l *snapshotLayer) blockCount() int64 {
+	count := int64(0)
+	for _, b := range l.bitmap {
+		for ; b != 0; b &= b - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// readAt lee datos de un bloque presente en la capa
+func (l *snapshotLayer) readAt(data []byte, offset int64) error {
+	if _, err := l.file.ReadAt(data, l.dataOffset+offset); err != nil {
+		return fmt.Errorf("error al leer la capa del snapshot: %v", err)
+	}
+	return nil
+}
+
+// writeAt escribe datos en la capa (sin actualizar el bitmap)
+func (l *snapshotLayer) writeAt(data []byte, offset int64) error {
+	if _, err := l.file.WriteAt(data, l.dataOffset+offset); err != nil {
+		return fmt.Errorf("error al escribir en la capa del snapshot: %v", err)
+	}
+	return nil
+}
+
+// saveBitmap escribe en la capa los bytes del bitmap entre dos bloques
+func (l *snapshotLayer) saveBitmap(firstBlock, lastBlock int64) error {
+	first, last := firstBlock/8, lastBlock/8
+	if _, err := l.file.WriteAt(l.bitmap[first:last+1], int64(binary.Size(snapshotLayerHeader{}))+first); err != nil {
+		return fmt.Errorf("error al escribir el bitmap de la capa: %v", err)
+	}
+	return nil
+}
+
+// snapshotStack son las capas abiertas de un disco, de la más antigua a la más reciente
+type snapshotStack struct {
+	base   BlockDevice // Archivo del disco, raw o qmia
+	layers []*snapshotLayer
+}
+
+// openSnapshotStack abre el disco y las capas de sus snapshots; la última capa se abre para escritura
+func openSnapshotStack(path string, index *snapshotIndex, writable bool) (*snapshotStack, error) {
+	base, err := openDiskImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := &snapshotStack{base: base}
+	for i := range index.Snapshots {
+		layer, err := openSnapshotLayer(index.layerPath(path, i), writable && i == len(index.Snapshots)-1, index.DiskSize)
+		if err != nil {
+			stack.Close()
+			return nil, err
+		}
+		stack.layers = append(stack.layers, layer)
+	}
+
+	return stack, nil
+}
+
+// Close cierra el disco y las capas
+func (s *snapshotStack) Close() {
+	s.base.Close()
+	for _, layer := range s.layers {
+		layer.file.Close()
+	}
+}
+
+// readRange lee un rango que no cruza bloques usando solo las capas hasta la indicada (exclusiva)
+func (s *snapshotStack) readRange(data []byte, offset int64, upTo int) error {
+	block := offset / SNAPSHOT_BLOCK_SIZE
+	for i := upTo - 1; i >= 0; i-- {
+		if s.layers[i].has(block) {
+			return s.layers[i].readAt(data, offset)
+		}
+	}
+
+	if _, err := s.base.ReadAt(data, offset); err != nil {
+		return fmt.Errorf("error al leer del disco: %v", err)
+	}
+	return nil
+}
+
+// forEachBlock recorre un rango del disco dividiéndolo en trozos que no cruzan bloques
+func forEachBlock(offset int64, size int, fn func(chunkOffset int64, from, to int) error) error {
+	for done := 0; done < size; {
+		chunkOffset := offset + int64(done)
+		chunk := int(SNAPSHOT_BLOCK_SIZE - chunkOffset%SNAPSHOT_BLOCK_SIZE)
+		if chunk > size-done {
+			chunk = size - done
+		}
+		if err := fn(chunkOffset, done, done+chunk); err != nil {
+			return err
+		}
+		done += chunk
+	}
+	return nil
+}
+
+// snapshotDevice es el dispositivo de un disco con snapshots
+// Las lecturas toman cada bloque de la capa más reciente que lo tenga y las escrituras
+// van a la capa del snapshot más reciente
+type snapshotDevice struct {
+	stack    *snapshotStack
+	diskSize int64
+}
+
+// openSnapshotDevice abre el disco y sus capas como un dispositivo
+func openSnapshotDevice(path string, index *snapshotIndex) (*snapshotDevice, error) {
+	stack, err := openSnapshotStack(path, index, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotDevice{stack: stack, diskSize: index.DiskSize}, nil
+}
+
+// ReadAt lee un rango del disco a través de sus snapshots
+func (d *snapshotDevice) ReadAt(data []byte, offset int64) (int, error) {
+	if offset < 0 || offset+int64(len(data)) > d.diskSize {
+		return 0, fmt.Errorf("rango [%d, %d) fuera del disco", offset, offset+int64(len(data)))
+	}
+
+	err := forEachBlock(offset, len(data), func(chunkOffset int64, from, to int) error {
+		return d.stack.readRange(data[from:to], chunkOffset, len(d.stack.layers))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// WriteAt escribe un rango del disco en la capa del snapshot más reciente
+func (d *snapshotDevice) WriteAt(data []byte, offset int64) (int, error) {
+	if offset < 0 || offset+int64(len(data)) > d.diskSize {
+		return 0, fmt.Errorf("rango [%d, %d) fuera del disco", offset, offset+int64(len(data)))
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	stack := d.stack
+	top := stack.layers[len(stack.layers)-1]
+	err := forEachBlock(offset, len(data), func(chunkOffset int64, from, to int) error {
+		block := chunkOffset / SNAPSHOT_BLOCK_SIZE
+		blockStart := block * SNAPSHOT_BLOCK_SIZE
+		if top.has(block) || int64(to-from) == SNAPSHOT_BLOCK_SIZE {
+			return top.writeAt(data[from:to], chunkOffset)
+		}
+
+		// Copiar el bloque completo desde las capas anteriores antes de modificarlo
+		blockSize := SNAPSHOT_BLOCK_SIZE
+		if blockStart+blockSize > d.diskSize {
+			blockSize = d.diskSize - blockStart
+		}
+		blockData := make([]byte, blockSize)
+		if err := stack.readRange(blockData, blockStart, len(stack.layers)-1); err != nil {
+			return err
+		}
+		copy(blockData[chunkOffset-blockStart:], data[from:to])
+		return top.writeAt(blockData, blockStart)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// El bitmap se actualiza después de los datos
+	firstBlock := offset / SNAPSHOT_BLOCK_SIZE
+	lastBlock := (offset + int64(len(data)) - 1) / SNAPSHOT_BLOCK_SIZE
+	for block := firstBlock; block <= lastBlock; block++ {
+		top.set(block)
+	}
+	if err := top.saveBitmap(firstBlock, lastBlock); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// Size retorna el tamaño del disco al crear los snapshots
+func (d *snapshotDevice) Size() int64 {
+	return d.diskSize
+}
+
+// Sync lleva al disco físico las escrituras de la capa activa
+func (d *snapshotDevice) Sync() error {
+	return d.stack.layers[len(d.stack.layers)-1].file.Sync()
+}
+
+// Close cierra el disco y las capas
+func (d *snapshotDevice) Close() error {
+	d.stack.Close()
+	return nil
+}
+
+// CreateSnapshot crea un snapshot del estado actual del disco
+func CreateSnapshot(path, name string) (*SnapshotInfo, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("nombre de snapshot inválido: '%s' (use hasta 32 letras, números, '_' o '-')", name)
+	}
+
+	if IsMemoryDisk(path) {
+		return nil, fmt.Errorf("los discos en memoria no admiten snapshots")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("el archivo del disco no existe: %v", err)
+	}
+
+	// Las escrituras siguientes deben ir a la capa nueva
+	CloseDevice(path)
+
+	index, err := loadSnapshotIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		diskSize, err := DiskSize(path)
+		if err != nil {
+			return nil, err
+		}
+		index = &snapshotIndex{DiskSize: diskSize}
+	}
+
+	if index.find(name) != -1 {
+		return nil, fmt.Errorf("ya existe un snapshot con el nombre '%s'", name)
+	}
+
+	if err := os.MkdirAll(snapshotDir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error al crear el directorio de snapshots: %v", err)
+	}
+
+	snapshot := SnapshotInfo{
+		Name:      name,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Layer:     name + ".cow",
+	}
+	if err := createSnapshotLayer(filepath.Join(snapshotDir(path), snapshot.Layer), index.DiskSize); err != nil {
+		return nil, err
+	}
+
+	index.Snapshots = append(index.Snapshots, snapshot)
+	if err := saveSnapshotIndex(path, index); err != nil {
+		return nil, err
+	}
+
+	snapshot.Active = true
+	return &snapshot, nil
+}
+
+// ListSnapshots retorna los snapshots del disco en orden de creación
+func ListSnapshots(path string) ([]SnapshotInfo, error) {
+	if !DiskExists(path) {
+		return nil, fmt.Errorf("el disco no existe: %s", path)
+	}
+
+	index, err := loadSnapshotIndex(path)
+	if err != nil || index == nil {
+		return []SnapshotInfo{}, err
+	}
+
+	snapshots := make([]SnapshotInfo, 0, len(index.Snapshots))
+	for i, snapshot := range index.Snapshots {
+		layer, err := openSnapshotLayer(index.layerPath(path, i), false, index.DiskSize)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Bytes = layer.blockCount() * SNAPSHOT_BLOCK_SIZE
+		layer.file.Close()
+
+		snapshot.Active = i == len(index.Snapshots)-1
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot regresa el disco al estado del snapshot
+// Se descartan los cambios posteriores y los snapshots creados después; el snapshot se conserva
+// para poder restaurarlo de nuevo. Retorna los nombres de los snapshots descartados.
+func RestoreSnapshot(path, name string) ([]string, error) {
+	// Las capas cambian por fuera del dispositivo abierto
+	CloseDevice(path)
+
+	index, err := loadSnapshotIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	position := -1
+	if index != nil {
+		position = index.find(name)
+	}
+	if position == -1 {
+		return nil, fmt.Errorf("no existe un snapshot con el nombre '%s'", name)
+	}
+
+	discarded := []string{}
+	for i := position + 1; i < len(index.Snapshots); i++ {
+		if err := os.Remove(index.layerPath(path, i)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error al eliminar la capa del snapshot '%s': %v", index.Snapshots[i].Name, err)
+		}
+		discarded = append(discarded, index.Snapshots[i].Name)
+	}
+	index.Snapshots = index.Snapshots[:position+1]
+
+	// Vaciar la capa del snapshot: el disco queda como estaba al crearlo
+	if err := createSnapshotLayer(index.layerPath(path, position), index.DiskSize); err != nil {
+		return nil, err
+	}
+
+	if err := saveSnapshotIndex(path, index); err != nil {
+		return nil, err
+	}
+
+	// El contenido cambió sin pasar por WriteToDisk
+	if err := refreshChecksums(path); err != nil {
+		return nil, err
+	}
+
+	return discarded, nil
+}
+
+// DeleteSnapshot elimina un snapshot conservando el estado actual del disco
+// Su capa se combina con la del snapshot anterior, o con el archivo del disco si es el más antiguo.
+// Retorna la cantidad de bytes combinados.
+func DeleteSnapshot(path, name string) (int64, error) {
+	// Las capas cambian por fuera del dispositivo abierto
+	CloseDevice(path)
+
+	index, err := loadSnapshotIndex(path)
+	if err != nil {
+		return 0, err
+	}
+
+	position := -1
+	if index != nil {
+		position = index.find(name)
+	}
+	if position == -1 {
+		return 0, fmt.Errorf("no existe un snapshot con el nombre '%s'", name)
+	}
+
+	merged, err := mergeSnapshotLayer(path, index, position)
+	if err != nil {
+		return 0, err
+	}
+
+	layerPath := index.layerPath(path, position)
+	index.Snapshots = append(index.Snapshots[:position], index.Snapshots[position+1:]...)
+	if err := saveSnapshotIndex(path, index); err != nil {
+		return 0, err
+	}
+
+	if err := os.Remove(layerPath); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("error al eliminar la capa del snapshot: %v", err)
+	}
+
+	return merged, nil
+}
+
+// mergeSnapshotLayer copia los bloques de la capa del snapshot indicado a la capa anterior (o al disco)
+func mergeSnapshotLayer(path string, index *snapshotIndex, position int) (int64, error) {
+	layer, err := openSnapshotLayer(index.layerPath(path, position), false, index.DiskSize)
+	if err != nil {
+		return 0, err
+	}
+	defer layer.file.Close()
+
+	var target *snapshotLayer
+	var base BlockDevice
+	if position > 0 {
+		target, err = openSnapshotLayer(index.layerPath(path, position-1), true, index.DiskSize)
+		if err != nil {
+			return 0, err
+		}
+		defer target.file.Close()
+	} else {
+		base, err = openDiskImage(path)
+		if err != nil {
+			return 0, err
+		}
+		defer base.Close()
+	}
+
+	blocks := (index.DiskSize + SNAPSHOT_BLOCK_SIZE - 1) / SNAPSHOT_BLOCK_SIZE
+	merged := int64(0)
+	for block := int64(0); block < blocks; block++ {
+		if !layer.has(block) {
+			continue
+		}
+
+		offset := block * SNAPSHOT_BLOCK_SIZE
+		size := SNAPSHOT_BLOCK_SIZE
+		if offset+size > index.DiskSize {
+			size = index.DiskSize - offset
+		}
+
+		data := make([]byte, size)
+		if err := layer.readAt(data, offset); err != nil {
+			return merged, err
+		}
+
+		if target != nil {
+			if err := target.writeAt(data, offset); err != nil {
+				return merged, err
+			}
+			target.set(block)
+		} else if _, err := base.WriteAt(data, offset); err != nil {
+			return merged, fmt.Errorf("error al escribir en el disco: %v", err)
+		}
+		merged += size
+	}
+
+	if target != nil && blocks > 0 {
+		if err := target.saveBitmap(0, blocks-1); err != nil {
+			return merged, err
+		}
+	}
+
+	return merged, nil
+}
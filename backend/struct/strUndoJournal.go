@@ -0,0 +1,410 @@
+package estructuras
+
+import (
+	utils "backend/Utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Diario para deshacer cambios estructurales
+// Mientras se ejecuta un comando que modifica la estructura del disco (fdisk, mount,
+// mkfs) cada escritura con WriteToDisk guarda antes el contenido que va a
+// sobrescribir. Al terminar el comando con éxito esas imágenes previas se guardan
+// como una entrada del diario del disco, junto con la línea de comando. Deshacer una
+// entrada reescribe las imágenes previas en orden inverso. Las imágenes se guardan
+// en crudo, así que de una partición cifrada el diario solo guarda texto cifrado.
+//
+// Cada rango guarda también el SHA-256 de su contenido después del comando: si algo
+// modificó esos bytes después (un comando que no se registra, restaurar un snapshot),
+// la entrada ya no se puede deshacer con seguridad y undo se detiene. No basta un
+// CRC32: el MBR y su copia de respaldo guardan el CRC32 de sus datos en el mismo
+// sector, así que todo sector válido tiene el mismo CRC32. Los comandos que reescriben
+// el disco sin registrar sus cambios además descartan el diario (RemoveUndoJournal).
+//
+// El diario se guarda junto al disco, en el directorio <disco>.undo:
+//   journal.json  Índice con las entradas, de la más antigua a la más reciente
+//   <id>.img      Imágenes previas de la entrada, concatenadas en el orden de sus rangos
+
+// Límites del diario de cada disco; al superarlos se descartan las entradas más antiguas
+const (
+	UNDO_JOURNAL_MAX_SIZE    int64 = 16 * 1024 * 1024 // Bytes de imágenes previas
+	UNDO_JOURNAL_MAX_ENTRIES int   = 32               // Cantidad de entradas
+)
+
+// Nombre del índice dentro del directorio del diario
+const undoJournalIndexFile = "journal.json"
+
+// UndoRange es un rango del disco sobrescrito por un comando
+type UndoRange struct {
+	Offset    int64  `json:"offset"`       // Byte del disco donde inicia el rango
+	Length    int64  `json:"length"`       // Bytes del rango
+	AfterHash string `json:"after_sha256"` // SHA-256 del rango al terminar el comando (hexadecimal)
+}
+
+// UndoEntry es una entrada del diario: los cambios de un comando sobre un disco
+type UndoEntry struct {
+	ID        int64       `json:"id"`               // Identificador de la entrada
+	Command   string      `json:"command"`          // Línea de comando que hizo los cambios
+	CreatedAt string      `json:"created_at"`       // Fecha del comando (RFC3339)
+	Bytes     int64       `json:"bytes"`            // Bytes de imágenes previas guardados
+	Ranges    []UndoRange `json:"ranges"`           // Rangos sobrescritos, en orden de escritura
+	Mounts    []string    `json:"mounts,omitempty"` // IDs montados por el comando
+}
+
+// undoJournal es el contenido de journal.json
+type undoJournal struct {
+	NextID  int64       `json:"next_id"`
+	Entries []UndoEntry `json:"entries"`
+}
+
+// pendingUndo son los cambios de un comando en curso sobre un disco
+type pendingUndo struct {
+	path     string // Ruta del disco tal como la recibió el comando
+	ranges   []UndoRange
+	images   [][]byte
+	size     int64
+	overflow bool // Las imágenes previas no caben en el diario o no se pudieron leer
+	mounts   []string
+}
+
+// UndoTransaction es un comando en curso cuyos cambios se registran
+type UndoTransaction struct {
+	command string
+	disks   map[string]*pendingUndo // Key: ruta normalizada del disco (ver diskLockKey)
+	order   []string
+}
+
+// Comandos en curso por disco. Cada disco lo modifica un solo comando a la vez (el que
+// tiene su bloqueo de escritura, ver LockDisks), así que las escrituras sobre un disco
+// se registran en la transacción del comando que lo bloqueó.
+var undoState = struct {
+	mutex        sync.Mutex
+	transactions map[string]*UndoTransaction
+}{transactions: make(map[string]*UndoTransaction)}
+
+// undoJournalDir retorna el directorio del diario de un disco
+func undoJournalDir(path string) string {
+	return path + ".undo"
+}
+
+// BeginUndo empieza a registrar las escrituras del comando indicado sobre los discos indicados
+// El comando debe tener bloqueados para escritura esos discos mientras dure la transacción
+func BeginUndo(command string, paths []string) *UndoTransaction {
+	undoState.mutex.Lock()
+	defer undoState.mutex.Unlock()
+
+	transaction := &UndoTransaction{
+		command: command,
+		disks:   make(map[string]*pendingUndo),
+	}
+
+	for _, path := range paths {
+		key := diskLockKey(path)
+		if _, exists := transaction.disks[key]; exists {
+			continue
+		}
+		// Los discos en memoria no tienen diario: se guardaría en archivos junto al disco
+		if IsMemoryDisk(path) {
+			continue
+		}
+		transaction.disks[key] = &pendingUndo{path: path}
+		transaction.order = append(transaction.order, key)
+		undoState.transactions[key] = transaction
+	}
+
+	return transaction
+}
+
+// EndUndo termina el registro; si el comando tuvo éxito sus cambios se guardan en el diario de cada disco
+func EndUndo(transaction *UndoTransaction, success bool) {
+	undoState.mutex.Lock()
+	for _, key := range transaction.order {
+		if undoState.transactions[key] == transaction {
+			delete(undoState.transactions, key)
+		}
+	}
+	undoState.mutex.Unlock()
+
+	if !success {
+		return
+	}
+
+	for _, key := range transaction.order {
+		pending := transaction.disks[key]
+		if err := commitUndo(pending.path, transaction.command, pending); err != nil {
+			utils.LogWarning("Undo", fmt.Sprintf("No se pudo guardar el diario de deshacer de %s: %v", pending.path, err))
+		}
+	}
+}
+
+// RecordUndoMount registra que el comando en curso montó una partición del disco
+func RecordUndoMount(path, id string) {
+	undoState.mutex.Lock()
+	defer undoState.mutex.Unlock()
+
+	if pending := pendingUndoFor(path); pending != nil {
+		pending.mounts = append(pending.mounts, id)
+	}
+}
+
+// pendingUndoFor retorna los cambios pendientes de un disco, o nil si ningún comando los registra
+func pendingUndoFor(path string) *pendingUndo {
+	key := diskLockKey(path)
+	transaction, exists := undoState.transactions[key]
+	if !exists {
+		return nil
+	}
+	return transaction.disks[key]
+}
+
+// captureUndo guarda el contenido que una escritura va a sobrescribir, si hay un comando en curso
+func captureUndo(path string, offset int64, length int) {
+	undoState.mutex.Lock()
+	defer undoState.mutex.Unlock()
+
+	pending := pendingUndoFor(path)
+	if pending == nil || pending.overflow || length == 0 {
+		return
+	}
+
+	if pending.size+int64(length) > UNDO_JOURNAL_MAX_SIZE {
+		pending.overflow = true
+		pending.ranges, pending.images = nil, nil
+		return
+	}
+
+	image, err := ReadRawFromDisk(path, offset, length)
+	if err != nil {
+		pending.overflow = true
+		pending.ranges, pending.images = nil, nil
+		return
+	}
+
+	pending.ranges = append(pending.ranges, UndoRange{Offset: offset, Length: int64(length)})
+	pending.images = append(pending.images, image)
+	pending.size += int64(length)
+}
+
+// commitUndo guarda los cambios de un comando como una entrada del diario del disco
+func commitUndo(path, command string, pending *pendingUndo) error {
+	// Si no se pudo registrar el cambio las entradas anteriores ya no se pueden alcanzar
+	if pending.overflow {
+		utils.LogWarning("Undo", fmt.Sprintf("Los cambios de '%s' no caben en el diario de deshacer de %s, se descarta el diario", command, path))
+		return RemoveUndoJournal(path)
+	}
+
+	if len(pending.ranges) == 0 && len(pending.mounts) == 0 {
+		return nil
+	}
+
+	journal, err := loadUndoJournal(path)
+	if err != nil {
+		return err
+	}
+
+	entry := UndoEntry{
+		ID:        journal.NextID,
+		Command:   command,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Bytes:     pending.size,
+		Ranges:    pending.ranges,
+		Mounts:    pending.mounts,
+	}
+	journal.NextID++
+
+	// Estado de cada rango después del comando
+	for i := range entry.Ranges {
+		data, err := ReadRawFromDisk(path, entry.Ranges[i].Offset, int(entry.Ranges[i].Length))
+		if err != nil {
+			return err
+		}
+		entry.Ranges[i].AfterHash = undoRangeHash(data)
+	}
+
+	if err := os.MkdirAll(undoJournalDir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error al crear el directorio del diario: %v", err)
+	}
+	if err := os.WriteFile(undoImagePath(path, entry.ID), bytes.Join(pending.images, nil), 0644); err != nil {
+		return fmt.Errorf("error al escribir las imágenes previas: %v", err)
+	}
+
+	journal.Entries = append(journal.Entries, entry)
+
+	// Descartar las entradas más antiguas que excedan los límites
+	total := int64(0)
+	for _, e := range journal.Entries {
+		total += e.Bytes
+	}
+	for len(journal.Entries) > UNDO_JOURNAL_MAX_ENTRIES || total > UNDO_JOURNAL_MAX_SIZE {
+		oldest := journal.Entries[0]
+		journal.Entries = journal.Entries[1:]
+		total -= oldest.Bytes
+		os.Remove(undoImagePath(path, oldest.ID))
+	}
+
+	return saveUndoJournal(path, journal)
+}
+
+// undoRangeHash retorna el SHA-256 en hexadecimal del contenido de un rango
+func undoRangeHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// undoImagePath retorna la ruta de las imágenes previas de una entrada
+func undoImagePath(path string, id int64) string {
+	return filepath.Join(undoJournalDir(path), fmt.Sprintf("%d.img", id))
+}
+
+// loadUndoJournal lee el diario del disco (vacío si no tiene)
+func loadUndoJournal(path string) (*undoJournal, error) {
+	journal := &undoJournal{NextID: 1, Entries: []UndoEntry{}}
+	if IsMemoryDisk(path) {
+		return journal, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(undoJournalDir(path), undoJournalIndexFile))
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el diario de deshacer: %v", err)
+	}
+
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("diario de deshacer dañado: %v", err)
+	}
+
+	return journal, nil
+}
+
+// saveUndoJournal escribe el índice del diario (o elimina el directorio si queda vacío)
+func saveUndoJournal(path string, journal *undoJournal) error {
+	if len(journal.Entries) == 0 {
+		return RemoveUndoJournal(path)
+	}
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error al serializar el diario de deshacer: %v", err)
+	}
+
+	// Escribir en un archivo temporal y renombrar para no dejar un índice a medias
+	indexPath := filepath.Join(undoJournalDir(path), undoJournalIndexFile)
+	if err := os.WriteFile(indexPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("error al escribir el diario de deshacer: %v", err)
+	}
+	if err := os.Rename(indexPath+".tmp", indexPath); err != nil {
+		return fmt.Errorf("error al escribir el diario de deshacer: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveUndoJournal elimina el diario de deshacer del disco
+func RemoveUndoJournal(path string) error {
+	if IsMemoryDisk(path) {
+		return nil
+	}
+	if err := os.RemoveAll(undoJournalDir(path)); err != nil {
+		return fmt.Errorf("error al eliminar el diario de deshacer: %v", err)
+	}
+	return nil
+}
+
+// ListUndo retorna las entradas del diario del disco, de la más antigua a la más reciente
+func ListUndo(path string) ([]UndoEntry, error) {
+	if !DiskExists(path) {
+		return nil, fmt.Errorf("el disco no existe: %s", path)
+	}
+
+	journal, err := loadUndoJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return journal.Entries, nil
+}
+
+// Undo deshace las últimas entradas del diario del disco, de la más reciente a la más antigua
+// Retorna las entradas deshechas; se detiene en la primera que ya no se puede deshacer
+func Undo(path string, count int) ([]UndoEntry, error) {
+	if !DiskExists(path) {
+		return nil, fmt.Errorf("el disco no existe: %s", path)
+	}
+
+	journal, err := loadUndoJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(journal.Entries) == 0 {
+		return nil, fmt.Errorf("no hay cambios para deshacer en %s", path)
+	}
+
+	undone := []UndoEntry{}
+	for len(undone) < count && len(journal.Entries) > 0 {
+		entry := journal.Entries[len(journal.Entries)-1]
+
+		if err := undoEntry(path, entry); err != nil {
+			// Guardar lo que sí se deshizo
+			if saveErr := saveUndoJournal(path, journal); saveErr != nil {
+				return undone, saveErr
+			}
+			return undone, fmt.Errorf("no se puede deshacer '%s': %v", entry.Command, err)
+		}
+
+		journal.Entries = journal.Entries[:len(journal.Entries)-1]
+		os.Remove(undoImagePath(path, entry.ID))
+		undone = append(undone, entry)
+	}
+
+	return undone, saveUndoJournal(path, journal)
+}
+
+// undoEntry verifica que los rangos de la entrada no cambiaron y reescribe sus imágenes previas
+func undoEntry(path string, entry UndoEntry) error {
+	for _, r := range entry.Ranges {
+		data, err := ReadRawFromDisk(path, r.Offset, int(r.Length))
+		if err != nil {
+			return err
+		}
+		// Las entradas de diarios anteriores solo guardan un CRC32, no se pueden verificar
+		if r.AfterHash == "" || undoRangeHash(data) != r.AfterHash {
+			return fmt.Errorf("los bytes [%d, %d) se modificaron después del comando", r.Offset, r.Offset+r.Length)
+		}
+	}
+
+	images, err := os.ReadFile(undoImagePath(path, entry.ID))
+	if err != nil {
+		return fmt.Errorf("error al leer las imágenes previas: %v", err)
+	}
+	if int64(len(images)) != entry.Bytes {
+		return fmt.Errorf("imágenes previas incompletas: %d de %d bytes", len(images), entry.Bytes)
+	}
+
+	// Separar las imágenes en el orden de los rangos
+	offsets := make([]int64, len(entry.Ranges))
+	position := int64(0)
+	for i, r := range entry.Ranges {
+		offsets[i] = position
+		position += r.Length
+	}
+
+	// En orden inverso, para que un rango escrito varias veces quede con su contenido original
+	for i := len(entry.Ranges) - 1; i >= 0; i-- {
+		r := entry.Ranges[i]
+		if err := WriteRawToDisk(path, images[offsets[i]:offsets[i]+r.Length], r.Offset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
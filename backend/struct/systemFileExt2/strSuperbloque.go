@@ -1,5 +1,11 @@
 package systemfileext2
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
 /*
 	┌───────────────────────┬────────┬───────────────────────────────────────────────────────────────┐
 	│ NOMBRE                │ TIPO   │ DESCRIPCIÓN                                                   │
@@ -44,3 +50,27 @@ type Superblock struct {
 	SInodeStart      int32 `binary:"little"` // Inicio de la tabla de inodos
 	SBlockStart      int32 `binary:"little"` // Inicio de la tabla de bloques
 }
+
+// Identificador del sistema de archivos EXT2 (s_magic)
+const EXT2_MAGIC int32 = 0xEF53
+
+// Tamaño del superbloque en bytes
+var SUPERBLOCK_SIZE = binary.Size(Superblock{})
+
+// DeserializeSuperblock convierte bytes a Superblock
+func DeserializeSuperblock(data []byte) (*Superblock, error) {
+	if len(data) < SUPERBLOCK_SIZE {
+		return nil, fmt.Errorf("datos insuficientes para el superbloque: necesarios %d, recibidos %d", SUPERBLOCK_SIZE, len(data))
+	}
+
+	superblock := &Superblock{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, superblock); err != nil {
+		return nil, fmt.Errorf("error al deserializar el superbloque: %v", err)
+	}
+	return superblock, nil
+}
+
+// HasMagic indica si el superbloque tiene el identificador de EXT2
+func (s *Superblock) HasMagic() bool {
+	return s.SMagic == EXT2_MAGIC
+}